@@ -1,32 +1,10 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"nug/pkg/lexer"
-	"nug/pkg/parser"
+	"nug/pkg/cli"
 	"os"
 )
 
 func main() {
-
-	var s = `GET http://airwallex.com/v1/api
-header: value
-hello: world
-HTTP 200
-`
-
-	l := lexer.New(s)
-
-	p := parser.New(l)
-	tree, err := p.ParseProgram()
-	if err != nil {
-		fmt.Printf("error: %v \n", err)
-		os.Exit(1)
-	}
-
-	fmt.Printf("%+v\n", *tree.RootValue)
-
-	jtree, _ := json.MarshalIndent(*tree.RootValue, "  ", "    ")
-	fmt.Println(string(jtree))
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
@@ -1,5 +1,7 @@
 package ast
 
+import "nug/pkg/token"
+
 // These are the available root node types. In JSON it will either be an
 // object or an array at the base.
 const (
@@ -17,45 +19,195 @@ type RootNode struct {
 }
 
 type Nugget struct {
-	Type    string // "Nugget"
-	Entries []Entry
+	Type      string     `json:"-"`                   // "Nugget"
+	Variables []KeyValue `json:"variables,omitempty"` // `@name = value` definitions of the .http dialect, in order
+	Entries   []Entry    `json:"entries"`
+	Comments  []Comment  `json:"comments,omitempty"` // comments after the last entry
 }
 
 type Entry struct {
-	Type string // "Entry"
-	Req  Request
-	Res  Response
+	Type     string    `json:"-"` // "Entry"
+	Req      Request   `json:"request"`
+	Res      Response  `json:"response"`           // left out of the JSON when the entry has no response
+	Comments []Comment `json:"comments,omitempty"` // comments before the request line
 }
 
 // Object represents a nugget request. It holds a slice of Property as its children,
 // a Type ("Request"), and start & end code points for displaying.
 type Request struct {
-	Type   string // "Request"
-	Line   Endpoint
-	Header []KeyValue
-	Start  int
-	End    int
+	Type     string         `json:"-"` // "Request"
+	Line     Endpoint       `json:"line"`
+	Header   []KeyValue     `json:"headers,omitempty"`
+	Body     *Body          `json:"body,omitempty"`     // nil when the request has no body
+	Comments []Comment      `json:"comments,omitempty"` // comments after the request line and before the body
+	Start    int            `json:"start_index"`
+	End      int            `json:"end_index"`
+	StartPos token.Position `json:"start"`
+	EndPos   token.Position `json:"end"` // position right after the last token of the request
 }
 
 type Response struct {
-	Type    string // "Response"
-	Version string
-	Status  int
-	Capture []KeyValue
-	Start   int
-	End     int
+	Type          string         `json:"-"`                        // "Response"
+	Version       string         `json:"version"`                  // "HTTP" matches any version, or "HTTP/1.0", "HTTP/1.1", "HTTP/2", "HTTP/3"
+	Status        int            `json:"status"`                   // 0 when the status is a wildcard
+	StatusPattern string         `json:"status_pattern,omitempty"` // "2xx" for a class of status codes or "*" for any, "" for an exact Status
+	Capture       []Capture      `json:"captures,omitempty"`
+	Asserts       []Assert       `json:"asserts,omitempty"`
+	Comments      []Comment      `json:"comments,omitempty"` // comments before and after the status line
+	Start         int            `json:"start_index"`
+	End           int            `json:"end_index"`
+	StartPos      token.Position `json:"start"`
+	EndPos        token.Position `json:"end"` // position right after the last token of the response
 }
 
 type Endpoint struct {
-	Type   string // "Endpoint"
-	Method string
-	Url    string
+	Type        string    `json:"-"` // "Endpoint"
+	Method      string    `json:"method"`
+	Url         string    `json:"url"`
+	UrlTemplate *Template `json:"url_template,omitempty"` // nil when Url has no {{name}} reference
 }
 
 type KeyValue struct {
-	Type  string // "KeyValue"
-	Key   string
-	Value string
+	Type          string         `json:"-"` // "KeyValue"
+	Key           string         `json:"key"`
+	Value         string         `json:"value"`
+	ValueTemplate *Template      `json:"value_template,omitempty"` // nil when Value has no {{name}} reference
+	Comments      []Comment      `json:"comments,omitempty"`       // comments before the key and after the value
+	StartPos      token.Position `json:"start"`
+	EndPos        token.Position `json:"end"`
+}
+
+// Assert is a line of the `[Asserts]` section, such as `jsonpath "$.id" == 1`.
+// The value its query selects in the response must satisfy its predicate.
+type Assert struct {
+	Type      string         `json:"-"` // "Assert"
+	Query     Query          `json:"query"`
+	Predicate Predicate      `json:"predicate"`
+	Comments  []Comment      `json:"comments,omitempty"` // comments before the assert and after it
+	StartPos  token.Position `json:"start"`
+	EndPos    token.Position `json:"end"`
+}
+
+// Capture is a line of the `[Capture]` section, such as `id: jsonpath "$.id"`.
+// The value its query selects in the response is stored in the variable Name.
+type Capture struct {
+	Type     string         `json:"-"` // "Capture"
+	Name     string         `json:"name"`
+	Query    Query          `json:"query"`
+	Comments []Comment      `json:"comments,omitempty"` // comments before the capture and after it
+	StartPos token.Position `json:"start"`
+	EndPos   token.Position `json:"end"`
+}
+
+// Query selects a value of the response, for an assert or a capture
+type Query struct {
+	Type string `json:"-"`             // "Query"
+	Kind string `json:"kind"`          // "status", "header", "cookie", "jsonpath", "regex", "xpath", "body", "url" or "duration"
+	Arg  string `json:"arg,omitempty"` // the header or cookie name, the JSON path, the regex or the XPath, "" for the other kinds
+}
+
+type Predicate struct {
+	Type  string   `json:"-"`               // "Predicate"
+	Op    string   `json:"op"`              // "==", "!=", ">", ">=", "<", "<=", "contains", "startsWith", "matches", "exists" or "isInteger"
+	Value *Literal `json:"value,omitempty"` // nil for "exists" and "isInteger"
+}
+
+// Template is a string referencing variables with the `{{name}}` syntax. Parts
+// holds its literal text and its references in source order.
+type Template struct {
+	Type  string         `json:"type"` // "Template"
+	Parts []TemplatePart `json:"parts"`
+}
+
+// TemplatePart is either a piece of literal text (Type "Text") or a
+// reference to a variable (Type "Variable") whose name is in Value.
+type TemplatePart struct {
+	Type  string         `json:"type"` // "Text" or "Variable"
+	Value string         `json:"value"`
+	Pos   token.Position `json:"pos"` // position of the part in the input
+}
+
+// Variables returns the names of the variables referenced by the template
+func (t *Template) Variables() []string {
+	var names []string
+	for _, part := range t.Parts {
+		if part.Type == "Variable" {
+			names = append(names, part.Value)
+		}
+	}
+	return names
+}
+
+// Comment is a `#` comment. Comments are attached to the node that follows
+// them, or to the node they trail when Inline is true (e.g. `key: value # note`).
+type Comment struct {
+	Type     string         `json:"-"`    // "Comment"
+	Text     string         `json:"text"` // Full comment, including the leading `#`
+	Inline   bool           `json:"inline"`
+	Line     int            `json:"line"`        // 0-based line, kept for compatibility: prefer StartPos.Line
+	Start    int            `json:"start_index"` // rune index of the `#`
+	End      int            `json:"end_index"`   // rune index after the last char of the comment
+	StartPos token.Position `json:"start"`
+	EndPos   token.Position `json:"end"` // position right after the last char of the comment
+}
+
+// Body is the JSON payload sent with a request. Value holds the parsed tree
+// (an Object or an Array) and Raw the source text exactly as written, which is
+// what gets sent to the server.
+type Body struct {
+	Type     string         `json:"-"` // "Body"
+	Value    Value          `json:"value"`
+	Raw      string         `json:"raw"`
+	Template *Template      `json:"template,omitempty"` // nil when Raw has no {{name}} reference
+	Start    int            `json:"start_index"`
+	End      int            `json:"end_index"`
+	StartPos token.Position `json:"start"`
+	EndPos   token.Position `json:"end"`
+}
+
+// Value will eventually have some methods that all Values will have to implement.
+// For now, it holds an Object, an Array, a Literal or a Template (for a bare
+// `{{name}}` used as a value).
+type Value interface{}
+
+// Object represents a JSON object. It holds a slice of Property as its children,
+// a Type ("Object"), and start & end code points for displaying.
+type Object struct {
+	Type     string     `json:"type"` // "Object"
+	Children []Property `json:"children"`
+	Start    int        `json:"start_index"`
+	End      int        `json:"end_index"`
+}
+
+// Array represents a JSON array. It holds a slice of Value as its children,
+// a Type ("Array"), and start & end code points for displaying.
+type Array struct {
+	Type     string  `json:"type"` // "Array"
+	Children []Value `json:"children"`
+	Start    int     `json:"start_index"`
+	End      int     `json:"end_index"`
+}
+
+// Property holds a Type ("Property") as well as a `Key` and `Value`. The Key is
+// an Identifier and the value is any Value.
+type Property struct {
+	Type  string     `json:"-"` // "Property"
+	Key   Identifier `json:"key"`
+	Value Value      `json:"value"`
+}
+
+// Identifier represents a JSON object property key
+type Identifier struct {
+	Type  string // "Identifier"
+	Value string // Unquoted key, with escape sequences applied
+}
+
+// Literal represents a JSON string, number, boolean or null. Value holds a
+// string, a float64, a bool or nil respectively, a number out of the range of
+// a float64 is a json.Number.
+type Literal struct {
+	Type  string `json:"type"` // "Literal"
+	Value Value  `json:"value"`
 }
 
 // state is a type alias for int and used to create the available value states below
@@ -76,6 +228,7 @@ const (
 	ReqStart
 	ReqOpen
 	ReqLine
+	ReqBody
 
 	// Response states
 	ResStart
@@ -92,6 +245,23 @@ const (
 	HeaderKey
 	HeaderValue
 
+	// Object states
+	ObjStart
+	ObjOpen
+	ObjProperty
+	ObjComma
+
+	// Property states
+	PropertyStart
+	PropertyKey
+	PropertyColon
+
+	// Array states
+	ArrayStart
+	ArrayOpen
+	ArrayValue
+	ArrayComma
+
 	// String states
 	StringStart
 	StringQuoteOrChar
@@ -106,4 +276,5 @@ const (
 	NumberDigitFraction
 	NumberExp
 	NumberExpDigitOrSign
+	NumberExpDigit
 )
@@ -0,0 +1,215 @@
+package ast
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the version of the JSON form of the AST. It changes with each
+// change of the form that breaks its readers.
+const Version = 1
+
+// Schema is the JSON Schema of the Envelope
+//
+//go:embed schema.json
+var Schema []byte
+
+// Envelope is the JSON form of a nugget, along with the Version of the form
+type Envelope struct {
+	Version int     `json:"version"`
+	Nugget  *Nugget `json:"nugget"`
+}
+
+// Marshal returns the JSON form of a nugget, wrapped in an Envelope
+func Marshal(nugget *Nugget) ([]byte, error) {
+	return json.Marshal(Envelope{Version: Version, Nugget: nugget})
+}
+
+// Unmarshal reads a nugget back from its JSON form, as written by Marshal. The
+// Type fields left out of the JSON are set back.
+func Unmarshal(data []byte) (*Nugget, error) {
+	var envelope struct {
+		Version int             `json:"version"`
+		Nugget  json.RawMessage `json:"nugget"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Version != Version {
+		return nil, fmt.Errorf("unsupported AST version %d, expected %d", envelope.Version, Version)
+	}
+	if envelope.Nugget == nil {
+		return nil, fmt.Errorf("missing nugget")
+	}
+
+	var nugget Nugget
+	if err := json.Unmarshal(envelope.Nugget, &nugget); err != nil {
+		return nil, err
+	}
+	nugget.setTypes()
+	return &nugget, nil
+}
+
+// MarshalJSON leaves out the response of an entry that has none
+func (e Entry) MarshalJSON() ([]byte, error) {
+	type entry Entry
+	var res *Response
+	if e.Res.Version != "" {
+		res = &e.Res
+	}
+	return json.Marshal(struct {
+		entry
+		Res *Response `json:"response,omitempty"`
+	}{entry(e), res})
+}
+
+// MarshalJSON writes an identifier as a plain string
+func (i Identifier) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.Value)
+}
+
+func (i *Identifier) UnmarshalJSON(data []byte) error {
+	i.Type = "Identifier"
+	return json.Unmarshal(data, &i.Value)
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	type body Body
+	var v struct {
+		body
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*b = Body(v.body)
+	var err error
+	b.Value, err = unmarshalValue(v.Value)
+	return err
+}
+
+func (p *Property) UnmarshalJSON(data []byte) error {
+	type property Property
+	var v struct {
+		property
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*p = Property(v.property)
+	p.Type = "Property"
+	var err error
+	p.Value, err = unmarshalValue(v.Value)
+	return err
+}
+
+func (a *Array) UnmarshalJSON(data []byte) error {
+	type array Array
+	var v struct {
+		array
+		Children []json.RawMessage `json:"children"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*a = Array(v.array)
+	a.Children = nil
+	for _, child := range v.Children {
+		value, err := unmarshalValue(child)
+		if err != nil {
+			return err
+		}
+		a.Children = append(a.Children, value)
+	}
+	return nil
+}
+
+// unmarshalValue reads a Value, which one is told by its "type"
+func unmarshalValue(data json.RawMessage) (Value, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	var v struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	switch v.Type {
+	case "Object":
+		var obj Object
+		err := json.Unmarshal(data, &obj)
+		return obj, err
+	case "Array":
+		var arr Array
+		err := json.Unmarshal(data, &arr)
+		return arr, err
+	case "Literal":
+		var lit Literal
+		err := json.Unmarshal(data, &lit)
+		return lit, err
+	case "Template":
+		var tmpl Template
+		err := json.Unmarshal(data, &tmpl)
+		return tmpl, err
+	}
+	return nil, fmt.Errorf("unknown value type `%s`", v.Type)
+}
+
+// setTypes sets the Type fields that the JSON form leaves out
+func (n *Nugget) setTypes() {
+	n.Type = "Nugget"
+	setCommentTypes(n.Comments)
+	for i := range n.Variables {
+		n.Variables[i].Type = "KeyValue"
+		setCommentTypes(n.Variables[i].Comments)
+	}
+
+	for i := range n.Entries {
+		entry := &n.Entries[i]
+		entry.Type = "Entry"
+		setCommentTypes(entry.Comments)
+
+		req := &entry.Req
+		req.Type = "Request"
+		req.Line.Type = "Endpoint"
+		setCommentTypes(req.Comments)
+		for j := range req.Header {
+			req.Header[j].Type = "KeyValue"
+			setCommentTypes(req.Header[j].Comments)
+		}
+		if req.Body != nil {
+			req.Body.Type = "Body"
+		}
+
+		res := &entry.Res
+		res.Type = "Response"
+		setCommentTypes(res.Comments)
+		for j := range res.Capture {
+			capture := &res.Capture[j]
+			capture.Type = "Capture"
+			capture.Query.Type = "Query"
+			setCommentTypes(capture.Comments)
+		}
+		for j := range res.Asserts {
+			assert := &res.Asserts[j]
+			assert.Type = "Assert"
+			assert.Query.Type = "Query"
+			assert.Predicate.Type = "Predicate"
+			setCommentTypes(assert.Comments)
+		}
+	}
+}
+
+func setCommentTypes(comments []Comment) {
+	for i := range comments {
+		comments[i].Type = "Comment"
+	}
+}
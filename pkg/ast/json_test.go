@@ -0,0 +1,159 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"nug/pkg/ast"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+	"nug/pkg/token"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	input := `# login
+POST https://test.com/{{tenant}}/login # inline
+Content-Type: application/json
+X-Id: {{id}}
+{"user": "{{user}}", "tags": ["a", 1, true, null, {}], "id": {{id}}, "none": []}
+HTTP/2 2xx
+[Capture]
+token: jsonpath "$.token" # the token
+[Asserts]
+status == 200
+header "X-Id" exists
+
+GET https://test.com/todos
+# end`
+
+	p := parser.New(lexer.New(input))
+	root, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	data, err := ast.Marshal(root.RootValue)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+	nugget, err := ast.Unmarshal(data)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	if !reflect.DeepEqual(nugget, root.RootValue) {
+		t.Fatalf("error: expected %+v, got: %+v", root.RootValue, nugget)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	p := parser.New(lexer.New("GET https://test.com\nHTTP 200"))
+	root, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	data, err := ast.Marshal(root.RootValue)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	expected := `{"version":1,"nugget":{"entries":[{"request":{"line":{"method":"GET","url":"https://test.com"},` +
+		`"start_index":0,"end_index":20,"start":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":21,"offset":20}},` +
+		`"response":{"version":"HTTP","status":200,"start_index":21,"end_index":29,` +
+		`"start":{"line":2,"column":1,"offset":21},"end":{"line":2,"column":9,"offset":29}}}]}}`
+	if string(data) != expected {
+		t.Fatalf("error: expected %s, got: %s", expected, data)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{`{"nugget": {"entries": []}}`, "unsupported AST version 0, expected 1"},
+		{`{"version": 2, "nugget": {"entries": []}}`, "unsupported AST version 2, expected 1"},
+		{`{"version": 1}`, "missing nugget"},
+		{`{"version": 1, "nugget": {"entries": [{"request": {"body": {"value": {"type": "Set"}}}}]}}`, "unknown value type `Set`"},
+	}
+
+	for _, test := range tests {
+		_, err := ast.Unmarshal([]byte(test.input))
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("error: %s - expected %q, got: %v", test.input, test.err, err)
+		}
+	}
+
+	if _, err := ast.Unmarshal([]byte(`[]`)); err == nil {
+		t.Fatal("error: expected an error for a JSON array")
+	}
+}
+
+// TestSchema checks that the properties of each definition of the schema are
+// the JSON fields of its Go type
+func TestSchema(t *testing.T) {
+	var schema struct {
+		Properties map[string]interface{}
+		Defs       map[string]struct {
+			Properties map[string]interface{}
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(ast.Schema, &schema); err != nil {
+		t.Fatal("error: invalid schema: ", err)
+	}
+
+	types := map[string]interface{}{
+		"nugget":       ast.Nugget{},
+		"entry":        ast.Entry{},
+		"request":      ast.Request{},
+		"response":     ast.Response{},
+		"endpoint":     ast.Endpoint{},
+		"keyValue":     ast.KeyValue{},
+		"capture":      ast.Capture{},
+		"assert":       ast.Assert{},
+		"query":        ast.Query{},
+		"predicate":    ast.Predicate{},
+		"body":         ast.Body{},
+		"object":       ast.Object{},
+		"property":     ast.Property{},
+		"array":        ast.Array{},
+		"literal":      ast.Literal{},
+		"template":     ast.Template{},
+		"templatePart": ast.TemplatePart{},
+		"comment":      ast.Comment{},
+		"position":     token.Position{},
+	}
+
+	check := func(name string, v interface{}, properties map[string]interface{}) {
+		var fields []string
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			tag := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			if tag != "-" {
+				fields = append(fields, tag)
+			}
+		}
+		var names []string
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(fields)
+		sort.Strings(names)
+		if !reflect.DeepEqual(fields, names) {
+			t.Fatalf("error: %s - expected properties %v, got: %v", name, fields, names)
+		}
+	}
+
+	check("envelope", ast.Envelope{}, schema.Properties)
+	for name, v := range types {
+		def, ok := schema.Defs[name]
+		if !ok {
+			t.Fatalf("error: missing definition %s", name)
+		}
+		check(name, v, def.Properties)
+	}
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+
+	"nug/pkg/parser"
+	"nug/pkg/resolver"
+)
+
+// checkCmd reports every syntax error of the nuggets, and the references to
+// variables that are neither given with --var nor captured before their use
+func checkCmd(e *env, args []string) int {
+	flags := e.newFlags("check", "[--output json|text] [--var name=value] [file ...]")
+	out := outputFlag(flags)
+	vars := variablesFlag(flags)
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	inputs, err := e.readInputs(files)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+
+	diags := []diagnostic{}
+	for _, in := range inputs {
+		root, syntax := parse(in, parser.Recover())
+		diags = append(diags, syntax...)
+		if root.RootValue == nil {
+			continue
+		}
+		for _, err := range resolver.Check(root.RootValue, vars) {
+			diags = append(diags, diagnostic{
+				File:    in.Name,
+				Line:    err.Pos.Line,
+				Column:  err.Pos.Column,
+				Message: fmt.Sprintf("undefined variable `%s`", err.Name),
+			})
+		}
+	}
+
+	if *out == "json" {
+		e.writeJSON(diags)
+	} else {
+		for _, d := range diags {
+			fmt.Fprintln(e.stdout, d)
+		}
+	}
+
+	if len(diags) > 0 {
+		return ExitFailure
+	}
+	return ExitOK
+}
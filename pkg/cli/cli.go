@@ -0,0 +1,221 @@
+package cli
+
+// The cli package is the nug command. Each command reads nugget files, or
+// stdin when none is given, and writes its result to stdout as text or JSON.
+// The files ending in .http or .rest are read in the .http dialect.
+//
+//	nug parse [--output json|text] [file ...]
+//	nug check [--output json|text] [--var name=value] [file ...]
+//	nug run [--output json|text] [--var name=value] [--timeout 30s] [file ...]
+//	nug fmt [-l] [-w] [-d] [file ...]
+//	nug import curl|har|http|openapi [file ...]
+//	nug import postman [--environment file] [file ...]
+//	nug export curl [--entry N] [--var name=value] [file ...]
+//	nug export har [--var name=value] [--timeout 30s] [file ...]
+//	nug export http [--var name=value] [file ...]
+//	nug schema
+//	nug lsp
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exit codes of the commands
+const (
+	ExitOK      = 0 // everything went fine
+	ExitFailure = 1 // syntax errors, undefined variables, failed checks or unformatted files
+	ExitUsage   = 2 // bad command line
+	ExitError   = 3 // a file cannot be read or written, or a request cannot be sent
+)
+
+const usage = `usage: nug <command> [flags] [file ...]
+
+commands:
+  parse   print the AST of the nuggets
+  check   report the syntax errors and the undefined variables
+  run     send the requests and check the responses
+  fmt     format the nuggets
+  import  convert curl commands, HTTP archives, OpenAPI or Postman to nuggets
+  export  convert nuggets to curl commands or .http files, or run them to an
+          HTTP archive
+  schema  print the JSON Schema of the AST
+  lsp     run the language server on stdin and stdout
+
+Files can be glob patterns, and "-" or no file reads stdin. The .http and
+.rest files are read in the dialect of the REST Client.
+Run "nug <command> -h" for the flags of a command.
+`
+
+// env is where a command reads its input and writes its output
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+type command func(e *env, args []string) int
+
+var commands = map[string]command{
+	"parse":  parseCmd,
+	"check":  checkCmd,
+	"run":    runCmd,
+	"fmt":    fmtCmd,
+	"import": importCmd,
+	"export": exportCmd,
+	"schema": schemaCmd,
+	"lsp":    lspCmd,
+}
+
+// Run runs the command named by args[0] with the rest of args and returns the
+// exit code
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return ExitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "nug: unknown command `%s`\n\n%s", args[0], usage)
+		return ExitUsage
+	}
+	return cmd(e, args[1:])
+}
+
+// newFlags returns the flag set of a command, its errors and usage go to
+// stderr
+func (e *env) newFlags(name, synopsis string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: nug %s %s\n", name, synopsis)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses args with flags, which can come before or after the
+// files. It returns the files, or false on a bad command line.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, bool) {
+	var files []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, false
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return files, true
+		}
+		if args[0] == "--" {
+			return append(files, args[1:]...), true
+		}
+		files = append(files, args[0])
+		args = args[1:]
+	}
+}
+
+// output is the value of the --output flag
+type output string
+
+func (o *output) String() string { return string(*o) }
+
+func (o *output) Set(s string) error {
+	if s != "json" && s != "text" {
+		return fmt.Errorf("expected json or text, got: `%s`", s)
+	}
+	*o = output(s)
+	return nil
+}
+
+func outputFlag(flags *flag.FlagSet) *output {
+	o := output("text")
+	flags.Var(&o, "output", "output format, json or text")
+	return &o
+}
+
+// variables is the value of the repeated --var flag
+type variables map[string]string
+
+func (v variables) String() string { return "" }
+
+func (v variables) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got: `%s`", s)
+	}
+	v[name] = value
+	return nil
+}
+
+func variablesFlag(flags *flag.FlagSet) variables {
+	v := variables{}
+	flags.Var(v, "var", "value of a variable, as name=value (repeatable)")
+	return v
+}
+
+// input is a nugget to read, Name is "-" for stdin
+type input struct {
+	Name string
+	Src  []byte
+}
+
+// readInputs reads the files, after expanding their glob patterns, or stdin
+// when there is no file or for a file named "-"
+func (e *env) readInputs(files []string) ([]input, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var inputs []input
+	for _, pattern := range files {
+		if pattern == "-" {
+			src, err := io.ReadAll(e.stdin)
+			if err != nil {
+				return nil, fmt.Errorf("stdin: %v", err)
+			}
+			inputs = append(inputs, input{Name: "-", Src: src})
+			continue
+		}
+
+		names := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: no file matches the pattern", pattern)
+			}
+			names = matches
+		}
+
+		for _, name := range names {
+			src, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, input{Name: name, Src: src})
+		}
+	}
+	return inputs, nil
+}
+
+// writeJSON writes v as indented JSON
+func (e *env) writeJSON(v interface{}) {
+	enc := json.NewEncoder(e.stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
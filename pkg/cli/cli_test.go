@@ -0,0 +1,356 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nug/pkg/ast"
+)
+
+// run runs the command line args with stdin and returns its exit code and
+// outputs
+func run(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeFiles writes the files in a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal("error: ", err)
+		}
+	}
+	return dir
+}
+
+func TestUsage(t *testing.T) {
+	tests := [...]struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{nil, ExitUsage, "usage: nug <command>"},
+		{[]string{"build"}, ExitUsage, "nug: unknown command `build`"},
+		{[]string{"parse", "--output", "yaml"}, ExitUsage, "invalid value \"yaml\" for flag -output: expected json or text, got: `yaml`"},
+		{[]string{"check", "--var", "token"}, ExitUsage, "expected name=value, got: `token`"},
+		{[]string{"parse", "missing.nug"}, ExitError, "nug: open missing.nug: no such file or directory"},
+		{[]string{"parse", "missing/*.nug"}, ExitError, "nug: missing/*.nug: no file matches the pattern"},
+	}
+
+	for _, test := range tests {
+		code, _, stderr := run(t, "", test.args...)
+		if code != test.code {
+			t.Fatalf("error: %v - expected exit code %d, got: %d", test.args, test.code, code)
+		}
+		if !strings.Contains(stderr, test.stderr) {
+			t.Fatalf("error: %v - expected %q in stderr, got: %q", test.args, test.stderr, stderr)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.nug": "GET https://test.com/a\nHTTP 200",
+		"b.nug": "POST https://test.com/b\nHTTP 2xx",
+		"c.txt": "not a nugget",
+	})
+
+	code, stdout, stderr := run(t, "", "parse", filepath.Join(dir, "*.nug"))
+	expected := dir + "/a.nug: line 1: GET https://test.com/a -> HTTP 200\n" +
+		dir + "/b.nug: line 1: POST https://test.com/b -> HTTP 2xx\n"
+	if code != ExitOK || stdout != expected || stderr != "" {
+		t.Fatalf("error: unexpected result %d %q %q", code, stdout, stderr)
+	}
+
+	code, stdout, _ = run(t, "GET https://test.com\n", "parse", "-", "--output", "json")
+	nugget, err := ast.Unmarshal([]byte(stdout))
+	if err != nil {
+		t.Fatalf("error: invalid JSON %q: %v", stdout, err)
+	}
+	if code != ExitOK || len(nugget.Entries) != 1 || nugget.Entries[0].Req.Line.Url != "https://test.com" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "schema")
+	if code != ExitOK || stdout != string(ast.Schema) {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, stderr = run(t, "GET https://test.com\nHTTP abc", "parse")
+	if code != ExitFailure || stdout != "" || stderr != "-: line 2, column 6: expected status code, got: `abc`\n" {
+		t.Fatalf("error: unexpected result %d %q %q", code, stdout, stderr)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	input := `GET https://test.com/{{path}}
+HTTP abc
+
+GET https://test.com/{{id}}
+Authorization: {{token}}
+HTTP 200
+`
+
+	code, stdout, _ := run(t, input, "check", "--var", "token=abc")
+	expected := "-: line 2, column 6: expected status code, got: `abc`\n" +
+		"-: line 4, column 22: undefined variable `id`\n"
+	if code != ExitFailure || stdout != expected {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, input, "check", "--output", "json")
+	var diags []diagnostic
+	if err := json.Unmarshal([]byte(stdout), &diags); err != nil {
+		t.Fatalf("error: invalid JSON %q: %v", stdout, err)
+	}
+	if code != ExitFailure || len(diags) != 3 || diags[2] != (diagnostic{"-", 5, 16, "undefined variable `token`"}) {
+		t.Fatalf("error: unexpected result %d %v", code, diags)
+	}
+
+	code, stdout, _ = run(t, "GET https://test.com\nHTTP 200", "check", "--output", "json")
+	if code != ExitOK || stdout != "[]\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer server.Close()
+
+	input := `GET {{host}}/todos
+HTTP 200
+[Capture]
+id: $.id
+
+GET {{host}}/todos/{{id}}
+HTTP 201
+[Asserts]
+jsonpath "$.id" == 42
+`
+
+	code, stdout, _ := run(t, input, "run", "--var", "host="+server.URL)
+	lines := strings.Split(stdout, "\n")
+	if code != ExitFailure || len(lines) != 5 ||
+		!strings.HasPrefix(lines[0], "-: line 1: GET "+server.URL+"/todos 200 (") ||
+		!strings.HasPrefix(lines[1], "-: line 6: GET "+server.URL+"/todos/42 200 (") ||
+		lines[2] != "-: line 7, column 1: expected status `201`, got: `200`" ||
+		lines[3] != "2 entries run, 1 check failed" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, input, "run", "--output", "json", "--var", "host="+server.URL)
+	var reports []fileReport
+	if err := json.Unmarshal([]byte(stdout), &reports); err != nil {
+		t.Fatalf("error: invalid JSON %q: %v", stdout, err)
+	}
+	if code != ExitFailure || len(reports) != 1 || len(reports[0].Entries) != 2 {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+	checks := reports[0].Entries[1].Checks
+	expected := checkReport{Type: "Assert", Line: 9, Column: 1, Expected: `jsonpath "$.id" == 42`, Actual: "42", Pass: true}
+	if len(checks) != 3 || checks[2] != expected {
+		t.Fatalf("error: unexpected checks %v", checks)
+	}
+
+	code, _, stderr := run(t, "GET http://127.0.0.1:0/todos\nHTTP 200", "run")
+	if code != ExitError || !strings.HasPrefix(stderr, "-: entry 1, line 1: ") {
+		t.Fatalf("error: unexpected result %d %q", code, stderr)
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.nug": "get https://test.com/a\nHTTP 200\n",
+		"b.nug": "GET https://test.com/b\nHTTP 200\n",
+	})
+	a, b := filepath.Join(dir, "a.nug"), filepath.Join(dir, "b.nug")
+
+	code, stdout, _ := run(t, "", "fmt", "-l", filepath.Join(dir, "*.nug"))
+	if code != ExitFailure || stdout != a+"\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "fmt", "-d", a)
+	if code != ExitOK || !strings.Contains(stdout, "-get https://test.com/a\n+GET https://test.com/a\n") {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "fmt", "-w", a, b)
+	src, _ := os.ReadFile(a)
+	if code != ExitOK || stdout != "" || string(src) != "GET https://test.com/a\nHTTP 200\n" {
+		t.Fatalf("error: unexpected result %d %q %q", code, stdout, src)
+	}
+
+	code, stdout, _ = run(t, "put https://test.com\n", "fmt")
+	if code != ExitOK || stdout != "PUT https://test.com\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, _, stderr := run(t, "put https://test.com\n", "fmt", "-w")
+	if code != ExitUsage || stderr != "nug: cannot use -w with stdin\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stderr)
+	}
+}
+
+func TestLsp(t *testing.T) {
+	message := func(content string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(content), content)
+	}
+	stdin := message(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`) + message(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	code, stdout, _ := run(t, stdin, "lsp")
+	if code != ExitOK || stdout != message(`{"jsonrpc":"2.0","id":1,"result":null}`) {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, _, stderr := run(t, message(`{"jsonrpc":"2.0","method":"exit"}`), "lsp")
+	if code != ExitError || stderr != "nug: exit without shutdown\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stderr)
+	}
+}
+
+func TestImport(t *testing.T) {
+	code, stdout, _ := run(t, "curl https://test.com -H 'Accept: text/html' --compressed", "import", "curl")
+	if code != ExitOK || stdout != "GET https://test.com\nAccept: text/html\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, stderr := run(t, "curl -k https://test.com\ncurl -F a=b", "import", "curl")
+	if code != ExitFailure || stdout != "GET https://test.com\n" ||
+		stderr != "-: line 1: unsupported option `-k`\n-: line 2: unsupported option `--form`\n-: line 2: expected a URL\n" {
+		t.Fatalf("error: unexpected result %d %q %q", code, stdout, stderr)
+	}
+
+	code, _, stderr = run(t, "", "import", "wget")
+	if code != ExitUsage || stderr != "usage: nug import <curl|har|http|openapi|postman> [file ...]\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stderr)
+	}
+}
+
+func TestImportPostman(t *testing.T) {
+	env := filepath.Join(t.TempDir(), "env.json")
+	if err := os.WriteFile(env, []byte(`{"values": [{"key": "host", "value": "test.com"}]}`), 0o644); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	collection := `{"info": {"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"}, "item": [{"name": "Home", "request": "https://{{host}}"}]}`
+
+	code, stdout, _ := run(t, collection, "import", "postman", "--environment", env)
+	if code != ExitOK || stdout != "# nug run --var host=test.com\n# Home\nGET https://{{host}}\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, _, stderr := run(t, collection, "import", "postman", "--environment", env+".missing")
+	if code != ExitError || !strings.HasPrefix(stderr, "nug: open ") {
+		t.Fatalf("error: unexpected result %d %q", code, stderr)
+	}
+}
+
+func TestExport(t *testing.T) {
+	input := "GET https://test.com/a\n\nPOST https://test.com/{{path}}\nAuthorization: {{token}}\n{\"a\": 1}\n"
+
+	code, stdout, _ := run(t, input, "export", "curl", "--var", "path=b")
+	expected := "curl https://test.com/a\n\ncurl https://test.com/b \\\n  -H 'Authorization: '\"${token}\" \\\n  -H 'Content-Type: application/json' \\\n  --data-raw '{\"a\": 1}'\n"
+	if code != ExitOK || stdout != expected {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, input, "export", "curl", "--entry", "1")
+	if code != ExitOK || stdout != "curl https://test.com/a\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, _, stderr := run(t, input, "export", "curl", "--entry", "3")
+	if code != ExitUsage || stderr != "-: expected an entry from 1 to 2, got: 3\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stderr)
+	}
+
+	code, _, stderr = run(t, "GET https://test.com\nHTTP abc", "export", "curl")
+	if code != ExitFailure || stderr != "-: line 2, column 6: expected status code, got: `abc`\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stderr)
+	}
+}
+
+func TestHar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	input := "GET " + server.URL + "/{{path}}\nHTTP 200\n"
+	code, stdout, _ := run(t, input, "export", "har", "--var", "path=a")
+	if code != ExitOK || !strings.Contains(stdout, `"url": "`+server.URL+`/a"`) || !strings.Contains(stdout, `"text": "ok"`) {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, imported, _ := run(t, stdout, "import", "har")
+	if code != ExitOK || imported != "GET "+server.URL+"/a\nHTTP 200\n" {
+		t.Fatalf("error: unexpected result %d %q", code, imported)
+	}
+
+	code, stdout, stderr := run(t, "GET http://127.0.0.1:1\n", "export", "har")
+	if code != ExitError || !strings.Contains(stdout, `"entries": []`) || !strings.Contains(stderr, "-: entry 1, line 1: ") {
+		t.Fatalf("error: unexpected result %d %q %q", code, stdout, stderr)
+	}
+}
+
+func TestHTTPFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.http": "@host = test.com\n###\nget https://{{host}}/a HTTP/1.1\n// no cache\nCache-Control: no-cache\n",
+		"b.http": "@url = https://{{host}}\nGET {{url}}\n",
+	})
+	a, b := filepath.Join(dir, "a.http"), filepath.Join(dir, "b.http")
+
+	code, stdout, _ := run(t, "", "fmt", a)
+	if code != ExitOK || stdout != "@host = test.com\n\n###\nGET https://{{host}}/a\n// no cache\nCache-Control: no-cache\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "export", "curl", a)
+	if code != ExitOK || stdout != "curl https://test.com/a \\\n  -H 'Cache-Control: no-cache'\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "", "import", "http", a)
+	if code != ExitOK || stdout != "# nug run --var host=test.com\nGET https://{{host}}/a\n# no cache\nCache-Control: no-cache\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	code, stdout, _ = run(t, "GET https://{{host}}/a\nHTTP 200\n", "export", "http", "--var", "host=test.com")
+	if code != ExitOK || stdout != "@host = test.com\n\nGET https://{{host}}/a\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	// a .http file exports back with its own variables, unless --var gives them
+	code, stdout, _ = run(t, "", "export", "http", a)
+	if code != ExitOK || stdout != "@host = test.com\n\n###\nGET https://{{host}}/a\n// no cache\nCache-Control: no-cache\n" {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+	code, stdout, _ = run(t, "", "export", "http", "--var", "host=localhost", a)
+	if code != ExitOK || !strings.HasPrefix(stdout, "@host = localhost\n") {
+		t.Fatalf("error: unexpected result %d %q", code, stdout)
+	}
+
+	// a variable defined with an undefined one is reported
+	for _, format := range []string{"curl", "http"} {
+		code, stdout, stderr := run(t, "", "export", format, b)
+		if code != ExitFailure || stdout != "" || stderr != b+": line 1, column 16: undefined variable `host`\n" {
+			t.Fatalf("error: %s - unexpected result %d %q %q", format, code, stdout, stderr)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"nug/pkg/ast"
+	"nug/pkg/curl"
+	"nug/pkg/har"
+	"nug/pkg/httpfile"
+	"nug/pkg/resolver"
+	"nug/pkg/runner"
+)
+
+// exporters are the subcommands of export, one per format
+var exporters = map[string]command{
+	"curl": exportCurlCmd,
+	"har":  exportHarCmd,
+	"http": exportHTTPCmd,
+}
+
+// exportCmd converts nuggets to another format, printed to stdout
+func exportCmd(e *env, args []string) int {
+	if len(args) == 0 || exporters[args[0]] == nil {
+		names := make([]string, 0, len(exporters))
+		for name := range exporters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(e.stderr, "usage: nug export <%s> [flags] [file ...]\n", strings.Join(names, "|"))
+		return ExitUsage
+	}
+	return exporters[args[0]](e, args[1:])
+}
+
+// exportCurlCmd prints the curl command of each entry of the nuggets, or only
+// of the entry given by --entry. The references to the variables given by
+// --var, or defined by a .http file, are replaced by their value.
+func exportCurlCmd(e *env, args []string) int {
+	flags := e.newFlags("export curl", "[--entry N] [--var name=value] [file ...]")
+	index := flags.Int("entry", 0, "the entry to export, from 1, instead of all of them")
+	vars := variablesFlag(flags)
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	inputs, err := e.readInputs(files)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+
+	code := ExitOK
+	for _, in := range inputs {
+		root, diags := parse(in)
+		if len(diags) > 0 {
+			for _, d := range diags {
+				fmt.Fprintln(e.stderr, d)
+			}
+			code = ExitFailure
+			continue
+		}
+
+		fileVars, err := fileVariables(root.RootValue, vars)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "%s: %v\n", in.Name, err)
+			code = ExitFailure
+			continue
+		}
+
+		entries := root.RootValue.Entries
+		if *index != 0 {
+			if *index < 0 || *index > len(entries) {
+				fmt.Fprintf(e.stderr, "%s: expected an entry from 1 to %d, got: %d\n", in.Name, len(entries), *index)
+				return ExitUsage
+			}
+			entries = entries[*index-1 : *index]
+		}
+		for i, entry := range entries {
+			if i > 0 {
+				fmt.Fprintln(e.stdout)
+			}
+			fmt.Fprintln(e.stdout, curl.Export(entry, fileVars))
+		}
+	}
+	return code
+}
+
+// exportHarCmd runs the nuggets like runCmd and prints the archive of the
+// requests sent and the responses received. The archive holds the entries
+// run before an error, which is reported on stderr.
+func exportHarCmd(e *env, args []string) int {
+	flags := e.newFlags("export har", "[--var name=value] [--timeout 30s] [file ...]")
+	vars := variablesFlag(flags)
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of each request")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	inputs, err := e.readInputs(files)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client := &http.Client{Timeout: *timeout}
+
+	code := ExitOK
+	var all []runner.Result
+	for _, in := range inputs {
+		root, diags := parse(in)
+		if len(diags) > 0 {
+			for _, d := range diags {
+				fmt.Fprintln(e.stderr, d)
+			}
+			code = max(code, ExitFailure)
+			continue
+		}
+
+		r := runner.New(runner.Client(client), runner.Variables(vars))
+		results, err := r.Run(ctx, root)
+		all = append(all, results...)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "%s: %v\n", in.Name, err)
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				code = ExitError
+			} else {
+				code = max(code, ExitFailure)
+			}
+		}
+	}
+
+	e.writeJSON(har.Export(all))
+	return code
+}
+
+// exportHTTPCmd prints the nuggets as .http files, which define the variables
+// given by --var, or defined by a .http input. The responses are left out.
+func exportHTTPCmd(e *env, args []string) int {
+	flags := e.newFlags("export http", "[--var name=value] [file ...]")
+	vars := variablesFlag(flags)
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	inputs, err := e.readInputs(files)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+
+	code := ExitOK
+	printed := false
+	for _, in := range inputs {
+		root, diags := parse(in)
+		if len(diags) > 0 {
+			for _, d := range diags {
+				fmt.Fprintln(e.stderr, d)
+			}
+			code = ExitFailure
+			continue
+		}
+
+		fileVars, err := fileVariables(root.RootValue, vars)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "%s: %v\n", in.Name, err)
+			code = ExitFailure
+			continue
+		}
+
+		if printed {
+			fmt.Fprint(e.stdout, "\n###\n")
+		}
+		fmt.Fprint(e.stdout, httpfile.Export(root.RootValue, fileVars))
+		printed = true
+	}
+	return code
+}
+
+// fileVariables returns the variables given by --var, with the ones defined
+// by the `@name = value` lines of a .http file
+func fileVariables(nugget *ast.Nugget, vars map[string]string) (map[string]string, error) {
+	fileVars := make(map[string]string, len(vars))
+	for name, value := range vars {
+		fileVars[name] = value
+	}
+	if err := resolver.Define(nugget.Variables, fileVars); err != nil {
+		return nil, err
+	}
+	return fileVars, nil
+}
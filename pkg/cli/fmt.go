@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"nug/pkg/format"
+)
+
+// fmtCmd formats the nuggets, and the .http files in their dialect. The
+// formatted source is printed, unless -w writes it back to the files, -d
+// prints the diff or -l lists the files whose formatting differs.
+func fmtCmd(e *env, args []string) int {
+	flags := e.newFlags("fmt", "[-l] [-w] [-d] [file ...]")
+	list := flags.Bool("l", false, "list the files whose formatting differs, and fail if any")
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	diff := flags.Bool("d", false, "print the diff instead of the formatted source")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	inputs, err := e.readInputs(files)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+
+	code := ExitOK
+	for _, in := range inputs {
+		if *write && in.Name == "-" {
+			fmt.Fprintln(e.stderr, "nug: cannot use -w with stdin")
+			return ExitUsage
+		}
+
+		source := format.Source
+		if isHTTPFile(in.Name) {
+			source = format.HTTPFileSource
+		}
+		formatted, err := source(in.Src)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "%s: %v\n", in.Name, err)
+			code = max(code, ExitFailure)
+			continue
+		}
+		changed := !bytes.Equal(in.Src, formatted)
+
+		if *list && changed {
+			fmt.Fprintln(e.stdout, in.Name)
+			code = max(code, ExitFailure)
+		}
+		if *diff {
+			e.stdout.Write(format.Diff(in.Name, in.Src, formatted))
+		}
+		if *write && changed {
+			if err := os.WriteFile(in.Name, formatted, 0644); err != nil {
+				fmt.Fprintf(e.stderr, "nug: %v\n", err)
+				code = ExitError
+			}
+		}
+		if !*list && !*diff && !*write {
+			e.stdout.Write(formatted)
+		}
+	}
+	return code
+}
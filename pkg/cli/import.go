@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/curl"
+	"nug/pkg/format"
+	"nug/pkg/har"
+	"nug/pkg/httpfile"
+	"nug/pkg/openapi"
+	"nug/pkg/postman"
+)
+
+// importers convert the source of a format to a nugget. The nugget holds what
+// could be converted even when an error is returned.
+var importers = map[string]func(src []byte) (*ast.Nugget, error){
+	"curl":    func(src []byte) (*ast.Nugget, error) { return curl.Import(string(src)) },
+	"har":     har.Import,
+	"http":    httpfile.Import,
+	"openapi": openapi.Import,
+	"postman": func(src []byte) (*ast.Nugget, error) { return postman.Import(src) },
+}
+
+// importCmd converts files of another format to nugget source, printed to
+// stdout. What cannot be converted is reported on stderr. Postman collections
+// can be given the variables of an environment with --environment.
+func importCmd(e *env, args []string) int {
+	if len(args) == 0 || importers[args[0]] == nil {
+		names := make([]string, 0, len(importers))
+		for name := range importers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(e.stderr, "usage: nug import <%s> [file ...]\n", strings.Join(names, "|"))
+		return ExitUsage
+	}
+	convert := importers[args[0]]
+
+	flags := e.newFlags("import "+args[0], "[file ...]")
+	var environment *string
+	if args[0] == "postman" {
+		flags = e.newFlags("import postman", "[--environment file] [file ...]")
+		environment = flags.String("environment", "", "Postman environment whose variables override the ones of the collection")
+	}
+	files, ok := parseFlags(flags, args[1:])
+	if !ok {
+		return ExitUsage
+	}
+	if environment != nil && *environment != "" {
+		data, err := os.ReadFile(*environment)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "nug: %v\n", err)
+			return ExitError
+		}
+		convert = func(src []byte) (*ast.Nugget, error) { return postman.Import(src, postman.Environment(data)) }
+	}
+
+	inputs, err := e.readInputs(files)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+
+	code := ExitOK
+	printed := false
+	for _, in := range inputs {
+		nugget, err := convert(in.Src)
+		if err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintf(e.stderr, "%s: %s\n", in.Name, line)
+			}
+			code = ExitFailure
+		}
+		if nugget == nil || len(nugget.Entries) == 0 {
+			continue
+		}
+		if printed {
+			fmt.Fprintln(e.stdout)
+		}
+		fmt.Fprint(e.stdout, format.Nugget(nugget))
+		printed = true
+	}
+	return code
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"fmt"
+
+	"nug/pkg/lsp"
+)
+
+// lspCmd runs the language server on stdin and stdout, until the client
+// exits
+func lspCmd(e *env, args []string) int {
+	flags := e.newFlags("lsp", "")
+	if files, ok := parseFlags(flags, args); !ok || len(files) > 0 {
+		return ExitUsage
+	}
+
+	if err := lsp.New(e.stdin, e.stdout).Serve(); err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+	return ExitOK
+}
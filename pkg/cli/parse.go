@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"nug/pkg/ast"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+)
+
+// diagnostic is an error found in a nugget, at a position of the file
+type diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s: line %d, column %d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// isHTTPFile reports whether a file is in the .http dialect, told by its
+// extension
+func isHTTPFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".http" || ext == ".rest"
+}
+
+// parse parses a nugget, or a .http file, and returns its syntax errors as
+// diagnostics
+func parse(in input, opts ...parser.Option) (ast.RootNode, []diagnostic) {
+	var lexerOpts []lexer.Option
+	if isHTTPFile(in.Name) {
+		lexerOpts = append(lexerOpts, lexer.HTTPFile())
+	}
+	p := parser.New(lexer.New(string(in.Src), lexerOpts...))
+	root, err := p.ParseProgram(opts...)
+	if err == nil {
+		return root, nil
+	}
+
+	var list parser.ErrorList
+	if !errors.As(err, &list) {
+		return root, []diagnostic{{File: in.Name, Message: err.Error()}}
+	}
+	diags := make([]diagnostic, len(list))
+	for i, e := range list {
+		diags[i] = diagnostic{File: in.Name, Line: e.Line, Column: e.Column, Message: e.Msg}
+	}
+	return root, diags
+}
+
+// parseCmd prints the AST of each nugget, as JSON or as a line per entry. The
+// JSON is an ast.Envelope, described by the schema printed by schemaCmd.
+func parseCmd(e *env, args []string) int {
+	flags := e.newFlags("parse", "[--output json|text] [file ...]")
+	out := outputFlag(flags)
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	inputs, err := e.readInputs(files)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+
+	code := ExitOK
+	for _, in := range inputs {
+		root, diags := parse(in)
+		if len(diags) > 0 {
+			for _, d := range diags {
+				fmt.Fprintln(e.stderr, d)
+			}
+			code = ExitFailure
+			continue
+		}
+
+		if *out == "json" {
+			e.writeJSON(ast.Envelope{Version: ast.Version, Nugget: root.RootValue})
+			continue
+		}
+		for _, entry := range root.RootValue.Entries {
+			fmt.Fprintf(e.stdout, "%s: line %d: %s %s", in.Name, entry.Req.StartPos.Line, entry.Req.Line.Method, entry.Req.Line.Url)
+			if res := entry.Res; res.Version != "" {
+				status := res.StatusPattern
+				if status == "" {
+					status = fmt.Sprint(res.Status)
+				}
+				fmt.Fprintf(e.stdout, " -> %s %s", res.Version, status)
+			}
+			fmt.Fprintln(e.stdout)
+		}
+	}
+	return code
+}
+
+// schemaCmd prints the JSON Schema of the AST written by parseCmd
+func schemaCmd(e *env, args []string) int {
+	flags := e.newFlags("schema", "")
+	if files, ok := parseFlags(flags, args); !ok || len(files) > 0 {
+		return ExitUsage
+	}
+	e.stdout.Write(ast.Schema)
+	return ExitOK
+}
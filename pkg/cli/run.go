@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"time"
+
+	"nug/pkg/runner"
+	"nug/pkg/verify"
+)
+
+// fileReport is what running a nugget gave, in JSON
+type fileReport struct {
+	File    string        `json:"file"`
+	Entries []entryReport `json:"entries"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type entryReport struct {
+	Entry    int           `json:"entry"`
+	Line     int           `json:"line"`
+	Method   string        `json:"method"`
+	URL      string        `json:"url"`
+	Status   int           `json:"status"`
+	Duration int64         `json:"duration_ms"`
+	Checks   []checkReport `json:"checks"`
+}
+
+type checkReport struct {
+	Type     string `json:"type"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Pass     bool   `json:"pass"`
+}
+
+// runCmd runs the nuggets one after the other and checks their responses.
+// Each nugget starts with the variables given by --var.
+func runCmd(e *env, args []string) int {
+	flags := e.newFlags("run", "[--output json|text] [--var name=value] [--timeout 30s] [file ...]")
+	out := outputFlag(flags)
+	vars := variablesFlag(flags)
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of each request")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return ExitUsage
+	}
+
+	inputs, err := e.readInputs(files)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "nug: %v\n", err)
+		return ExitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client := &http.Client{Timeout: *timeout}
+
+	code := ExitOK
+	reports := []fileReport{}
+	entries, failures := 0, 0
+	for _, in := range inputs {
+		root, diags := parse(in)
+		if len(diags) > 0 {
+			for _, d := range diags {
+				fmt.Fprintln(e.stderr, d)
+			}
+			code = max(code, ExitFailure)
+			continue
+		}
+
+		r := runner.New(runner.Client(client), runner.Variables(vars))
+		results, err := r.Run(ctx, root)
+
+		report := fileReport{File: in.Name, Entries: []entryReport{}}
+		for i, result := range results {
+			entry := entryReport{
+				Entry:    i + 1,
+				Line:     result.Entry.Req.StartPos.Line,
+				Method:   result.Request.Method,
+				URL:      result.Request.URL.String(),
+				Status:   result.Status,
+				Duration: result.Duration.Milliseconds(),
+				Checks:   []checkReport{},
+			}
+			if *out == "text" {
+				fmt.Fprintf(e.stdout, "%s: line %d: %s %s %d (%dms)\n",
+					in.Name, entry.Line, entry.Method, entry.URL, entry.Status, entry.Duration)
+			}
+
+			for _, check := range verify.Entry(result) {
+				entry.Checks = append(entry.Checks, checkReport(check))
+				if !check.Pass {
+					failures++
+					if *out == "text" {
+						fmt.Fprintf(e.stdout, "%s: %s\n", in.Name, check)
+					}
+				}
+			}
+			report.Entries = append(report.Entries, entry)
+		}
+		entries += len(results)
+
+		if err != nil {
+			report.Error = err.Error()
+			fmt.Fprintf(e.stderr, "%s: %v\n", in.Name, err)
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				code = ExitError
+			} else {
+				code = max(code, ExitFailure)
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	if failures > 0 {
+		code = max(code, ExitFailure)
+	}
+	if *out == "json" {
+		e.writeJSON(reports)
+	} else {
+		fmt.Fprintf(e.stdout, "%d %s run, %d %s failed\n",
+			entries, plural(entries, "entry", "entries"), failures, plural(failures, "check", "checks"))
+	}
+	return code
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,363 @@
+package curl
+
+// The curl package converts curl commands to nugget entries, such as the
+// ones copied from the developer tools of a browser or from the documentation
+// of an API, and nugget entries back to curl commands.
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/format"
+	"nug/pkg/token"
+)
+
+// Error is a part of a curl command that cannot be converted, such as an
+// unsupported option. Line is the 1-based line of the command it is on.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// ErrorList is the list of errors returned by Import. Use errors.As to get it
+// back from the returned error.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func errorf(line int, format string, args ...interface{}) *Error {
+	return &Error{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Import converts the curl commands of src, one entry per command. An option
+// that cannot be converted is reported in the returned ErrorList and left out
+// of its entry, and a command that cannot be converted at all, or whose entry
+// does not parse back from its source, is left out of the nugget. The entries
+// have no response, and are parsed back so their templates, bodies and
+// positions are filled in.
+func Import(src string) (*ast.Nugget, error) {
+	commands, err := split(src)
+	if err != nil {
+		return nil, ErrorList{err.(*Error)}
+	}
+
+	var errs ErrorList
+	var entries []ast.Entry
+	for _, words := range commands {
+		entry, cmdErrs := convert(words)
+		errs = append(errs, cmdErrs...)
+		if entry == nil {
+			continue
+		}
+		// an entry that would not parse back is left out, with the parser error
+		parsed, err := format.ReparseEntry(*entry)
+		if err != nil {
+			errs = append(errs, errorf(words[0].line, "cannot convert the request: %v", err))
+			continue
+		}
+		entries = append(entries, parsed)
+	}
+
+	nugget := &ast.Nugget{}
+	if len(entries) > 0 {
+		nugget.Entries = entries
+		// the positions become the ones in the source of all the entries
+		reparsed, err := format.Reparse(nugget)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the entries back: %v", err)
+		}
+		nugget = reparsed
+	}
+
+	if len(errs) > 0 {
+		return nugget, errs
+	}
+	return nugget, nil
+}
+
+// options maps the short options of curl to their long name
+var options = map[string]string{
+	"-A": "--user-agent",
+	"-b": "--cookie",
+	"-d": "--data",
+	"-e": "--referer",
+	"-G": "--get",
+	"-H": "--header",
+	"-I": "--head",
+	"-i": "--include",
+	"-s": "--silent",
+	"-S": "--show-error",
+	"-u": "--user",
+	"-v": "--verbose",
+	"-X": "--request",
+
+	// unsupported, listed for their value
+	"-c": "--cookie-jar",
+	"-E": "--cert",
+	"-F": "--form",
+	"-m": "--max-time",
+	"-o": "--output",
+	"-T": "--upload-file",
+	"-w": "--write-out",
+	"-x": "--proxy",
+}
+
+// withValue lists the long options followed by a value
+var withValue = map[string]bool{
+	"--cookie": true, "--data": true, "--data-ascii": true, "--data-binary": true,
+	"--data-raw": true, "--header": true, "--json": true, "--referer": true,
+	"--request": true, "--url": true, "--user": true, "--user-agent": true,
+
+	"--cacert": true, "--cert": true, "--connect-timeout": true, "--cookie-jar": true,
+	"--data-urlencode": true, "--form": true, "--key": true, "--max-time": true,
+	"--output": true, "--proxy": true, "--resolve": true, "--retry": true,
+	"--upload-file": true, "--write-out": true,
+}
+
+// quiet lists the options that change what curl prints but not the request.
+// `--compressed` is there too, the runner asks for and decodes gzip bodies on
+// its own.
+var quiet = map[string]bool{
+	"--compressed": true, "--include": true, "--no-progress-meter": true,
+	"--show-error": true, "--silent": true, "--verbose": true,
+}
+
+// command is what a curl command sends
+type command struct {
+	method  string
+	url     string
+	headers []ast.KeyValue
+	data    []string
+	json    bool // data given with --json
+	get     bool // data sent in the query string, with --get
+	head    bool
+}
+
+// convert converts the words of a command to an entry, or returns nil when
+// the command cannot be converted
+func convert(words []word) (*ast.Entry, ErrorList) {
+	line := words[0].line
+	if words[0].text != "curl" {
+		return nil, ErrorList{errorf(line, "expected a curl command, got: `%s`", words[0].text)}
+	}
+
+	var cmd command
+	var errs ErrorList
+	unsupported := func(w word, msg string) {
+		errs = append(errs, errorf(w.line, "%s", msg))
+	}
+
+	args := words[1:]
+	for len(args) > 0 {
+		w := args[0]
+		args = args[1:]
+
+		if w.text == "--" {
+			for _, url := range args {
+				cmd.setURL(url, unsupported)
+			}
+			break
+		}
+		if !strings.HasPrefix(w.text, "-") || w.text == "-" {
+			cmd.setURL(w, unsupported)
+			continue
+		}
+
+		name, value := w.text, ""
+		if !strings.HasPrefix(name, "--") {
+			name, args = expand(w, args)
+			if strings.HasPrefix(name, "-") && !strings.HasPrefix(name, "--") {
+				unsupported(w, fmt.Sprintf("unsupported option `%s`", name))
+				continue
+			}
+		}
+		if withValue[name] {
+			if len(args) == 0 {
+				return nil, append(errs, errorf(w.line, "expected a value after `%s`", name))
+			}
+			value = args[0].text
+			args = args[1:]
+		}
+
+		switch name {
+		case "--request":
+			cmd.method = value
+		case "--url":
+			cmd.setURL(word{text: value, line: w.line}, unsupported)
+		case "--header":
+			key, val, ok := strings.Cut(value, ":")
+			if !ok {
+				unsupported(w, fmt.Sprintf("expected a header as `Name: value`, got: `%s`", value))
+				continue
+			}
+			cmd.setHeader(strings.TrimSpace(key), strings.TrimSpace(val))
+		case "--data", "--data-ascii", "--data-binary", "--data-raw", "--json":
+			if name != "--data-raw" && strings.HasPrefix(value, "@") {
+				unsupported(w, fmt.Sprintf("unsupported data read from a file `%s`", value))
+				continue
+			}
+			cmd.data = append(cmd.data, value)
+			cmd.json = cmd.json || name == "--json"
+		case "--get":
+			cmd.get = true
+		case "--head":
+			cmd.head = true
+		case "--user":
+			if !strings.Contains(value, ":") {
+				unsupported(w, fmt.Sprintf("expected user:password, got: `%s`", value))
+				continue
+			}
+			cmd.setHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+		case "--user-agent":
+			cmd.setHeader("User-Agent", value)
+		case "--referer":
+			cmd.setHeader("Referer", value)
+		case "--cookie":
+			if !strings.Contains(value, "=") {
+				unsupported(w, fmt.Sprintf("unsupported cookies read from a file `%s`", value))
+				continue
+			}
+			cmd.setHeader("Cookie", value)
+		default:
+			if !quiet[name] {
+				unsupported(w, fmt.Sprintf("unsupported option `%s`", name))
+			}
+		}
+	}
+
+	entry, err := cmd.entry(line)
+	if err != nil {
+		return nil, append(errs, err)
+	}
+	return entry, errs
+}
+
+// expand returns the long name of the short option w. Short options can be
+// grouped, as in `-sS`, and the value of the last one can be attached to it,
+// as in `-XPOST`: the other options and the value are put back in front of
+// args. An unknown short option is returned as is.
+func expand(w word, args []word) (string, []word) {
+	name, ok := options[w.text[:2]]
+	if !ok {
+		return w.text[:2], args
+	}
+	rest := w.text[2:]
+	if rest == "" {
+		return name, args
+	}
+	if !withValue[name] {
+		rest = "-" + rest
+	}
+	return name, append([]word{{text: rest, line: w.line}}, args...)
+}
+
+func (c *command) setURL(w word, unsupported func(word, string)) {
+	if c.url != "" {
+		unsupported(w, fmt.Sprintf("unsupported second URL `%s`", w.text))
+		return
+	}
+	c.url = w.text
+}
+
+// setHeader sets a header, or replaces the value of the header with the same
+// name
+func (c *command) setHeader(key, value string) {
+	for i, header := range c.headers {
+		if strings.EqualFold(header.Key, key) {
+			c.headers[i].Value = value
+			return
+		}
+	}
+	c.headers = append(c.headers, ast.KeyValue{Type: "KeyValue", Key: key, Value: value})
+}
+
+func (c *command) hasHeader(key string) bool {
+	for _, header := range c.headers {
+		if strings.EqualFold(header.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// entry returns the entry sending the request of the command, the way curl
+// would send it
+func (c *command) entry(line int) (*ast.Entry, *Error) {
+	if c.url == "" {
+		return nil, errorf(line, "expected a URL")
+	}
+	if !strings.Contains(c.url, "://") {
+		c.url = "http://" + c.url // what curl does
+	}
+
+	var body string
+	if c.json {
+		body = strings.Join(c.data, "")
+	} else {
+		body = strings.Join(c.data, "&")
+	}
+
+	method := "GET"
+	switch {
+	case c.head:
+		method = "HEAD"
+	case len(c.data) > 0 && !c.get:
+		method = "POST"
+	}
+	if c.method != "" {
+		method = strings.ToUpper(c.method)
+	}
+	if !token.IsMethod(token.Type(method)) {
+		return nil, errorf(line, "unsupported method `%s`", c.method)
+	}
+
+	req := ast.Request{Type: "Request", Line: ast.Endpoint{Type: "Endpoint", Method: method, Url: c.url}}
+	if c.get && body != "" {
+		sep := "?"
+		if strings.Contains(c.url, "?") {
+			sep = "&"
+		}
+		req.Line.Url += sep + body
+		body = ""
+	}
+
+	if body != "" {
+		if trimmed := strings.TrimSpace(body); !json.Valid([]byte(trimmed)) || !strings.ContainsAny(trimmed[:1], "{[") {
+			return nil, errorf(line, "unsupported body, expected a JSON object or array, got: `%s`", firstLine(body))
+		}
+		if c.json {
+			if !c.hasHeader("Content-Type") {
+				c.setHeader("Content-Type", "application/json")
+			}
+			if !c.hasHeader("Accept") {
+				c.setHeader("Accept", "application/json")
+			}
+		} else if !c.hasHeader("Content-Type") {
+			c.setHeader("Content-Type", "application/x-www-form-urlencoded") // what curl sends with --data
+		}
+		req.Body = &ast.Body{Type: "Body", Raw: strings.TrimSpace(body)}
+	}
+	req.Header = c.headers
+
+	return &ast.Entry{Type: "Entry", Req: req}, nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i] + "..."
+	}
+	return s
+}
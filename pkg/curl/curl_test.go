@@ -0,0 +1,269 @@
+package curl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"nug/pkg/ast"
+	"nug/pkg/format"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+)
+
+func TestSplit(t *testing.T) {
+	tests := [...]struct {
+		input    string
+		expected [][]string
+	}{
+		{`curl https://test.com`, [][]string{{"curl", "https://test.com"}}},
+		{`curl 'a b' "c \"d\" \$e \x" f\ g`, [][]string{{"curl", "a b", `c "d" $e \x`, "f g"}}},
+		{"curl \\\n  -H 'A: b' \\\r\n  https://test.com", [][]string{{"curl", "-H", "A: b", "https://test.com"}}},
+		{`curl $'it\'s\n\x41é\101'`, [][]string{{"curl", "it's\nAé" + "A"}}},
+		{`curl "Bearer $TOKEN" ${host}/a $ $1`, [][]string{{"curl", "Bearer {{TOKEN}}", "{{host}}/a", "$", "$1"}}},
+		{"curl a; curl b && curl c\ncurl d # comment", [][]string{{"curl", "a"}, {"curl", "b"}, {"curl", "c"}, {"curl", "d"}}},
+		{"curl a | jq . | less\ncurl b", [][]string{{"curl", "a"}, {"curl", "b"}}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		commands, err := split(test.input)
+		if err != nil {
+			t.Fatalf("error: %q - %v", test.input, err)
+		}
+		var got [][]string
+		for _, command := range commands {
+			var words []string
+			for _, w := range command {
+				words = append(words, w.text)
+			}
+			got = append(got, words)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("error: %q - expected %q, got: %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{"curl 'abc", "line 1: unterminated string, expected `'`"},
+		{"curl \\\n\"abc", "line 2: unterminated string, expected `\"`"},
+		{"curl $(cat url)", "line 1: unsupported command substitution"},
+		{"curl ${host:-a}", "line 1: unsupported parameter expansion `${host:-a}`"},
+	}
+
+	for _, test := range tests {
+		_, err := split(test.input)
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("error: %q - expected %q, got: %v", test.input, test.err, err)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := [...]struct {
+		input    string
+		expected string
+	}{
+		{
+			"curl https://test.com/todos",
+			"GET https://test.com/todos\n",
+		},
+		{
+			`curl 'https://test.com/todos' \
+  -H 'Content-Type: application/json' \
+  -H "Authorization: Bearer $TOKEN" \
+  --data-raw '{"title": "write tests"}'`,
+			"POST https://test.com/todos\nContent-Type: application/json\nAuthorization: Bearer {{TOKEN}}\n{\"title\": \"write tests\"}\n",
+		},
+		{
+			`curl -XPUT --url test.com/todos/1 --json '[1, 2]' -sS --compressed`,
+			"PUT http://test.com/todos/1\nContent-Type: application/json\nAccept: application/json\n[1, 2]\n",
+		},
+		{
+			`curl -d '{"a": 1}' -u bob:secret -A nug -e https://test.com -b 'a=1; b=2' https://test.com`,
+			"POST https://test.com\nAuthorization: Basic Ym9iOnNlY3JldA==\nUser-Agent: nug\nReferer: https://test.com\nCookie: a=1; b=2\nContent-Type: application/x-www-form-urlencoded\n{\"a\": 1}\n",
+		},
+		{
+			`curl -G -d page=2 -d size=10 'https://test.com/todos?sort=id'`,
+			"GET https://test.com/todos?sort=id&page=2&size=10\n",
+		},
+		{
+			"curl -I https://test.com\ncurl -X delete https://test.com/1",
+			"HEAD https://test.com\n\nDELETE https://test.com/1\n",
+		},
+	}
+
+	for _, test := range tests {
+		nugget, err := Import(test.input)
+		if err != nil {
+			t.Fatalf("error: %q - %v", test.input, err)
+		}
+		if got := format.Nugget(nugget); got != test.expected {
+			t.Fatalf("error: %q - expected:\n%s\ngot:\n%s", test.input, test.expected, got)
+		}
+	}
+
+	nugget, _ := Import("curl -H 'Authorization: {{token}}' https://test.com/{{id}}")
+	req := nugget.Entries[0].Req
+	if req.Line.UrlTemplate == nil || req.Header[0].ValueTemplate == nil {
+		t.Fatalf("error: expected the templates to be parsed, got: %+v", req)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	input := `curl -k -L https://test.com/a
+curl https://test.com/b -F file=@a.txt --data @body.json
+wget https://test.com/c
+curl -d 'a=1' https://test.com/d
+curl -X PURGE https://test.com/e
+curl -H 'Accept' https://test.com/f https://test.com/g
+curl -u bob https://test.com/h -b cookies.txt -u
+curl -H 'X Y: z' https://test.com/i
+curl`
+
+	nugget, err := Import(input)
+	var errs ErrorList
+	if !errors.As(err, &errs) {
+		t.Fatalf("error: expected an ErrorList, got: %v", err)
+	}
+
+	expected := []string{
+		"line 1: unsupported option `-k`",
+		"line 1: unsupported option `-L`",
+		"line 2: unsupported option `--form`",
+		"line 2: unsupported data read from a file `@body.json`",
+		"line 3: expected a curl command, got: `wget`",
+		"line 4: unsupported body, expected a JSON object or array, got: `a=1`",
+		"line 5: unsupported method `PURGE`",
+		"line 6: expected a header as `Name: value`, got: `Accept`",
+		"line 6: unsupported second URL `https://test.com/g`",
+		"line 7: expected user:password, got: `bob`",
+		"line 7: unsupported cookies read from a file `cookies.txt`",
+		"line 7: expected a value after `--user`",
+		"line 8: cannot convert the request: line 2, column 3: expected `:`, got: `Y:`",
+		"line 9: expected a URL",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("error: expected %d errors, got: %v", len(expected), errs)
+	}
+	for i, e := range errs {
+		if e.Error() != expected[i] {
+			t.Fatalf("error: expected %q, got: %q", expected[i], e.Error())
+		}
+	}
+
+	got := format.Nugget(nugget)
+	if got != "GET https://test.com/a\n\nGET https://test.com/b\n\nGET https://test.com/f\n" {
+		t.Fatalf("error: unexpected entries:\n%s", got)
+	}
+
+	if _, err := Import("curl 'abc"); err == nil || err.Error() != "line 1: unterminated string, expected `'`" {
+		t.Fatalf("error: unexpected error %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	tests := [...]struct {
+		input    string
+		vars     map[string]string
+		expected string
+	}{
+		{
+			"GET https://test.com/todos",
+			nil,
+			"curl https://test.com/todos",
+		},
+		{
+			"POST https://test.com/todos?page=1&size=2\nAuthorization: Bearer {{token}}\nX-Note: it's {{api-key}}\n{\"title\": \"{{title}}\"}",
+			map[string]string{"title": "it's done"},
+			`curl 'https://test.com/todos?page=1&size=2' \
+  -H 'Authorization: Bearer '"${token}" \
+  -H 'X-Note: it'\''s '"${api_key}" \
+  -H 'Content-Type: application/json' \
+  --data-raw '{"title": "it'\''s done"}'`,
+		},
+		{
+			"put https://{{host}}/todos/1\nContent-Type: text/plain\n[1]",
+			nil,
+			`curl -X PUT https://"${host}"/todos/1 \
+  -H 'Content-Type: text/plain' \
+  --data-raw '[1]'`,
+		},
+		{
+			"POST https://test.com/todos\n{\"title\": \"{{title}}\", \"done\": {{done}}}",
+			map[string]string{"title": `say "hi"`},
+			`curl https://test.com/todos \
+  -H 'Content-Type: application/json' \
+  --data-raw '{"title": "say \"hi\"", "done": '"${done}"'}'`,
+		},
+		{
+			"HEAD https://test.com\n\nDELETE https://test.com/{{id}}",
+			map[string]string{"id": "42"},
+			"curl --head https://test.com\ncurl -X DELETE https://test.com/42",
+		},
+	}
+
+	for _, test := range tests {
+		nugget := parse(t, test.input)
+		var commands []string
+		for _, entry := range nugget.Entries {
+			commands = append(commands, Export(entry, test.vars))
+		}
+		if got := strings.Join(commands, "\n"); got != test.expected {
+			t.Fatalf("error: %q - expected:\n%s\ngot:\n%s", test.input, test.expected, got)
+		}
+	}
+}
+
+// TestExportImport checks that importing an exported command gives back the
+// same request, and that the shell reads the words that were written
+func TestExportImport(t *testing.T) {
+	input := `POST https://test.com/todos?q=a+b&x=$1
+Authorization: Bearer {{token}}
+Content-Type: application/json
+X-Quote: it's "ok" \ $HOME
+{"title": "a\nb", "tags": ["{{tag}}"]}
+
+DELETE https://test.com/todos/{{id}}
+
+GET https://test.com/hello
+Accept: */*`
+
+	nugget := parse(t, input)
+	var commands []string
+	for _, entry := range nugget.Entries {
+		commands = append(commands, Export(entry, nil))
+	}
+
+	imported, err := Import(strings.Join(commands, "\n"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if expected, got := format.Nugget(nugget), format.Nugget(imported); got != expected {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	words, err := split(Export(nugget.Entries[0], nil))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if words[0][7].text != `X-Quote: it's "ok" \ $HOME` {
+		t.Fatalf("error: unexpected word %q", words[0][7].text)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Nugget {
+	t.Helper()
+
+	root, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("error: %q - %v", input, err)
+	}
+	return root.RootValue
+}
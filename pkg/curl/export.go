@@ -0,0 +1,106 @@
+package curl
+
+import (
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/resolver"
+)
+
+// Export returns the curl command sending the request of entry, one option
+// per line. The references to the variables of vars are replaced by their
+// value, the others become shell variables, like `"${token}"`, for the shell
+// to expand. A name that is not a valid shell name has its invalid characters
+// replaced by `_`.
+func Export(entry ast.Entry, vars map[string]string) string {
+	req := entry.Req
+	command := "curl"
+
+	switch {
+	case req.Line.Method == "HEAD":
+		command += " --head"
+	case req.Line.Method == "POST" && req.Body != nil:
+		// implied by the body
+	case req.Line.Method != "GET" || req.Body != nil:
+		command += " -X " + req.Line.Method
+	}
+	command += " " + shellWord("", req.Line.UrlTemplate, req.Line.Url, vars)
+
+	var args []string
+
+	hasContentType := false
+	for _, header := range req.Header {
+		hasContentType = hasContentType || strings.EqualFold(header.Key, "Content-Type")
+		args = append(args, "-H "+shellWord(header.Key+": ", header.ValueTemplate, header.Value, vars))
+	}
+
+	if req.Body != nil {
+		if !hasContentType {
+			args = append(args, "-H "+quote("Content-Type: application/json")) // what the runner sends
+		}
+		tmpl := req.Body.Template
+		if tmpl != nil {
+			tmpl = resolver.BodyTemplate(tmpl, vars)
+		}
+		args = append(args, "--data-raw "+shellWord("", tmpl, req.Body.Raw, vars))
+	}
+
+	for _, arg := range args {
+		command += " \\\n  " + arg
+	}
+	return command
+}
+
+// shellWord returns the shell word of prefix followed by text, or by the
+// template of text when it has one
+func shellWord(prefix string, tmpl *ast.Template, text string, vars map[string]string) string {
+	if tmpl == nil {
+		return quote(prefix + text)
+	}
+
+	var word strings.Builder
+	literal := prefix
+	for _, part := range tmpl.Parts {
+		if part.Type != "Variable" {
+			literal += part.Value
+			continue
+		}
+		if value, ok := vars[part.Value]; ok {
+			literal += value
+			continue
+		}
+		if literal != "" {
+			word.WriteString(quote(literal))
+			literal = ""
+		}
+		word.WriteString(`"${` + shellName(part.Value) + `}"`)
+	}
+	if literal != "" || word.Len() == 0 {
+		word.WriteString(quote(literal))
+	}
+	return word.String()
+}
+
+// quote returns s as a shell word, single quoted unless it is made of safe
+// characters only
+func quote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellName returns name with the characters a shell variable name cannot
+// have replaced by `_`
+func shellName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !isNameChar(c, false) {
+			b[i] = '_'
+		}
+	}
+	if !isNameChar(b[0], true) {
+		return "_" + string(b)
+	}
+	return string(b)
+}
@@ -0,0 +1,301 @@
+package curl
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// word is a shell word, with its quotes removed and its shell variables
+// turned into `{{name}}` references, and the line it starts on
+type word struct {
+	text string
+	line int
+}
+
+// split splits src into commands, and each command into words, the way a
+// POSIX shell does: words are separated by blanks, quoted with `'`, `"` or
+// `$'`, lines are continued by a trailing `\`, and commands end at a new
+// line, `;`, `&`, `&&` or `||`. The commands piped into after a `|` are left
+// out, they only see what curl prints.
+func split(src string) ([][]word, error) {
+	s := &splitter{src: src, line: 1}
+	return s.commands()
+}
+
+type splitter struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (s *splitter) commands() ([][]word, error) {
+	var commands [][]word
+	var command []word
+	piped := false
+
+	end := func() {
+		if len(command) > 0 && !piped {
+			commands = append(commands, command)
+		}
+		command = nil
+		piped = false
+	}
+
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			s.pos++
+		case c == '\\' && strings.HasPrefix(s.src[s.pos+1:], "\n"):
+			s.pos += 2
+			s.line++
+		case c == '\\' && strings.HasPrefix(s.src[s.pos+1:], "\r\n"):
+			s.pos += 3
+			s.line++
+		case c == '\n':
+			end()
+			s.pos++
+			s.line++
+		case strings.HasPrefix(s.src[s.pos:], "&&") || strings.HasPrefix(s.src[s.pos:], "||"):
+			end()
+			s.pos += 2
+		case c == ';' || c == '&':
+			end()
+			s.pos++
+		case c == '|':
+			if len(command) > 0 && !piped {
+				commands = append(commands, command)
+			}
+			command = nil
+			piped = true
+			s.pos++
+		case c == '#':
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos++
+			}
+		default:
+			w, err := s.word()
+			if err != nil {
+				return nil, err
+			}
+			command = append(command, w)
+		}
+	}
+	end()
+	return commands, nil
+}
+
+// word reads the word starting at the current position
+func (s *splitter) word() (word, error) {
+	w := word{line: s.line}
+	var b strings.Builder
+
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case strings.IndexByte(" \t\r\n;&|", c) != -1:
+			w.text = b.String()
+			return w, nil
+		case c == '\\':
+			s.pos++
+			if s.pos == len(s.src) {
+				break
+			}
+			if s.src[s.pos] == '\n' {
+				s.line++
+			} else {
+				b.WriteByte(s.src[s.pos])
+			}
+			s.pos++
+		case c == '\'':
+			end := strings.IndexByte(s.src[s.pos+1:], '\'')
+			if end == -1 {
+				return w, s.errorf("unterminated string, expected `'`")
+			}
+			text := s.src[s.pos+1 : s.pos+1+end]
+			b.WriteString(text)
+			s.line += strings.Count(text, "\n")
+			s.pos += end + 2
+		case c == '"':
+			if err := s.doubleQuoted(&b); err != nil {
+				return w, err
+			}
+		case strings.HasPrefix(s.src[s.pos:], "$'"):
+			if err := s.ansiQuoted(&b); err != nil {
+				return w, err
+			}
+		case c == '$':
+			if err := s.variable(&b); err != nil {
+				return w, err
+			}
+		default:
+			b.WriteByte(c)
+			s.pos++
+		}
+	}
+	w.text = b.String()
+	return w, nil
+}
+
+// doubleQuoted reads a `"` string, in which `\` only escapes `$`, `"`, `\`,
+// the backquote and new lines
+func (s *splitter) doubleQuoted(b *strings.Builder) error {
+	s.pos++
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '"':
+			s.pos++
+			return nil
+		case c == '\\' && s.pos+1 < len(s.src) && strings.IndexByte("$`\"\\\n", s.src[s.pos+1]) != -1:
+			if s.src[s.pos+1] == '\n' {
+				s.line++
+			} else {
+				b.WriteByte(s.src[s.pos+1])
+			}
+			s.pos += 2
+		case c == '$':
+			if err := s.variable(b); err != nil {
+				return err
+			}
+		case c == '`':
+			return s.errorf("unsupported command substitution")
+		default:
+			if c == '\n' {
+				s.line++
+			}
+			b.WriteByte(c)
+			s.pos++
+		}
+	}
+	return s.errorf("unterminated string, expected `\"`")
+}
+
+// ansiQuoted reads a `$'` string, in which `\` starts a C escape sequence
+func (s *splitter) ansiQuoted(b *strings.Builder) error {
+	s.pos += 2
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		if c == '\'' {
+			s.pos++
+			return nil
+		}
+		if c != '\\' || s.pos+1 == len(s.src) {
+			if c == '\n' {
+				s.line++
+			}
+			b.WriteByte(c)
+			s.pos++
+			continue
+		}
+
+		s.pos++
+		esc := s.src[s.pos]
+		s.pos++
+		switch esc {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'e', 'E':
+			b.WriteByte(0x1b)
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			b.WriteByte(byte(s.number(16, 2)))
+		case 'u':
+			b.WriteRune(rune(s.number(16, 4)))
+		case 'U':
+			b.WriteRune(rune(s.number(16, 8)))
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			s.pos--
+			b.WriteByte(byte(s.number(8, 3)))
+		default: // `\\`, `\'`, `\"` and `\?`, or a backslash kept as is
+			if strings.IndexByte("\\'\"?", esc) == -1 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(esc)
+		}
+	}
+	return s.errorf("unterminated string, expected `'`")
+}
+
+// number reads up to n digits in base and returns their value
+func (s *splitter) number(base, n int) uint64 {
+	end := s.pos
+	for end < len(s.src) && end-s.pos < n && strings.IndexByte("0123456789abcdefABCDEF"[:base+max(base-10, 0)], s.src[end]) != -1 {
+		end++
+	}
+	v, _ := strconv.ParseUint(s.src[s.pos:end], base, 32)
+	s.pos = end
+	if base != 8 && !utf8.ValidRune(rune(v)) {
+		return utf8.RuneError
+	}
+	return v
+}
+
+// variable reads a `$name` or `${name}` shell variable, which becomes a
+// `{{name}}` reference. A `$` followed by anything else is kept as is.
+func (s *splitter) variable(b *strings.Builder) error {
+	rest := s.src[s.pos+1:]
+	if strings.HasPrefix(rest, "(") {
+		return s.errorf("unsupported command substitution")
+	}
+
+	name, braces := rest, false
+	if strings.HasPrefix(rest, "{") {
+		end := strings.IndexByte(rest, '}')
+		if end == -1 {
+			return s.errorf("unterminated variable, expected `}`")
+		}
+		name, braces = rest[1:end], true
+	} else {
+		end := 0
+		for end < len(rest) && isNameChar(rest[end], end == 0) {
+			end++
+		}
+		name = rest[:end]
+	}
+
+	if name == "" || !isName(name) {
+		if braces {
+			return s.errorf("unsupported parameter expansion `${%s}`", name)
+		}
+		b.WriteByte('$')
+		s.pos++
+		return nil
+	}
+
+	b.WriteString("{{" + name + "}}")
+	s.pos += 1 + len(name)
+	if braces {
+		s.pos += 2
+	}
+	return nil
+}
+
+func isName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+func (s *splitter) errorf(format string, args ...interface{}) error {
+	return errorf(s.line, format, args...)
+}
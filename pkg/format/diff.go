@@ -0,0 +1,148 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change
+const context = 3
+
+// Diff returns the unified diff turning old into new, with name as the file
+// name of both sides, or nil when they are the same
+func Diff(name string, old, new []byte) []byte {
+	a, b := lines(string(old)), lines(string(new))
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		// skip to the next change, then back to the context before it
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// a hunk ends on more than twice the context of unchanged lines
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			same := end
+			for same < len(ops) && ops[same].kind == ' ' {
+				same++
+			}
+			if same == len(ops) || same-end > 2*context {
+				end += min(context, same-end)
+				break
+			}
+			end = same
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+		}
+		writeHunk(&out, ops[start:end])
+		i = end
+	}
+
+	if out.Len() == 0 {
+		return nil
+	}
+	return []byte(out.String())
+}
+
+// op is a line of a diff, kind is ' ' for an unchanged line, '-' for a
+// removed one or '+' for an added one. text keeps its new line, if any, and
+// oldLine and newLine are the 1-based numbers the line would have in each
+// side.
+type op struct {
+	kind             byte
+	text             string
+	oldLine, newLine int
+}
+
+func writeHunk(out *strings.Builder, ops []op) {
+	var oldCount, newCount int
+	for _, o := range ops {
+		if o.kind != '+' {
+			oldCount++
+		}
+		if o.kind != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ops[0].oldLine, oldCount), hunkRange(ops[0].newLine, newCount))
+	for _, o := range ops {
+		fmt.Fprintf(out, "%c%s", o.kind, o.text)
+		// the last line of a side without a new line, marked as by diff(1)
+		if !strings.HasSuffix(o.text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange returns the `line,count` of a hunk side, where an empty side is
+// given the line before it
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line--
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// diffLines returns the shortest edit script from a to b, computed on their
+// longest common subsequence
+func diffLines(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i], i + 1, j + 1})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j], i + 1, j + 1})
+			j++
+		}
+	}
+	return ops
+}
+
+// lines splits s in lines, each with its new line, so that a missing one at
+// the end of s is a change too
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	l := strings.SplitAfter(s, "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
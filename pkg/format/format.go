@@ -0,0 +1,281 @@
+package format
+
+// The format package prints a nugget back to its canonical source: one blank
+// line between entries, no blank line inside them, `Key: value` headers,
+// upper-cased methods, the `[Capture]` section before `[Asserts]`, and the
+// comments kept next to the lines they were attached to. Request bodies are
+// printed as written, they are what gets sent to the server.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+)
+
+// Source parses src and returns it formatted. Formatting the result again
+// returns it unchanged.
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	root, err := p.ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(Nugget(root.RootValue)), nil
+}
+
+// Reparse returns the nugget parsed back from its canonical source. The
+// converters build their entries by hand, reparsing fills in their templates,
+// bodies and positions.
+func Reparse(nugget *ast.Nugget) (*ast.Nugget, error) {
+	root, err := parser.New(lexer.New(Nugget(nugget))).ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+	return root.RootValue, nil
+}
+
+// ReparseEntry returns the entry parsed back from its canonical source, like
+// Reparse. The source must read back as this one entry.
+func ReparseEntry(entry ast.Entry) (ast.Entry, error) {
+	nugget, err := Reparse(&ast.Nugget{Entries: []ast.Entry{entry}})
+	if err != nil {
+		return ast.Entry{}, err
+	}
+	if n := len(nugget.Entries); n != 1 {
+		return ast.Entry{}, fmt.Errorf("expected 1 entry, got: %d", n)
+	}
+	return nugget.Entries[0], nil
+}
+
+// Nugget returns the canonical source of a nugget
+func Nugget(nugget *ast.Nugget) string {
+	var f formatter
+	for i, entry := range nugget.Entries {
+		if i > 0 {
+			f.b.WriteString("\n")
+		}
+		f.entry(entry)
+	}
+	for _, c := range nugget.Comments {
+		f.line(c.Text, nil)
+	}
+	return f.b.String()
+}
+
+type formatter struct {
+	b        bytes.Buffer
+	httpFile bool // write the .http dialect, see HTTPFile
+}
+
+// line writes a line of source, followed by its trailing comment if any
+func (f *formatter) line(text string, comment *ast.Comment) {
+	f.b.WriteString(text)
+	if comment != nil {
+		if text != "" {
+			f.b.WriteString(" ")
+		}
+		f.b.WriteString(comment.Text)
+	}
+	f.b.WriteString("\n")
+}
+
+// comments writes each comment on a line of its own
+func (f *formatter) comments(comments []ast.Comment) {
+	for _, c := range comments {
+		f.line(c.Text, nil)
+	}
+}
+
+func (f *formatter) entry(entry ast.Entry) {
+	f.comments(entry.Comments)
+	f.request(entry.Req)
+	if entry.Res.Version != "" {
+		f.response(entry.Res)
+	}
+}
+
+// request writes the request line, the headers and the body. The inline
+// comments of a request trail its line, the others come before the body.
+func (f *formatter) request(req ast.Request) {
+	trailing, before := splitInline(req.Comments)
+
+	f.line(req.Line.Method+" "+url(req.Line), first(trailing))
+	f.comments(rest(trailing))
+
+	for _, header := range req.Header {
+		trailing, leading := splitInline(header.Comments)
+		f.comments(leading)
+
+		line := header.Key + ":"
+		if header.Value != "" {
+			line += " " + header.Value
+		}
+		f.line(line, first(trailing))
+		f.comments(rest(trailing))
+	}
+
+	f.comments(before)
+	if req.Body != nil {
+		if f.httpFile {
+			f.b.WriteString("\n")
+		}
+		f.line(req.Body.Raw, nil)
+	}
+}
+
+// response writes the status line and the sections. Its comments come before
+// the status line, trail it, or trail the header of a section, which is told
+// by their line.
+func (f *formatter) response(res ast.Response) {
+	statusLine := res.StartPos.Line - 1 // comment lines are 0-based
+
+	var before, status []ast.Comment
+	var sections = map[string][]ast.Comment{}
+	for _, c := range res.Comments {
+		switch {
+		case c.Line < statusLine || !c.Inline:
+			before = append(before, c)
+		case c.Line == statusLine:
+			status = append(status, c)
+		default:
+			section := sectionOf(res, c.Line)
+			sections[section] = append(sections[section], c)
+		}
+	}
+	status = append(status, sections[""]...)
+
+	f.comments(before)
+	s := res.StatusPattern
+	if s == "" {
+		s = strconv.Itoa(res.Status)
+	}
+	f.line(res.Version+" "+s, first(status))
+	f.comments(rest(status))
+
+	if len(res.Capture) > 0 {
+		f.line("[Capture]", first(sections["Capture"]))
+		f.comments(rest(sections["Capture"]))
+		for _, capture := range res.Capture {
+			trailing, leading := splitInline(capture.Comments)
+			f.comments(leading)
+			f.line(capture.Name+": "+query(capture.Query), first(trailing))
+			f.comments(rest(trailing))
+		}
+	}
+
+	if len(res.Asserts) > 0 {
+		f.line("[Asserts]", first(sections["Asserts"]))
+		f.comments(rest(sections["Asserts"]))
+		for _, assert := range res.Asserts {
+			trailing, leading := splitInline(assert.Comments)
+			f.comments(leading)
+			f.line(query(assert.Query)+" "+predicate(assert.Predicate), first(trailing))
+			f.comments(rest(trailing))
+		}
+	}
+}
+
+// sectionOf returns the section whose header is on line, the section starting
+// right after it, or "" when no section is left to hold it
+func sectionOf(res ast.Response, line int) string {
+	section, start := "", -1
+	check := func(name string, pos int) {
+		if pos > line && (start == -1 || pos < start) {
+			section, start = name, pos
+		}
+	}
+	if len(res.Capture) > 0 {
+		check("Capture", res.Capture[0].StartPos.Line-1)
+	}
+	if len(res.Asserts) > 0 {
+		check("Asserts", res.Asserts[0].StartPos.Line-1)
+	}
+	return section
+}
+
+func query(q ast.Query) string {
+	if q.Arg == "" {
+		return q.Kind
+	}
+	return q.Kind + " " + quote(q.Arg)
+}
+
+func predicate(p ast.Predicate) string {
+	if p.Value == nil {
+		return p.Op
+	}
+	switch v := p.Value.Value.(type) {
+	case string:
+		return p.Op + " " + quote(v)
+	case nil:
+		return p.Op + " null"
+	default:
+		b, _ := json.Marshal(v)
+		return p.Op + " " + string(b)
+	}
+}
+
+// urlChars are the chars of a url that needs no quotes, besides its
+// `{{name}}` references
+const urlChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789;/?:@&=+$,#-_.!~*'()[]"
+
+// url returns the url of a request line, quoted when it holds chars the lexer
+// would not read as part of it
+func url(line ast.Endpoint) string {
+	text := line.Url
+	if line.UrlTemplate != nil {
+		text = ""
+		for _, part := range line.UrlTemplate.Parts {
+			if part.Type == "Text" {
+				text += part.Value
+			}
+		}
+	}
+	for _, char := range text {
+		if !strings.ContainsRune(urlChars, char) {
+			return quote(line.Url)
+		}
+	}
+	return line.Url
+}
+
+// quote returns s as a double quoted string, with JSON escape sequences
+func quote(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// splitInline returns the inline comments and the others, in order
+func splitInline(comments []ast.Comment) (inline, other []ast.Comment) {
+	for _, c := range comments {
+		if c.Inline {
+			inline = append(inline, c)
+		} else {
+			other = append(other, c)
+		}
+	}
+	return inline, other
+}
+
+func first(comments []ast.Comment) *ast.Comment {
+	if len(comments) == 0 {
+		return nil
+	}
+	return &comments[0]
+}
+
+func rest(comments []ast.Comment) []ast.Comment {
+	if len(comments) == 0 {
+		return nil
+	}
+	return comments[1:]
+}
@@ -0,0 +1,256 @@
+package format
+
+import (
+	"testing"
+
+	"nug/pkg/ast"
+)
+
+func TestSource(t *testing.T) {
+	tests := [...]struct {
+		input    string
+		expected string
+	}{
+		{
+			input: `
+
+	get   https://test.com/todos   # list
+cache-control :   no-cache
+   HTTP   200
+post https://test.com/todos
+Content-Type:application/json
+{"title": "write",
+  "done": false}
+HTTP/2 2xx`,
+			expected: `GET https://test.com/todos # list
+cache-control: no-cache
+HTTP 200
+
+POST https://test.com/todos
+Content-Type: application/json
+{"title": "write",
+  "done": false}
+HTTP/2 2xx
+`,
+		},
+		{
+			input: `# login first
+POST https://test.com/login
+# the credentials
+Authorization: Basic {{credentials}}
+User-Agent: " padded "
+X-Note: "a # b"
+
+HTTP 200 # logged in
+[Asserts] # checks
+status == 200
+jsonpath "$.user.admin" == false # not an admin
+header "X-Id" exists
+# the token
+[Capture]
+token: $.token
+id: header "X-Id"
+
+
+GET "https://test.com/a b"
+HTTP *
+# end of file`,
+			expected: `# login first
+POST https://test.com/login
+# the credentials
+Authorization: Basic {{credentials}}
+User-Agent: " padded "
+X-Note: "a # b"
+HTTP 200 # logged in
+[Capture]
+# the token
+token: jsonpath "$.token"
+id: header "X-Id"
+[Asserts] # checks
+status == 200
+jsonpath "$.user.admin" == false # not an admin
+header "X-Id" exists
+
+GET "https://test.com/a b"
+HTTP *
+# end of file
+`,
+		},
+		{
+			input: `GET https://test.com/{{path}}
+X-Empty:
+HTTP 200
+[Asserts]
+jsonpath "$.name" startsWith "a\"b"
+jsonpath "$.total" >= 1.5
+body contains "<html>"
+jsonpath "$.id" != null`,
+			expected: `GET https://test.com/{{path}}
+X-Empty:
+HTTP 200
+[Asserts]
+jsonpath "$.name" startsWith "a\"b"
+jsonpath "$.total" >= 1.5
+body contains "<html>"
+jsonpath "$.id" != null
+`,
+		},
+	}
+
+	for _, test := range tests {
+		formatted, err := Source([]byte(test.input))
+		if err != nil {
+			t.Fatalf("error formatting %q: %v", test.input, err)
+		}
+		if string(formatted) != test.expected {
+			t.Fatalf("error: expected:\n%s\ngot:\n%s", test.expected, formatted)
+		}
+
+		again, err := Source(formatted)
+		if err != nil {
+			t.Fatalf("error formatting %q: %v", formatted, err)
+		}
+		if string(again) != string(formatted) {
+			t.Fatalf("error: formatting is not idempotent, expected:\n%s\ngot:\n%s", formatted, again)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	_, err := Source([]byte("GET https://test.com\nHTTP abc"))
+	if err == nil || err.Error() != "line 2, column 6: expected status code, got: `abc`" {
+		t.Fatalf("error: unexpected error %v", err)
+	}
+}
+
+func TestReparse(t *testing.T) {
+	entry := ast.Entry{Type: "Entry", Req: ast.Request{
+		Type:   "Request",
+		Line:   ast.Endpoint{Type: "Endpoint", Method: "POST", Url: "https://{{host}}"},
+		Header: []ast.KeyValue{{Type: "KeyValue", Key: "X-Id", Value: "1"}},
+		Body:   &ast.Body{Type: "Body", Raw: `{"a": 1}`},
+	}}
+
+	parsed, err := ReparseEntry(entry)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if parsed.Req.Line.UrlTemplate == nil || parsed.Req.Body.Value == nil || parsed.Req.EndPos.Line != 3 {
+		t.Fatalf("error: expected the entry to be filled in, got: %+v", parsed.Req)
+	}
+
+	nugget, err := Reparse(&ast.Nugget{Entries: []ast.Entry{entry, entry}})
+	if err != nil || len(nugget.Entries) != 2 || nugget.Entries[1].Req.StartPos.Line != 5 {
+		t.Fatalf("error: unexpected nugget %+v, %v", nugget, err)
+	}
+
+	tests := []struct {
+		value string
+		err   string
+	}{
+		{"a\nb", "line 3, column 2: expected `:`, got: `\\n`"},
+		{"a\nGET https://test.com", "expected 1 entry, got: 2"},
+	}
+	for _, tt := range tests {
+		entry.Req.Body = nil
+		entry.Req.Header[0].Value = tt.value
+		if _, err := ReparseEntry(entry); err == nil || err.Error() != tt.err {
+			t.Fatalf("error: %q - expected %q, got: %v", tt.value, tt.err, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := [...]struct {
+		old, new string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\nb\nc\n", "a\nB\nc\n", `--- test.nug
++++ test.nug
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+`},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n", `--- test.nug
++++ test.nug
+@@ -1,3 +1,4 @@
++0
+ 1
+ 2
+ 3
+@@ -7,4 +8,3 @@
+ 7
+ 8
+ 9
+-10
+`},
+		{"", "a\n", `--- test.nug
++++ test.nug
+@@ -0,0 +1,1 @@
++a
+`},
+		{"a\nb", "a\nb\n", `--- test.nug
++++ test.nug
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`},
+	}
+
+	for _, test := range tests {
+		diff := Diff("test.nug", []byte(test.old), []byte(test.new))
+		if string(diff) != test.expected {
+			t.Fatalf("error: expected:\n%s\ngot:\n%s", test.expected, diff)
+		}
+	}
+}
+
+func TestHTTPFileSource(t *testing.T) {
+	input := `@host = https://test.com
+###
+@token  =   abc
+### list the todos
+get {{host}}/todos HTTP/1.1
+Accept:*/*
+POST {{host}}/todos
+// the payload
+Content-Type: application/json
+{"title": "a"}
+HTTP 201`
+	expected := `@host = https://test.com
+@token = abc
+
+### list the todos
+GET {{host}}/todos
+Accept: */*
+
+###
+POST {{host}}/todos
+// the payload
+Content-Type: application/json
+
+{"title": "a"}
+HTTP 201
+`
+
+	formatted, err := HTTPFileSource([]byte(input))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if string(formatted) != expected {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", expected, formatted)
+	}
+	again, err := HTTPFileSource(formatted)
+	if err != nil || string(again) != expected {
+		t.Fatalf("error: formatting is not idempotent, got:\n%s\n%v", again, err)
+	}
+
+	if _, err := HTTPFileSource([]byte("@host\nGET a")); err == nil {
+		t.Fatal("error: expected an error")
+	}
+}
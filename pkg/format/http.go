@@ -0,0 +1,47 @@
+package format
+
+import (
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+)
+
+// HTTPFileSource parses src in the .http dialect and returns it formatted
+func HTTPFileSource(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src), lexer.HTTPFile()))
+	root, err := p.ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(HTTPFile(root.RootValue)), nil
+}
+
+// HTTPFile returns the source of a nugget in the .http dialect: its variables
+// first, as `@name = value` lines, then its entries separated by `###` lines,
+// with a blank line before their body
+func HTTPFile(nugget *ast.Nugget) string {
+	f := formatter{httpFile: true}
+	for _, v := range nugget.Variables {
+		for _, c := range v.Comments {
+			// the separators are written before the entries
+			if strings.Trim(c.Text, "#") != "" {
+				f.line(c.Text, nil)
+			}
+		}
+		f.line("@"+v.Key+" = "+v.Value, nil)
+	}
+
+	for i, entry := range nugget.Entries {
+		if i > 0 || len(nugget.Variables) > 0 {
+			f.b.WriteString("\n")
+		}
+		if i > 0 && (len(entry.Comments) == 0 || !strings.HasPrefix(entry.Comments[0].Text, "###")) {
+			f.line("###", nil)
+		}
+		f.entry(entry)
+	}
+	f.comments(nugget.Comments)
+	return f.b.String()
+}
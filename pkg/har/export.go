@@ -0,0 +1,132 @@
+package har
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"nug/pkg/runner"
+)
+
+// Export returns the archive of the results of a run, with the requests as
+// they were sent and the responses as they were received
+func Export(results []runner.Result) *HAR {
+	archive := &HAR{Log: Log{
+		Version: "1.2",
+		Creator: Creator{Name: "nug", Version: "1.0"},
+		Entries: []Entry{},
+	}}
+	for _, result := range results {
+		archive.Log.Entries = append(archive.Log.Entries, entry(result))
+	}
+	return archive
+}
+
+func entry(result runner.Result) Entry {
+	wait := milliseconds(result.Wait)
+	duration := milliseconds(result.Duration)
+	return Entry{
+		StartedDateTime: result.Start.Format(time.RFC3339Nano),
+		Time:            duration,
+		Request:         request(result.Request, result.Proto),
+		Response:        response(result),
+		Timings: Timings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: -1,
+			Send:    0,
+			Wait:    wait,
+			Receive: duration - wait,
+			SSL:     -1,
+		},
+	}
+}
+
+func request(req *http.Request, proto string) Request {
+	r := Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: proto,
+		Cookies:     cookies(req.Cookies()),
+		Headers:     headers(req.Header),
+		QueryString: query(req.URL),
+		HeadersSize: -1,
+	}
+	if req.Host != "" {
+		r.Headers = append([]NameValue{{Name: "Host", Value: req.Host}}, r.Headers...)
+	}
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			text, _ := io.ReadAll(body)
+			r.PostData = &PostData{MimeType: req.Header.Get("Content-Type"), Text: string(text)}
+			r.BodySize = len(text)
+		}
+	}
+	return r
+}
+
+func response(result runner.Result) Response {
+	r := Response{
+		Status:      result.Status,
+		StatusText:  http.StatusText(result.Status),
+		HTTPVersion: result.Proto,
+		Cookies:     cookies((&http.Response{Header: result.Header}).Cookies()),
+		Headers:     headers(result.Header),
+		Content: Content{
+			Size:     len(result.Body),
+			MimeType: result.Header.Get("Content-Type"),
+		},
+		RedirectURL: result.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(result.Body),
+	}
+	if utf8.Valid(result.Body) {
+		r.Content.Text = string(result.Body)
+	} else {
+		r.Content.Text = base64.StdEncoding.EncodeToString(result.Body)
+		r.Content.Encoding = "base64"
+	}
+	return r
+}
+
+// headers returns the headers sorted by name, a header with several values
+// is repeated
+func headers(h http.Header) []NameValue {
+	list := []NameValue{}
+	for _, name := range sortedKeys(h) {
+		for _, value := range h[name] {
+			list = append(list, NameValue{Name: name, Value: value})
+		}
+	}
+	return list
+}
+
+func cookies(cookies []*http.Cookie) []NameValue {
+	list := []NameValue{}
+	for _, c := range cookies {
+		list = append(list, NameValue{Name: c.Name, Value: c.Value})
+	}
+	return list
+}
+
+func query(u *url.URL) []NameValue {
+	return headers(http.Header(u.Query()))
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,207 @@
+package har
+
+// The har package converts the entries of an HTTP Archive (HAR 1.2), such as
+// the ones saved by the developer tools of a browser, to nugget entries, and
+// the results of running a nugget back to an HTTP Archive. See
+// http://www.softwareishard.com/blog/har-12-spec/
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/format"
+	"nug/pkg/token"
+)
+
+// HAR is the root of an HTTP Archive
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+type Log struct {
+	Version string  `json:"version"` // "1.2"
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is an HTTP request and its response. Times are in milliseconds.
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"` // ISO 8601
+	Time            float64  `json:"time"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"` // -1 when unknown
+	BodySize    int         `json:"bodySize"`
+}
+
+type Response struct {
+	Status      int         `json:"status"` // 0 when no response was received
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// NameValue is a header, a cookie or a parameter of the query string
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // "base64" when Text is encoded
+}
+
+// Timings are the phases of a request, in milliseconds, -1 for the ones that
+// do not apply
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Error is an entry of the archive that cannot be converted, Entry is its
+// index in the log, from 1
+type Error struct {
+	Entry int
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("entry %d: %s", e.Entry, e.Msg)
+}
+
+// ErrorList is the list of errors returned by Import. Use errors.As to get it
+// back from the returned error.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Import converts the entries of an archive to nugget entries, sending the
+// same request and expecting the status recorded for its response. An entry
+// that cannot be converted, or that does not parse back from its source, is
+// reported in the returned ErrorList and left out of the nugget. The entries
+// are parsed back so their templates, bodies and positions are filled in.
+func Import(data []byte) (*ast.Nugget, error) {
+	var archive HAR
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %v", err)
+	}
+
+	var errs ErrorList
+	var entries []ast.Entry
+	for i, e := range archive.Log.Entries {
+		entry, err := convert(e)
+		if err != nil {
+			errs = append(errs, &Error{Entry: i + 1, Msg: err.Error()})
+			continue
+		}
+		// an entry that would not parse back is left out, with the parser error
+		parsed, err := format.ReparseEntry(entry)
+		if err != nil {
+			errs = append(errs, &Error{Entry: i + 1, Msg: fmt.Sprintf("cannot convert the request: %v", err)})
+			continue
+		}
+		entries = append(entries, parsed)
+	}
+
+	nugget := &ast.Nugget{}
+	if len(entries) > 0 {
+		nugget.Entries = entries
+		// the positions become the ones in the source of all the entries
+		reparsed, err := format.Reparse(nugget)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the entries back: %v", err)
+		}
+		nugget = reparsed
+	}
+
+	if len(errs) > 0 {
+		return nugget, errs
+	}
+	return nugget, nil
+}
+
+// skipped reports the headers left out of the entries: HTTP/2 pseudo headers
+// start with `:`, Content-Length and Accept-Encoding are set by the runner and
+// so is Host, unless it differs from the host of the url
+func skipped(header NameValue, rawURL string) bool {
+	if strings.EqualFold(header.Name, "Host") {
+		u, err := url.Parse(rawURL)
+		return err == nil && u.Host == header.Value
+	}
+	return strings.HasPrefix(header.Name, ":") ||
+		strings.EqualFold(header.Name, "Content-Length") ||
+		strings.EqualFold(header.Name, "Accept-Encoding")
+}
+
+func convert(e Entry) (ast.Entry, error) {
+	method := strings.ToUpper(e.Request.Method)
+	if !token.IsMethod(token.Type(method)) {
+		return ast.Entry{}, fmt.Errorf("unsupported method `%s`", e.Request.Method)
+	}
+
+	req := ast.Request{Type: "Request", Line: ast.Endpoint{Type: "Endpoint", Method: method, Url: e.Request.URL}}
+	for _, header := range e.Request.Headers {
+		if skipped(header, e.Request.URL) {
+			continue
+		}
+		req.Header = append(req.Header, ast.KeyValue{Type: "KeyValue", Key: header.Name, Value: header.Value})
+	}
+
+	if e.Request.PostData != nil && e.Request.PostData.Text != "" {
+		text := strings.TrimSpace(e.Request.PostData.Text)
+		if !json.Valid([]byte(text)) || !strings.ContainsAny(text[:1], "{[") {
+			return ast.Entry{}, fmt.Errorf("unsupported body of type `%s`, expected a JSON object or array", e.Request.PostData.MimeType)
+		}
+		req.Body = &ast.Body{Type: "Body", Raw: text}
+	}
+
+	entry := ast.Entry{Type: "Entry", Req: req}
+	if e.Response.Status > 0 {
+		entry.Res = ast.Response{Type: "Response", Version: "HTTP", Status: e.Response.Status}
+	}
+	return entry, nil
+}
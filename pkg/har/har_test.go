@@ -0,0 +1,188 @@
+package har
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nug/pkg/format"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+	"nug/pkg/runner"
+)
+
+const archive = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "WebInspector", "version": "537.36"},
+    "entries": [
+      {
+        "request": {
+          "method": "GET",
+          "url": "https://test.com/todos?q=a%20b",
+          "httpVersion": "http/2.0",
+          "headers": [
+            {"name": ":authority", "value": "test.com"},
+            {"name": "accept", "value": "application/json"},
+            {"name": "accept-encoding", "value": "gzip, deflate, br, zstd"},
+            {"name": "sec-ch-ua", "value": "\"Chromium\";v=\"122\""}
+          ]
+        },
+        "response": {"status": 200, "statusText": ""}
+      },
+      {
+        "request": {
+          "method": "post",
+          "url": "https://test.com/todos",
+          "headers": [
+            {"name": "Content-Type", "value": "application/json"},
+            {"name": "Content-Length", "value": "17"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "{\"title\": \"a\"}\n"}
+        },
+        "response": {"status": 201}
+      },
+      {
+        "request": {
+          "method": "POST",
+          "url": "https://test.com/login",
+          "headers": [],
+          "postData": {"mimeType": "application/x-www-form-urlencoded", "text": "user=bob"}
+        },
+        "response": {"status": 302}
+      },
+      {
+        "request": {"method": "PURGE", "url": "https://test.com/cache", "headers": []},
+        "response": {"status": 200}
+      },
+      {
+        "request": {"method": "GET", "url": "https://test.com/debug", "headers": [{"name": "X {debug}", "value": "1"}]},
+        "response": {"status": 200}
+      },
+      {
+        "request": {"method": "DELETE", "url": "https://test.com/todos/1", "headers": []},
+        "response": {"status": 0}
+      }
+    ]
+  }
+}`
+
+func TestImport(t *testing.T) {
+	nugget, err := Import([]byte(archive))
+
+	var errs ErrorList
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("error: expected 3 errors, got: %v", err)
+	}
+	expected := []string{
+		"entry 3: unsupported body of type `application/x-www-form-urlencoded`, expected a JSON object or array",
+		"entry 4: unsupported method `PURGE`",
+		"entry 5: cannot convert the request: line 2, column 3: expected `:`, got: `{`",
+	}
+	for i, e := range errs {
+		if e.Error() != expected[i] {
+			t.Fatalf("error: expected %q, got: %q", expected[i], e.Error())
+		}
+	}
+
+	got := format.Nugget(nugget)
+	want := `GET "https://test.com/todos?q=a%20b"
+accept: application/json
+sec-ch-ua: "Chromium";v="122"
+HTTP 200
+
+POST https://test.com/todos
+Content-Type: application/json
+{"title": "a"}
+HTTP 201
+
+DELETE https://test.com/todos/1
+`
+	if got != want {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", want, got)
+	}
+	if nugget.Entries[1].Req.Body.Value == nil {
+		t.Fatal("error: expected the body to be parsed")
+	}
+
+	if _, err := Import([]byte("{")); err == nil || err.Error() != "invalid HAR: unexpected end of JSON input" {
+		t.Fatalf("error: unexpected error %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "42"})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer server.Close()
+
+	input := "POST " + server.URL + "/todos?page=2\nAuthorization: {{token}}\n{\"title\": \"a\"}\nHTTP 201\n\nGET " + server.URL + "/bin\n"
+	root, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	results, err := runner.New(runner.Variables(map[string]string{"token": "abc"})).Run(context.Background(), root)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	archive := Export(results)
+	if archive.Log.Version != "1.2" || len(archive.Log.Entries) != 2 {
+		t.Fatalf("error: unexpected archive %+v", archive.Log)
+	}
+
+	entry := archive.Log.Entries[0]
+	req, res := entry.Request, entry.Response
+	if req.Method != "POST" || req.URL != server.URL+"/todos?page=2" || req.HTTPVersion != "HTTP/1.1" {
+		t.Fatalf("error: unexpected request %+v", req)
+	}
+	if len(req.QueryString) != 1 || req.QueryString[0] != (NameValue{"page", "2"}) {
+		t.Fatalf("error: unexpected query string %+v", req.QueryString)
+	}
+	if req.PostData == nil || req.PostData.Text != `{"title": "a"}` || req.PostData.MimeType != "application/json" || req.BodySize != 14 {
+		t.Fatalf("error: unexpected post data %+v", req.PostData)
+	}
+	found := false
+	for _, header := range req.Headers {
+		found = found || header == NameValue{"Authorization", "abc"}
+	}
+	if !found {
+		t.Fatalf("error: expected the rendered Authorization header, got: %+v", req.Headers)
+	}
+
+	if res.Status != 201 || res.StatusText != "Created" || res.Content.Text != `{"id": 1}` || res.Content.MimeType != "application/json" {
+		t.Fatalf("error: unexpected response %+v", res)
+	}
+	if len(res.Cookies) != 1 || res.Cookies[0] != (NameValue{"sid", "42"}) {
+		t.Fatalf("error: unexpected cookies %+v", res.Cookies)
+	}
+
+	timings := entry.Timings
+	if entry.Time <= 0 || timings.Wait <= 0 || timings.Wait+timings.Receive != entry.Time || timings.DNS != -1 {
+		t.Fatalf("error: unexpected timings %v %+v", entry.Time, timings)
+	}
+	if archive.Log.Entries[1].Request.PostData != nil {
+		t.Fatal("error: expected no post data for a request without body")
+	}
+
+	// the archive reads back as the nugget that was run, with the recorded
+	// statuses and the variables rendered
+	data, err := json.Marshal(archive)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	nugget, err := Import(data)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := "POST " + server.URL + "/todos?page=2\nAuthorization: abc\nContent-Type: application/json\n{\"title\": \"a\"}\nHTTP 201\n\nGET " + server.URL + "/bin\nHTTP 201\n"
+	if got := format.Nugget(nugget); got != want {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", want, got)
+	}
+}
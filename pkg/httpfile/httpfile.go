@@ -0,0 +1,120 @@
+package httpfile
+
+// The httpfile package converts the .http files of the REST Client of VS Code
+// and of the JetBrains IDEs to nuggets, and nuggets back to .http files. nug
+// reads .http files as they are with the lexer.HTTPFile option, these
+// conversions are for moving a whole file from one format to the other.
+
+import (
+	"sort"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/format"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+	"nug/pkg/resolver"
+)
+
+// Import converts a .http file to a nugget. The variables it defines are
+// listed in a comment, as the --var flags of `nug run`, with the `{{name}}`
+// references of their values replaced. The `###` separators are left out and
+// the `//` comments become `#` comments. The entries are parsed back from
+// their source so their templates, bodies and positions are filled in.
+func Import(src []byte) (*ast.Nugget, error) {
+	root, err := parser.New(lexer.New(string(src), lexer.HTTPFile())).ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+	nugget := root.RootValue
+
+	var comments []ast.Comment
+	if len(nugget.Variables) > 0 {
+		vars := map[string]string{}
+		run := "# nug run"
+		for _, v := range nugget.Variables {
+			comments = append(comments, v.Comments...)
+			if err := resolver.Define([]ast.KeyValue{v}, vars); err != nil {
+				vars[v.Key] = v.Value
+			}
+			run += " --var " + shellQuote(v.Key+"="+vars[v.Key])
+		}
+		comments = append(comments, ast.Comment{Type: "Comment", Text: run})
+	}
+
+	entries := make([]ast.Entry, len(nugget.Entries))
+	for i, entry := range nugget.Entries {
+		if i == 0 {
+			entry.Comments = append(comments, entry.Comments...)
+		}
+		entries[i] = convert(entry)
+	}
+
+	return format.Reparse(&ast.Nugget{Entries: entries, Comments: comment(nugget.Comments)})
+}
+
+// convert returns the entry with the comments of the .http dialect turned
+// into nugget comments
+func convert(entry ast.Entry) ast.Entry {
+	entry.Comments = comment(entry.Comments)
+	entry.Req.Comments = comment(entry.Req.Comments)
+	headers := make([]ast.KeyValue, len(entry.Req.Header))
+	for i, header := range entry.Req.Header {
+		header.Comments = comment(header.Comments)
+		headers[i] = header
+	}
+	entry.Req.Header = headers
+	return entry
+}
+
+// comment returns the comments without the `###` separators, with their
+// titles and the `//` comments as `#` comments
+func comment(comments []ast.Comment) []ast.Comment {
+	var list []ast.Comment
+	for _, c := range comments {
+		switch {
+		case strings.HasPrefix(c.Text, "###"):
+			title := strings.TrimSpace(strings.TrimLeft(c.Text, "#"))
+			if title == "" {
+				continue
+			}
+			c.Text = "# " + title
+		case strings.HasPrefix(c.Text, "//"):
+			c.Text = "#" + strings.TrimPrefix(c.Text, "//")
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+// Export returns a nugget as a .http file. The variables in vars are defined
+// at the top of the file, sorted by name, and the responses are left out as
+// the .http files have no expected responses.
+func Export(nugget *ast.Nugget, vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := &ast.Nugget{Comments: nugget.Comments}
+	for _, name := range names {
+		out.Variables = append(out.Variables, ast.KeyValue{Type: "KeyValue", Key: name, Value: vars[name]})
+	}
+	for _, entry := range nugget.Entries {
+		entry.Res = ast.Response{}
+		out.Entries = append(out.Entries, entry)
+	}
+	return format.HTTPFile(out)
+}
+
+// shellQuote returns s as a single word of the shell
+func shellQuote(s string) string {
+	for _, char := range s {
+		if !(char == '_' || char == '-' || char == '.' || char == '/' || char == ':' || char == '=' ||
+			'0' <= char && char <= '9' || 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z') {
+			return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+		}
+	}
+	return s
+}
@@ -0,0 +1,107 @@
+package httpfile
+
+import (
+	"testing"
+
+	"nug/pkg/format"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+)
+
+func TestImport(t *testing.T) {
+	input := `# the API
+@host = test.com
+@url = https://{{host}}/api
+@token = {{env_token}}
+
+### list the todos
+GET {{url}}/todos HTTP/1.1
+// no cache
+Cache-Control: no-cache
+
+###
+
+POST {{url}}/todos
+Authorization: Bearer {{token}}
+Content-Type: application/json
+
+{
+  "title": "a"
+}
+// the end`
+
+	nugget, err := Import([]byte(input))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	got := format.Nugget(nugget)
+	want := `# the API
+# nug run --var host=test.com --var url=https://test.com/api --var 'token={{env_token}}'
+# list the todos
+GET {{url}}/todos
+# no cache
+Cache-Control: no-cache
+
+POST {{url}}/todos
+Authorization: Bearer {{token}}
+Content-Type: application/json
+{
+  "title": "a"
+}
+# the end
+`
+	if got != want {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", want, got)
+	}
+	if nugget.Entries[1].Req.Body.Value == nil {
+		t.Fatal("error: expected the body to be parsed")
+	}
+
+	_, err = Import([]byte("GET https://test.com\n@host"))
+	if err == nil || err.Error() != "line 2, column 1: expected `@name = value`, got: `@host`" {
+		t.Fatalf("error: unexpected error %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	input := `# list the todos
+GET https://{{host}}/todos
+HTTP 200
+
+POST https://{{host}}/todos
+{"title": "a"}
+HTTP 201
+[Capture]
+id: jsonpath "$.id"
+`
+	root, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	got := Export(root.RootValue, map[string]string{"host": "test.com", "a": "1"})
+	want := `@a = 1
+@host = test.com
+
+# list the todos
+GET https://{{host}}/todos
+
+###
+POST https://{{host}}/todos
+
+{"title": "a"}
+`
+	if got != want {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	// the .http file reads back as the nugget without its responses
+	nugget, err := Import([]byte(got))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want = "# nug run --var a=1 --var host=test.com\n# list the todos\nGET https://{{host}}/todos\n\nPOST https://{{host}}/todos\n{\"title\": \"a\"}\n"
+	if got := format.Nugget(nugget); got != want {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,746 @@
+package jsonpath
+
+// The jsonpath package selects values of a JSON document with a path such as
+// `$.data[0].id`. Documents are the values decoded by encoding/json, numbers
+// can be float64 or json.Number.
+//
+// A path starts at the root `$` and is followed by segments:
+//
+//	.name  ['name']  ["name"]   member of an object
+//	.*     [*]                  every member or element
+//	[0]    [-1]                 element of an array, negative from the end
+//	[1:3]  [::2]  [-2:]         slice of an array
+//	[0,2]  ['a','b']            union of selectors
+//	[?(@.price < 10)]           filter of the members or elements
+//	..name  ..*  ..[0]          recursive descent
+//
+// Filters compare paths relative to the current value `@` or to the root `$`
+// with numbers, strings, true, false and null, using `==`, `!=`, `<`, `<=`,
+// `>`, `>=`, `&&`, `||`, `!` and parentheses. A path alone, `[?(@.isbn)]`, is
+// true when it selects something.
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error is a syntax error in a path. Pos is the index, in runes, of the char
+// where the error was found.
+type Error struct {
+	Path string
+	Pos  int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid JSON path, %s: `%s`", e.Msg, e.Path)
+}
+
+// Path is a parsed JSON path
+type Path struct {
+	Source   string
+	segments []segment
+}
+
+// segment selects values from the children of the current values, or from
+// all their descendants
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+type selectorKind int
+
+const (
+	nameSelector selectorKind = iota
+	wildcardSelector
+	indexSelector
+	sliceSelector
+	filterSelector
+)
+
+type selector struct {
+	kind             selectorKind
+	name             string
+	index            int
+	start, end, step *int
+	filter           expr
+}
+
+// Parse parses a path, an *Error tells where it is malformed
+func Parse(path string) (*Path, error) {
+	p := &parser{path: path, chars: []rune(path)}
+
+	if !p.accept('$') {
+		return nil, p.errorf("expected `$`")
+	}
+
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected `%c`", p.peek())
+	}
+
+	return &Path{Source: path, segments: segments}, nil
+}
+
+// Eval returns the values of doc selected by the path, in document order.
+// Object members are ordered by name, the order of the document is lost once
+// it is decoded.
+func (p *Path) Eval(doc interface{}) []interface{} {
+	return evalSegments(p.segments, doc, doc)
+}
+
+func evalSegments(segments []segment, root, value interface{}) []interface{} {
+	values := []interface{}{value}
+	for _, seg := range segments {
+		var next []interface{}
+		for _, v := range values {
+			if !seg.descendant {
+				next = append(next, seg.eval(root, v)...)
+				continue
+			}
+			for _, d := range descendants(v) {
+				next = append(next, seg.eval(root, d)...)
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func (seg segment) eval(root, value interface{}) []interface{} {
+	var values []interface{}
+	for _, sel := range seg.selectors {
+		values = append(values, sel.eval(root, value)...)
+	}
+	return values
+}
+
+func (sel selector) eval(root, value interface{}) []interface{} {
+	switch sel.kind {
+	case nameSelector:
+		if object, ok := value.(map[string]interface{}); ok {
+			if child, ok := object[sel.name]; ok {
+				return []interface{}{child}
+			}
+		}
+	case wildcardSelector:
+		return children(value)
+	case indexSelector:
+		if array, ok := value.([]interface{}); ok {
+			i := sel.index
+			if i < 0 {
+				i += len(array)
+			}
+			if 0 <= i && i < len(array) {
+				return []interface{}{array[i]}
+			}
+		}
+	case sliceSelector:
+		if array, ok := value.([]interface{}); ok {
+			return slice(array, sel.start, sel.end, sel.step)
+		}
+	case filterSelector:
+		var values []interface{}
+		for _, child := range children(value) {
+			if sel.filter.test(root, child) {
+				values = append(values, child)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// slice returns the elements of array from start to end, excluded, every step
+// elements. Like in Python, negative bounds count from the end and a negative
+// step walks the array backwards.
+func slice(array []interface{}, start, end, step *int) []interface{} {
+	n := len(array)
+	s := 1
+	if step != nil {
+		s = *step
+	}
+	// a step longer than the array takes at most one element, clamping it
+	// keeps the index from overflowing
+	s = clamp(s, -n-1, n+1)
+
+	bound := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		b := *i
+		if b < 0 {
+			b += n
+		}
+		if s > 0 {
+			return clamp(b, 0, n)
+		}
+		return clamp(b, -1, n-1)
+	}
+
+	var values []interface{}
+	if s > 0 {
+		for i := bound(start, 0); i < bound(end, n); i += s {
+			values = append(values, array[i])
+		}
+		return values
+	}
+	for i := bound(start, n-1); i > bound(end, -1); i += s {
+		values = append(values, array[i])
+	}
+	return values
+}
+
+func clamp(i, min, max int) int {
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
+// children returns the members of an object sorted by name, or the elements
+// of an array
+func children(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		values := make([]interface{}, len(names))
+		for i, name := range names {
+			values[i] = v[name]
+		}
+		return values
+	case []interface{}:
+		return v
+	}
+	return nil
+}
+
+// descendants returns value and all the values nested in it, parents first
+func descendants(value interface{}) []interface{} {
+	values := []interface{}{value}
+	for _, child := range children(value) {
+		values = append(values, descendants(child)...)
+	}
+	return values
+}
+
+// expr is a filter expression, tested against each child of the filtered value
+type expr interface {
+	test(root, current interface{}) bool
+}
+
+type orExpr struct{ left, right expr }
+type andExpr struct{ left, right expr }
+type notExpr struct{ e expr }
+
+// existsExpr is a path alone in a filter, true when it selects something
+type existsExpr struct{ path operand }
+
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e orExpr) test(root, current interface{}) bool {
+	return e.left.test(root, current) || e.right.test(root, current)
+}
+
+func (e andExpr) test(root, current interface{}) bool {
+	return e.left.test(root, current) && e.right.test(root, current)
+}
+
+func (e notExpr) test(root, current interface{}) bool {
+	return !e.e.test(root, current)
+}
+
+func (e existsExpr) test(root, current interface{}) bool {
+	_, found := e.path.value(root, current)
+	return found
+}
+
+func (e compareExpr) test(root, current interface{}) bool {
+	left, leftFound := e.left.value(root, current)
+	right, rightFound := e.right.value(root, current)
+
+	switch e.op {
+	case "==":
+		return leftFound == rightFound && (!leftFound || equal(left, right))
+	case "!=":
+		return leftFound != rightFound || (leftFound && !equal(left, right))
+	}
+	if !leftFound || !rightFound {
+		return false
+	}
+
+	c, ok := compare(left, right)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// operand is a literal or a path of a comparison. A path gives the first value
+// it selects.
+type operand struct {
+	literal  interface{}
+	isPath   bool
+	fromRoot bool
+	segments []segment
+}
+
+func (o operand) value(root, current interface{}) (interface{}, bool) {
+	if !o.isPath {
+		return o.literal, true
+	}
+	start := current
+	if o.fromRoot {
+		start = root
+	}
+	values := evalSegments(o.segments, root, start)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// number returns v as a float64 when it is a number
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func equal(a, b interface{}) bool {
+	x, ok := number(a)
+	y, isNumber := number(b)
+	if ok || isNumber {
+		return ok && isNumber && x == y
+	}
+	switch a.(type) {
+	case string, bool, nil:
+		return a == b
+	}
+	return false
+}
+
+// compare orders two numbers or two strings
+func compare(a, b interface{}) (int, bool) {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	x, ok := a.(string)
+	y, isString := b.(string)
+	if !ok || !isString {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+// parser reads a path one char at a time
+type parser struct {
+	path  string
+	chars []rune
+	pos   int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.chars)
+}
+
+func (p *parser) peek() rune {
+	if p.done() {
+		return 0
+	}
+	return p.chars[p.pos]
+}
+
+func (p *parser) accept(char rune) bool {
+	if p.peek() == char && !p.done() {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) acceptString(s string) bool {
+	if strings.HasPrefix(string(p.chars[p.pos:]), s) {
+		p.pos += len([]rune(s))
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpaces() {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) *Error {
+	return &Error{Path: p.path, Pos: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseSegments reads the segments following `$` or `@`
+func (p *parser) parseSegments() ([]segment, error) {
+	var segments []segment
+	for {
+		var seg segment
+		var err error
+
+		switch {
+		case p.acceptString(".."):
+			seg.descendant = true
+			if p.peek() == '[' {
+				seg.selectors, err = p.parseBracket()
+			} else {
+				seg.selectors, err = p.parseDotSelector()
+			}
+		case p.accept('.'):
+			seg.selectors, err = p.parseDotSelector()
+		case p.peek() == '[':
+			seg.selectors, err = p.parseBracket()
+		default:
+			return segments, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+}
+
+// parseDotSelector reads the name or `*` after a `.`
+func (p *parser) parseDotSelector() ([]selector, error) {
+	if p.accept('*') {
+		return []selector{{kind: wildcardSelector}}, nil
+	}
+
+	start := p.pos
+	for !p.done() && isNameChar(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.done() {
+			return nil, p.errorf("expected a name after `.`")
+		}
+		return nil, p.errorf("expected a name after `.`, got: `%c`", p.peek())
+	}
+	return []selector{{kind: nameSelector, name: string(p.chars[start:p.pos])}}, nil
+}
+
+func isNameChar(char rune) bool {
+	return char == '_' || char == '-' || char == '$' || char > 127 ||
+		'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9'
+}
+
+// parseBracket reads a `[...]` list of selectors separated by commas
+func (p *parser) parseBracket() ([]selector, error) {
+	open := p.pos
+	p.accept('[')
+
+	var selectors []selector
+	for {
+		p.skipSpaces()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		p.skipSpaces()
+
+		if p.accept(',') {
+			continue
+		}
+		if p.accept(']') {
+			return selectors, nil
+		}
+		if p.done() {
+			err := p.errorf("expected `]`")
+			err.Pos = open
+			return nil, err
+		}
+		return nil, p.errorf("expected `,` or `]`, got: `%c`", p.peek())
+	}
+}
+
+func (p *parser) parseSelector() (selector, error) {
+	switch char := p.peek(); {
+	case char == '*':
+		p.pos++
+		return selector{kind: wildcardSelector}, nil
+	case char == '\'' || char == '"':
+		name, err := p.parseString()
+		return selector{kind: nameSelector, name: name}, err
+	case char == '?':
+		p.pos++
+		p.skipSpaces()
+		filter, err := p.parseOr()
+		return selector{kind: filterSelector, filter: filter}, err
+	case char == ':' || char == '-' || isDigit(char):
+		return p.parseIndexOrSlice()
+	case char == 0:
+		return selector{}, p.errorf("expected a selector")
+	}
+	return selector{}, p.errorf("expected a selector, got: `%c`", p.peek())
+}
+
+// parseIndexOrSlice reads `index` or `start:end:step`, where each part of the
+// slice can be left out
+func (p *parser) parseIndexOrSlice() (selector, error) {
+	var parts [3]*int
+	part := 0
+
+	for {
+		p.skipSpaces()
+		if p.peek() == '-' || isDigit(p.peek()) {
+			n, err := p.parseInt()
+			if err != nil {
+				return selector{}, err
+			}
+			parts[part] = &n
+			p.skipSpaces()
+		}
+
+		if p.peek() != ':' {
+			break
+		}
+		if part == 2 {
+			return selector{}, p.errorf("unexpected `:`")
+		}
+		p.pos++
+		part++
+	}
+
+	if part == 0 {
+		if parts[0] == nil {
+			return selector{}, p.errorf("expected an index")
+		}
+		return selector{kind: indexSelector, index: *parts[0]}, nil
+	}
+	if parts[2] != nil && *parts[2] == 0 {
+		return selector{}, p.errorf("slice step cannot be zero")
+	}
+	return selector{kind: sliceSelector, start: parts[0], end: parts[1], step: parts[2]}, nil
+}
+
+func (p *parser) parseInt() (int, error) {
+	start := p.pos
+	p.accept('-')
+	for isDigit(p.peek()) {
+		p.pos++
+	}
+	n, err := strconv.Atoi(string(p.chars[start:p.pos]))
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("invalid number `%s`", string(p.chars[start:p.pos+1]))
+	}
+	return n, nil
+}
+
+func isDigit(char rune) bool {
+	return '0' <= char && char <= '9'
+}
+
+// parseString reads a single or double quoted string, `\` escapes the next
+// char
+func (p *parser) parseString() (string, error) {
+	start := p.pos
+	quote := p.chars[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for !p.done() {
+		char := p.chars[p.pos]
+		p.pos++
+		switch char {
+		case quote:
+			return b.String(), nil
+		case '\\':
+			if p.done() {
+				break
+			}
+			b.WriteRune(p.chars[p.pos])
+			p.pos++
+		default:
+			b.WriteRune(char)
+		}
+	}
+
+	p.pos = start
+	return "", p.errorf("unterminated string, expected `%c`", quote)
+}
+
+// parseOr reads a filter expression, a list of `&&` expressions separated by
+// `||`. A filter can be written `?(expr)` or `?expr`.
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !p.acceptString("||") {
+			return left, nil
+		}
+		p.skipSpaces()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !p.acceptString("&&") {
+			return left, nil
+		}
+		p.skipSpaces()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.peek() == '!' && !strings.HasPrefix(string(p.chars[p.pos:]), "!=") {
+		p.pos++
+		p.skipSpaces()
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+
+	if p.peek() == '(' {
+		open := p.pos
+		p.pos++
+		p.skipSpaces()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.accept(')') {
+			if p.done() {
+				err := p.errorf("expected `)`")
+				err.Pos = open
+				return nil, err
+			}
+			return nil, p.errorf("expected `)`, got: `%c`", p.peek())
+		}
+		return e, nil
+	}
+
+	return p.parseComparison()
+}
+
+var comparisons = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	for _, op := range comparisons {
+		if !p.acceptString(op) {
+			continue
+		}
+		p.skipSpaces()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compareExpr{op: op, left: left, right: right}, nil
+	}
+
+	if !left.isPath {
+		return nil, p.errorf("expected a comparison after the literal")
+	}
+	return existsExpr{left}, nil
+}
+
+// parseOperand reads a path starting at `@` or `$`, or a literal
+func (p *parser) parseOperand() (operand, error) {
+	switch char := p.peek(); {
+	case char == '@' || char == '$':
+		p.pos++
+		segments, err := p.parseSegments()
+		return operand{isPath: true, fromRoot: char == '$', segments: segments}, err
+	case char == '\'' || char == '"':
+		s, err := p.parseString()
+		return operand{literal: s}, err
+	case char == '-' || isDigit(char):
+		start := p.pos
+		p.accept('-')
+		for isDigit(p.peek()) || strings.ContainsRune(".eE+-", p.peek()) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(string(p.chars[start:p.pos]), 64)
+		if err != nil {
+			p.pos = start
+			return operand{}, p.errorf("invalid number")
+		}
+		return operand{literal: n}, nil
+	case p.acceptString("true"):
+		return operand{literal: true}, nil
+	case p.acceptString("false"):
+		return operand{literal: false}, nil
+	case p.acceptString("null"):
+		return operand{literal: nil}, nil
+	case char == 0:
+		return operand{}, p.errorf("expected a path or a value")
+	}
+	return operand{}, p.errorf("expected a path or a value, got: `%c`", p.peek())
+}
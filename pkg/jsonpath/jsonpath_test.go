@@ -0,0 +1,148 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{
+		"data": [
+			{"id": 1, "name": "a"},
+			{"id": 2, "name": "b"}
+		],
+		"total": 2,
+		"odd key": true
+	}`), &doc)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	tests := [...]struct {
+		path     string
+		expected []interface{}
+	}{
+		{"$", []interface{}{doc}},
+		{"$.total", []interface{}{2.0}},
+		{"$.data[0].id", []interface{}{1.0}},
+		{"$.data[-1].name", []interface{}{"b"}},
+		{"$['odd key']", []interface{}{true}},
+		{`$["data"][1]["id"]`, []interface{}{2.0}},
+		{"$.data[*].name", []interface{}{"a", "b"}},
+		{"$.data[0].*", []interface{}{1.0, "a"}},
+		{"$.missing", nil},
+		{"$.data[2]", nil},
+		{"$.total.id", nil},
+	}
+
+	for _, test := range tests {
+		path, err := Parse(test.path)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", test.path, err)
+		}
+		values := path.Eval(doc)
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Fatalf("error: %s - expected %v, got: %v", test.path, test.expected, values)
+		}
+	}
+}
+
+func TestEvalStore(t *testing.T) {
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{
+		"store": {
+			"book": [
+				{"title": "a", "price": 8.95, "tags": ["x"]},
+				{"title": "b", "price": 12.99, "isbn": "0-553"},
+				{"title": "c", "price": 8.99, "isbn": "0-395"},
+				{"title": "d", "price": 22.99}
+			],
+			"bicycle": {"color": "red", "price": 19.95}
+		},
+		"limit": 10
+	}`), &doc)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	tests := [...]struct {
+		path     string
+		expected []interface{}
+	}{
+		{"$..price", []interface{}{19.95, 8.95, 12.99, 8.99, 22.99}},
+		{"$.store..title", []interface{}{"a", "b", "c", "d"}},
+		{"$..book[2].title", []interface{}{"c"}},
+		{"$..[0]", []interface{}{map[string]interface{}{"title": "a", "price": 8.95, "tags": []interface{}{"x"}}, "x"}},
+		{"$.store.book[1:3].title", []interface{}{"b", "c"}},
+		{"$.store.book[-2:].title", []interface{}{"c", "d"}},
+		{"$.store.book[:2].title", []interface{}{"a", "b"}},
+		{"$.store.book[::2].title", []interface{}{"a", "c"}},
+		{"$.store.book[::-1].title", []interface{}{"d", "c", "b", "a"}},
+		{"$.store.book[1::9223372036854775807].title", []interface{}{"b"}},
+		{"$.store.book[2::-9223372036854775808].title", []interface{}{"c"}},
+		{"$.store.book[5:].title", nil},
+		{"$.store.book[0,3].title", []interface{}{"a", "d"}},
+		{"$.store.bicycle['color','price']", []interface{}{"red", 19.95}},
+		{"$.store.book[?(@.price < 10)].title", []interface{}{"a", "c"}},
+		{"$.store.book[?(@.price >= 12.99 && @.price <= 20)].title", []interface{}{"b"}},
+		{"$.store.book[?(@.price > 20 || @.title == 'a')].title", []interface{}{"a", "d"}},
+		{"$.store.book[?(@.isbn)].title", []interface{}{"b", "c"}},
+		{"$.store.book[?(!@.isbn)].title", []interface{}{"a", "d"}},
+		{`$.store.book[?(@.title != "a")].title`, []interface{}{"b", "c", "d"}},
+		{"$.store.book[?(@.price < $.limit)].title", []interface{}{"a", "c"}},
+		{"$.store.book[?(@.tags[0] == 'x')].title", []interface{}{"a"}},
+		{"$.store.book[?(!(@.price < 10 || @.isbn))].title", []interface{}{"d"}},
+		{"$.store.book[?@.price > 20].title", []interface{}{"d"}},
+		{"$.store.book[?(@.missing == null)].title", nil},
+	}
+
+	for _, test := range tests {
+		path, err := Parse(test.path)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", test.path, err)
+		}
+		values := path.Eval(doc)
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Fatalf("error: %s - expected %v, got: %v", test.path, test.expected, values)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := [...]struct {
+		path string
+		err  string
+		pos  int
+	}{
+		{"data.id", "invalid JSON path, expected `$`: `data.id`", 0},
+		{"$.", "invalid JSON path, expected a name after `.`: `$.`", 2},
+		{"$.data[0", "invalid JSON path, expected `]`: `$.data[0`", 6},
+		{"$.data[x]", "invalid JSON path, expected a selector, got: `x`: `$.data[x]`", 7},
+		{"$data", "invalid JSON path, unexpected `d`: `$data`", 1},
+		{"$.data[0 1]", "invalid JSON path, expected `,` or `]`, got: `1`: `$.data[0 1]`", 9},
+		{"$.data[1:2:0]", "invalid JSON path, slice step cannot be zero: `$.data[1:2:0]`", 12},
+		{"$.data[1:2:3:4]", "invalid JSON path, unexpected `:`: `$.data[1:2:3:4]`", 12},
+		{"$['id]", "invalid JSON path, unterminated string, expected `'`: `$['id]`", 2},
+		{"$.data[?(@.id > )]", "invalid JSON path, expected a path or a value, got: `)`: `$.data[?(@.id > )]`", 16},
+		{"$.data[?(@.id == 1]", "invalid JSON path, expected `)`, got: `]`: `$.data[?(@.id == 1]`", 18},
+		{"$.data[?(1)]", "invalid JSON path, expected a comparison after the literal: `$.data[?(1)]`", 10},
+		{"$.data[?(@.id = 1)]", "invalid JSON path, expected `)`, got: `=`: `$.data[?(@.id = 1)]`", 14},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.path)
+		if err == nil {
+			t.Fatalf("expected error for %q", test.path)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+		var pathErr *Error
+		if !errors.As(err, &pathErr) || pathErr.Pos != test.pos {
+			t.Fatalf("error: %s - expected position %d, got: %v", test.path, test.pos, pathErr)
+		}
+	}
+}
@@ -4,24 +4,71 @@ import (
 	"nug/pkg/token"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 type Lexer struct {
 	Input        []rune
-	char         rune // current char under examination
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position (after current char)
-	line         int  // line number for error reporting
+	char         rune           // current char under examination
+	position     int            // current position in input (points to current char)
+	readPosition int            // current reading position (after current char)
+	line         int            // line number for error reporting
+	column       int            // column of the current char, in runes, starting at 1
+	offset       int            // byte offset of the current char
+	start        token.Position // position of the token being lexed
+	lastLine     int            // line of the last emitted token
+	depth        int            // nesting level of the JSON body being lexed, 0 outside a body
+	afterKey     bool           // a `key` was just lexed and its `:` comes next
+	inValue      bool           // the rest of the line is the value of a `key:`
+	whitespace   bool           // emit Whitespace tokens, see EmitWhitespace
+	section      token.Type     // section of the response being lexed, e.g. token.Capture
+	httpFile     bool           // lex the .http dialect, see HTTPFile
+}
+
+// Option configures the Lexer
+type Option func(*Lexer)
+
+// EmitWhitespace makes the lexer emit the spaces and tabs between tokens as
+// Whitespace tokens instead of skipping them, for tools that need to keep the
+// layout of the input. Inside a JSON body new lines are whitespace too.
+func EmitWhitespace() Option {
+	return func(l *Lexer) {
+		l.whitespace = true
+	}
+}
+
+// HTTPFile makes the lexer read the .http dialect of the REST Client of VS Code
+// and of the JetBrains IDEs: `//` comments, `@name = value` lines defining
+// variables, and an HTTP version ending the request line. Its `###`
+// separators are comments already.
+func HTTPFile() Option {
+	return func(l *Lexer) {
+		l.httpFile = true
+	}
 }
 
 // New() creates a pointer to the Lexer
-func New(input string) *Lexer {
-	l := &Lexer{Input: []rune(input)}
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{Input: []rune(input), column: 1, lastLine: -1}
+	for _, opt := range opts {
+		opt(l)
+	}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	// keep track of the position of the char we are leaving
+	if l.readPosition > 0 && l.position < len(l.Input) {
+		l.offset += utf8.RuneLen(l.char)
+		if l.char == '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+	}
+
 	if l.readPosition >= len(l.Input) {
 		// End of input: haven't read anything yet or EOF
 		// 0 is ASCCII code for "NULL" character
@@ -34,20 +81,149 @@ func (l *Lexer) readChar() {
 	l.readPosition++
 }
 
-// NextToken switches through the lexer's current char and creates a new token.
-// It then it calls readChar() to advance the lexer and it returns the token
+// unreadChar moves the lexer back one char. It must not be used to go back
+// over a new line.
+func (l *Lexer) unreadChar() {
+	l.position--
+	l.readPosition--
+	l.char = l.Input[l.position]
+	l.offset -= utf8.RuneLen(l.char)
+	l.column--
+}
+
+// pos returns the position of the current char
+func (l *Lexer) pos() token.Position {
+	return token.Position{Line: l.line + 1, Column: l.column, Offset: l.offset}
+}
+
+// NextToken returns the next token of the input and remembers the line it
+// ended on, so the lexer knows which tokens start a new line (new lines and
+// whitespace don't count). The lexer always stops right after the token, which
+// gives its end position.
 func (l *Lexer) NextToken() token.Token {
+	t := l.nextToken()
+	t.StartPos = l.start
+	t.EndPos = l.pos()
+	t.End = l.position
+	if t.Type != token.NewLine && t.Type != token.Whitespace {
+		l.lastLine = l.line
+	}
+
+	switch {
+	case t.Type == token.Capture || t.Type == token.Asserts:
+		l.section = t.Type
+	case t.Type == token.Http || token.IsMethod(t.Type):
+		l.section = ""
+	}
+	return t
+}
+
+// nextToken switches through the lexer's current char and creates a new token.
+// It then it calls readChar() to advance the lexer and it returns the token
+func (l *Lexer) nextToken() token.Token {
 	var t token.Token
 
+	if l.whitespace && l.isSpace(l.char) {
+		l.start = l.pos()
+		t.Start = l.position
+		t.Line = l.line
+		t.Literal = l.skipWhiteSpace()
+		t.Type = token.Whitespace
+		return t
+	}
+
+	if l.inValue {
+		return l.readValue()
+	}
+
 	l.skipWhiteSpace()
+	l.start = l.pos()
+
+	if l.depth > 0 {
+		return l.nextJSONToken()
+	}
+
+	if l.afterKey {
+		// captures are made of tokens like the asserts, not of free text,
+		// except for a JSON path alone which can hold spaces in its filters
+		l.afterKey = false
+		l.inValue = l.section != token.Capture || l.isPathValue()
+		t = newToken(token.Colon, l.line, l.position, l.position+1, l.char)
+		l.readChar()
+		return t
+	}
+
+	if l.httpFile && l.firstOnLine() && (l.char == '@' || l.char == '/' && l.peekChar() == '/') {
+		t.Start = l.position
+		t.Type = token.Comment
+		if l.char == '@' {
+			t.Type = token.Definition
+		}
+		t.Literal = l.readComment()
+		t.Line = l.line
+		t.End = l.position
+		return t
+	}
+
+	// a line starting with `key:` is a header or a capture, its value is
+	// lexed up to the end of the line
+	if l.firstOnLine() && l.isKey() {
+		t.Start = l.position
+		t.Literal = l.readKey()
+		t.Type = token.String
+		t.Line = l.line
+		l.afterKey = true
+		return t
+	}
 
 	switch l.char {
 	case 0:
 		t.Literal = ""
 		t.Type = token.EOF
 		t.Line = l.line
+		t.Start = l.position
+		t.End = l.position
+		return t
+	case '\n':
+		t = newToken(token.NewLine, l.line, l.position, l.position+1, l.char)
+	case '#':
+		// a `#` starting a token opens a comment, inside an identifier (e.g.
+		// https://test.com/#) it is a normal char
+		t.Start = l.position
+		t.Literal = l.readComment()
+		t.Type = token.Comment
+		t.Line = l.line
+		t.End = l.position
+		return t
+	case '<', '>':
+		// comparison operators of the asserts
+		t.Start = l.position
+		t.Line = l.line
+		t.Type = token.String
+		if l.peekChar() == '=' {
+			l.readChar()
+		}
+		t.Literal = string(l.Input[t.Start : l.position+1])
+	case '"':
+		// double quoted strings keep their quotes and escape sequences, the
+		// parser unquotes them
+		t.Start = l.position
+		t.Literal = l.readString()
+		t.Type = token.String
+		t.Line = l.line
+		t.End = l.position + 1
+	case '{':
+		if l.firstOnLine() && !l.isVariable() {
+			return l.nextJSONToken()
+		}
+		fallthrough
+	case '[':
+		if l.char == '[' && l.firstOnLine() && !l.isSection() {
+			return l.nextJSONToken()
+		}
+		fallthrough
 	default:
-		if isValidChar(l.char) {
+		if isValidChar(l.char) || l.isVariable() {
 			t.Start = l.position
 			ident := l.readIdentifier()
 			t.Literal = ident
@@ -59,6 +235,17 @@ func (l *Lexer) NextToken() token.Token {
 				return t
 			}
 
+			// keywords only start a line, anywhere else they are plain strings
+			// (e.g. a `X-Method: get` header), but for the HTTP version ending
+			// a request line of the .http dialect
+			if !l.firstOnLine() {
+				t.Type = token.String
+				if tokenType, err := token.LookupMethod(ident); l.httpFile && err == nil && tokenType == token.Http {
+					t.Type = token.Http
+				}
+				return t
+			}
+
 			tokenType, err := token.LookupMethod(ident)
 			if err != nil {
 				t.Type = token.String
@@ -70,7 +257,7 @@ func (l *Lexer) NextToken() token.Token {
 			return t
 		}
 
-		t = newToken(token.Ilegal, l.line, 1, 2, l.char)
+		t = newToken(token.Ilegal, l.line, l.position, l.position+1, l.char)
 	}
 
 	// advance to next character
@@ -79,13 +266,19 @@ func (l *Lexer) NextToken() token.Token {
 	return t
 }
 
-func (l *Lexer) skipWhiteSpace() {
-	for l.char == ' ' || l.char == '\t' || l.char == '\n' || l.char == '\r' {
-		if l.char == '\n' {
-			l.line++
-		}
+// skipWhiteSpace skips the spaces and tabs under the lexer and returns them.
+// New lines are tokens of their own, except inside a JSON body.
+func (l *Lexer) skipWhiteSpace() string {
+	position := l.position
+	for l.isSpace(l.char) {
 		l.readChar()
 	}
+	return string(l.Input[position:l.position])
+}
+
+// isSpace reports whether char is whitespace between two tokens
+func (l *Lexer) isSpace(char rune) bool {
+	return isBlank(char) || (char == '\n' && l.depth > 0)
 }
 
 func newToken(tokenType token.Type, line, start, end int, char ...rune) token.Token {
@@ -98,22 +291,249 @@ func newToken(tokenType token.Type, line, start, end int, char ...rune) token.To
 	}
 }
 
+// nextJSONToken lexes a single token of a JSON request body. The body starts
+// with a `{` or `[` at the beginning of a line and ends when its matching
+// closing bracket is found, so l.depth tracks the nesting level.
+func (l *Lexer) nextJSONToken() token.Token {
+	var t token.Token
+
+	switch l.char {
+	case 0:
+		t = newToken(token.EOF, l.line, l.position, l.position)
+		return t
+	case '{':
+		if l.isVariable() {
+			// a bare `{{name}}` used as a JSON value
+			t.Start = l.position
+			t.Line = l.line
+			l.readVariable()
+			t.Literal = string(l.Input[t.Start:l.position])
+			t.Type = token.Variable
+			t.End = l.position
+			return t
+		}
+		l.depth++
+		t = newToken(token.LeftBrace, l.line, l.position, l.position+1, l.char)
+	case '}':
+		l.depth--
+		t = newToken(token.RightBrace, l.line, l.position, l.position+1, l.char)
+	case '[':
+		l.depth++
+		t = newToken(token.LeftBracket, l.line, l.position, l.position+1, l.char)
+	case ']':
+		l.depth--
+		t = newToken(token.RightBracket, l.line, l.position, l.position+1, l.char)
+	case ':':
+		t = newToken(token.Colon, l.line, l.position, l.position+1, l.char)
+	case ',':
+		t = newToken(token.Comma, l.line, l.position, l.position+1, l.char)
+	case '"':
+		t.Start = l.position
+		t.Literal = l.readString()
+		t.Type = token.String
+		t.Line = l.line
+		t.End = l.position + 1
+	default:
+		t.Start = l.position
+		t.Line = l.line
+
+		if isDigit(l.char) || l.char == '-' {
+			t.Literal = l.readNumber()
+			t.Type = token.Number
+			t.End = l.position
+			return t
+		}
+
+		if isLetter(l.char) {
+			t.Literal = l.readWord()
+			t.End = l.position
+			if tokenType, ok := token.LookupJSONKeyword(t.Literal); ok {
+				t.Type = tokenType
+				return t
+			}
+			// a keyword starting a line means the body was never closed,
+			// leave the body so the rest of the input can still be parsed
+			if tokenType, err := token.LookupMethod(t.Literal); err == nil && l.firstOnLine() {
+				l.depth = 0
+				t.Type = tokenType
+				return t
+			}
+			t.Type = token.Ilegal
+			return t
+		}
+
+		t = newToken(token.Ilegal, l.line, l.position, l.position+1, l.char)
+	}
+
+	l.readChar()
+
+	return t
+}
+
+// isKey reports whether the current char starts a `key:`, spaces are allowed
+// between the key and the `:`. A comment, a quoted string, a section name or
+// a JSON body never start a key.
+func (l *Lexer) isKey() bool {
+	if l.char == '#' || l.char == '"' || l.char == '[' || (l.char == '{' && !l.isVariable()) {
+		return false
+	}
+
+	saved := *l
+	defer func() { *l = saved }()
+
+	if l.readKey() == "" {
+		return false
+	}
+	for isBlank(l.char) {
+		l.readChar()
+	}
+	return l.char == ':'
+}
+
+// readKey reads the key of a `key: value` line up to the first whitespace or
+// `:`, `{{name}}` references included.
+func (l *Lexer) readKey() string {
+	position := l.position
+	for l.char != ':' && l.char != '\n' && l.char != 0 && !isBlank(l.char) {
+		if l.isVariable() {
+			l.readVariable()
+			continue
+		}
+		l.readChar()
+	}
+	return string(l.Input[position:l.position])
+}
+
+// readValue lexes the value of a `key: value` line, which is the rest of the
+// line without its surrounding whitespace. A `#` preceded by whitespace starts
+// a trailing comment. Quotes are part of the value, as in `If-Match: "abc"`.
+func (l *Lexer) readValue() token.Token {
+	l.inValue = false
+	for isBlank(l.char) {
+		l.readChar()
+	}
+	l.start = l.pos()
+
+	t := token.Token{Type: token.String, Line: l.line, Start: l.position}
+	for !l.endOfValue() {
+		l.readChar()
+	}
+	t.Literal = string(l.Input[t.Start:l.position])
+	return t
+}
+
+// endOfValue reports whether the rest of the line, from the current char on,
+// only holds whitespace and an optional comment.
+func (l *Lexer) endOfValue() bool {
+	i := l.position
+	for i < len(l.Input) && isBlank(l.Input[i]) {
+		i++
+	}
+	if i >= len(l.Input) || l.Input[i] == '\n' {
+		return true
+	}
+	return l.Input[i] == '#' && i > 0 && isBlank(l.Input[i-1])
+}
+
+// isPathValue reports whether the value after the current `:` starts with
+// `$`, the JSON path of a capture such as `id: $.data[?(@.id > 1)].id`.
+func (l *Lexer) isPathValue() bool {
+	i := l.position + 1
+	for i < len(l.Input) && isBlank(l.Input[i]) {
+		i++
+	}
+	return i < len(l.Input) && l.Input[i] == '$'
+}
+
+func isBlank(char rune) bool {
+	return char == ' ' || char == '\t' || char == '\r'
+}
+
+// firstOnLine reports whether the current char starts the first token of its
+// line, which is where a request body is allowed to begin.
+func (l *Lexer) firstOnLine() bool {
+	return l.line != l.lastLine
+}
+
+// isSection reports whether the `[` under examination opens a section name
+// such as `[Capture]` rather than a JSON array.
+func (l *Lexer) isSection() bool {
+	i := l.position + 1
+	for i < len(l.Input) && isLetter(l.Input[i]) {
+		i++
+	}
+	if i == l.position+1 || i >= len(l.Input) || l.Input[i] != ']' {
+		return false
+	}
+	_, ok := token.LookupJSONKeyword(string(l.Input[l.position+1 : i]))
+	return !ok
+}
+
 // readString sets a start position and reads through characters
 // When it finds a closing `"`, it stops consuming characters and
-// returns the string between the start and end positions.
+// returns the string between the start and end positions, quotes included.
+// Escaped quotes do not close the string, and a string never spans lines.
 func (l *Lexer) readString() string {
-	position := l.position + 1
+	position := l.position
 	for {
 		l.readChar() // this moves the reading position to the next char
-		if l.char == ' ' || l.char == 0 {
+		if l.char == '\\' {
+			l.readChar()
+			if l.char == '\n' || l.char == 0 {
+				break
+			}
+			continue
+		}
+		if l.char == '"' || l.char == '\n' || l.char == 0 {
 			break
 		}
 	}
+	if l.char != '"' {
+		// unterminated string, leave the new line or EOF to the next token
+		l.unreadChar()
+	}
+	return string(l.Input[position : l.position+1])
+}
+
+// readNumber reads all the characters that can be part of a JSON number. The
+// parser is in charge of validating that they are in a valid order.
+func (l *Lexer) readNumber() string {
+	position := l.position
+	for isDigit(l.char) || strings.ContainsRune("+-.eE", l.char) {
+		l.readChar()
+	}
 	return string(l.Input[position:l.position])
 }
 
+func (l *Lexer) readWord() string {
+	position := l.position
+	for isLetter(l.char) {
+		l.readChar()
+	}
+	return string(l.Input[position:l.position])
+}
+
+func isDigit(char rune) bool {
+	return '0' <= char && char <= '9'
+}
+
+func isLetter(char rune) bool {
+	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z'
+}
+
+// readComment reads from the `#` up to the end of the line, the new line
+// itself is not part of the comment.
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.char != '\n' && l.char != 0 {
+		l.readChar()
+	}
+	return strings.TrimRight(string(l.Input[position:l.position]), " \t\r")
+}
+
+// isNumber reports whether s is a JSON number, with its fraction and exponent
 func isNumber(s string) bool {
-	match, _ := regexp.MatchString(`^-?[0-9]\d*(\.\d+)?$`, s)
+	match, _ := regexp.MatchString(`^-?[0-9]\d*(\.\d+)?([eE][+-]?\d+)?$`, s)
 	return match
 }
 
@@ -125,9 +545,45 @@ func isValidChar(char rune) bool {
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 
-	for isValidChar(l.char) {
+	for {
+		if l.isVariable() {
+			l.readVariable()
+			continue
+		}
+		if !isValidChar(l.char) {
+			break
+		}
 		l.readChar()
 	}
 
 	return string(l.Input[position:l.position])
 }
+
+// isVariable reports whether the current char opens a `{{name}}` reference
+func (l *Lexer) isVariable() bool {
+	return l.char == '{' && l.peekChar() == '{'
+}
+
+// readVariable reads a `{{name}}` reference up to its closing braces. Spaces
+// are allowed inside the braces, so `{{ name }}` doesn't split the token. An
+// unterminated reference stops at the end of the line, the parser reports it.
+func (l *Lexer) readVariable() {
+	l.readChar()
+	l.readChar()
+	for l.char != '\n' && l.char != 0 {
+		if l.char == '}' && l.peekChar() == '}' {
+			l.readChar()
+			l.readChar()
+			return
+		}
+		l.readChar()
+	}
+}
+
+// peekChar returns the char after the current one without consuming it
+func (l *Lexer) peekChar() rune {
+	if l.readPosition >= len(l.Input) {
+		return 0
+	}
+	return l.Input[l.readPosition]
+}
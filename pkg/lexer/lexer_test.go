@@ -15,8 +15,10 @@ func TestNextToken(t *testing.T) {
 	tests := []token.Token{
 		{Type: token.Post, Literal: "POST", Line: 0},
 		{Type: token.String, Literal: "http://test.com/api/v1?var1=val1&var2=val2", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
 		{Type: token.Http, Literal: "HTTP", Line: 1},
 		{Type: token.Number, Literal: "200", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
 		{Type: token.EOF, Literal: "", Line: 2},
 	}
 
@@ -45,3 +47,464 @@ func formatTokenOutputString(t token.Token) string {
 	result := fmt.Sprintf("Type:%q; Literal:%q; Line:%d", t.Type, t.Literal, t.Line)
 	return result
 }
+
+func TestNextTokenBody(t *testing.T) {
+	input := `POST http://test.com/api
+{
+	"name": "nugget \"v1\"",
+	"tags": [1, -2.5e3, true, false, null]
+}
+HTTP 200`
+
+	tests := []token.Token{
+		{Type: token.Post, Literal: "POST", Line: 0},
+		{Type: token.String, Literal: "http://test.com/api", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.LeftBrace, Literal: "{", Line: 1},
+		{Type: token.String, Literal: `"name"`, Line: 2},
+		{Type: token.Colon, Literal: ":", Line: 2},
+		{Type: token.String, Literal: `"nugget \"v1\""`, Line: 2},
+		{Type: token.Comma, Literal: ",", Line: 2},
+		{Type: token.String, Literal: `"tags"`, Line: 3},
+		{Type: token.Colon, Literal: ":", Line: 3},
+		{Type: token.LeftBracket, Literal: "[", Line: 3},
+		{Type: token.Number, Literal: "1", Line: 3},
+		{Type: token.Comma, Literal: ",", Line: 3},
+		{Type: token.Number, Literal: "-2.5e3", Line: 3},
+		{Type: token.Comma, Literal: ",", Line: 3},
+		{Type: token.True, Literal: "true", Line: 3},
+		{Type: token.Comma, Literal: ",", Line: 3},
+		{Type: token.False, Literal: "false", Line: 3},
+		{Type: token.Comma, Literal: ",", Line: 3},
+		{Type: token.Null, Literal: "null", Line: 3},
+		{Type: token.RightBracket, Literal: "]", Line: 3},
+		{Type: token.RightBrace, Literal: "}", Line: 4},
+		{Type: token.NewLine, Literal: "\n", Line: 4},
+		{Type: token.Http, Literal: "HTTP", Line: 5},
+		{Type: token.Number, Literal: "200", Line: 5},
+		{Type: token.EOF, Literal: "", Line: 5},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenSectionIsNotBody(t *testing.T) {
+	input := `GET http://test.com/api
+HTTP 200
+[Capture]
+id: $.data[0].id`
+
+	tests := []token.Token{
+		{Type: token.Get, Literal: "GET", Line: 0},
+		{Type: token.String, Literal: "http://test.com/api", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.Http, Literal: "HTTP", Line: 1},
+		{Type: token.Number, Literal: "200", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.Capture, Literal: "[Capture]", Line: 2},
+		{Type: token.NewLine, Literal: "\n", Line: 2},
+		{Type: token.String, Literal: "id", Line: 3},
+		{Type: token.Colon, Literal: ":", Line: 3},
+		{Type: token.String, Literal: "$.data[0].id", Line: 3},
+		{Type: token.EOF, Literal: "", Line: 3},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenMethods(t *testing.T) {
+	input := `put http://test.com
+X-Method: DELETE
+Patch http://test.com`
+
+	tests := []token.Token{
+		{Type: token.Put, Literal: "put", Line: 0},
+		{Type: token.String, Literal: "http://test.com", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.String, Literal: "X-Method", Line: 1},
+		{Type: token.Colon, Literal: ":", Line: 1},
+		{Type: token.String, Literal: "DELETE", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.Patch, Literal: "Patch", Line: 2},
+		{Type: token.String, Literal: "http://test.com", Line: 2},
+		{Type: token.EOF, Literal: "", Line: 2},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenComments(t *testing.T) {
+	input := `# list the todos
+GET https://test.com/#
+header: value # trailing comment
+HTTP 200 #ok`
+
+	tests := []token.Token{
+		{Type: token.Comment, Literal: "# list the todos", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.Get, Literal: "GET", Line: 1},
+		{Type: token.String, Literal: "https://test.com/#", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.String, Literal: "header", Line: 2},
+		{Type: token.Colon, Literal: ":", Line: 2},
+		{Type: token.String, Literal: "value", Line: 2},
+		{Type: token.Comment, Literal: "# trailing comment", Line: 2},
+		{Type: token.NewLine, Literal: "\n", Line: 2},
+		{Type: token.Http, Literal: "HTTP", Line: 3},
+		{Type: token.Number, Literal: "200", Line: 3},
+		{Type: token.Comment, Literal: "#ok", Line: 3},
+		{Type: token.EOF, Literal: "", Line: 3},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "# héllo\nGET http://test.com ^\n  key : v"
+
+	tests := []token.Token{
+		{Type: token.Comment, Literal: "# héllo", Start: 0, End: 7,
+			StartPos: token.Position{Line: 1, Column: 1, Offset: 0},
+			EndPos:   token.Position{Line: 1, Column: 8, Offset: 8}},
+		{Type: token.NewLine, Literal: "\n", Start: 7, End: 8,
+			StartPos: token.Position{Line: 1, Column: 8, Offset: 8},
+			EndPos:   token.Position{Line: 2, Column: 1, Offset: 9}},
+		{Type: token.Get, Literal: "GET", Start: 8, End: 11,
+			StartPos: token.Position{Line: 2, Column: 1, Offset: 9},
+			EndPos:   token.Position{Line: 2, Column: 4, Offset: 12}},
+		{Type: token.String, Literal: "http://test.com", Start: 12, End: 27,
+			StartPos: token.Position{Line: 2, Column: 5, Offset: 13},
+			EndPos:   token.Position{Line: 2, Column: 20, Offset: 28}},
+		{Type: token.Ilegal, Literal: "^", Start: 28, End: 29,
+			StartPos: token.Position{Line: 2, Column: 21, Offset: 29},
+			EndPos:   token.Position{Line: 2, Column: 22, Offset: 30}},
+		{Type: token.NewLine, Literal: "\n", Start: 29, End: 30,
+			StartPos: token.Position{Line: 2, Column: 22, Offset: 30},
+			EndPos:   token.Position{Line: 3, Column: 1, Offset: 31}},
+		{Type: token.String, Literal: "key", Start: 32, End: 35,
+			StartPos: token.Position{Line: 3, Column: 3, Offset: 33},
+			EndPos:   token.Position{Line: 3, Column: 6, Offset: 36}},
+		{Type: token.Colon, Literal: ":", Start: 36, End: 37,
+			StartPos: token.Position{Line: 3, Column: 7, Offset: 37},
+			EndPos:   token.Position{Line: 3, Column: 8, Offset: 38}},
+		{Type: token.String, Literal: "v", Start: 38, End: 39,
+			StartPos: token.Position{Line: 3, Column: 9, Offset: 39},
+			EndPos:   token.Position{Line: 3, Column: 10, Offset: 40}},
+		{Type: token.EOF, Literal: "", Start: 39, End: 39,
+			StartPos: token.Position{Line: 3, Column: 10, Offset: 40},
+			EndPos:   token.Position{Line: 3, Column: 10, Offset: 40}},
+	}
+
+	l := New(input)
+
+	for i, expectedToken := range tests {
+		actualToken := l.NextToken()
+
+		if actualToken.Type != expectedToken.Type || actualToken.Literal != expectedToken.Literal {
+			t.Fatalf("tests[%d] - token wrong. Expected: %s, Got: %s", i, formatTokenOutputString(expectedToken), formatTokenOutputString(actualToken))
+		}
+		if actualToken.Start != expectedToken.Start || actualToken.End != expectedToken.End {
+			t.Fatalf("tests[%d] - start/end wrong. Expected: %d-%d, Got: %d-%d", i, expectedToken.Start, expectedToken.End, actualToken.Start, actualToken.End)
+		}
+		if actualToken.StartPos != expectedToken.StartPos || actualToken.EndPos != expectedToken.EndPos {
+			t.Fatalf("tests[%d] - position wrong. Expected: %+v-%+v, Got: %+v-%+v", i, expectedToken.StartPos, expectedToken.EndPos, actualToken.StartPos, actualToken.EndPos)
+		}
+	}
+}
+
+func TestNextTokenVariables(t *testing.T) {
+	input := `GET {{host}}/users/{{ id }}
+{{name}}: {{value}}
+{"id": {{id}}, "name": "{{name}}"}`
+
+	tests := []token.Token{
+		{Type: token.Get, Literal: "GET", Line: 0},
+		{Type: token.String, Literal: "{{host}}/users/{{ id }}", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.String, Literal: "{{name}}", Line: 1},
+		{Type: token.Colon, Literal: ":", Line: 1},
+		{Type: token.String, Literal: "{{value}}", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.LeftBrace, Literal: "{", Line: 2},
+		{Type: token.String, Literal: `"id"`, Line: 2},
+		{Type: token.Colon, Literal: ":", Line: 2},
+		{Type: token.Variable, Literal: "{{id}}", Line: 2},
+		{Type: token.Comma, Literal: ",", Line: 2},
+		{Type: token.String, Literal: `"name"`, Line: 2},
+		{Type: token.Colon, Literal: ":", Line: 2},
+		{Type: token.String, Literal: `"{{name}}"`, Line: 2},
+		{Type: token.RightBrace, Literal: "}", Line: 2},
+		{Type: token.EOF, Literal: "", Line: 2},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenQuotedStrings(t *testing.T) {
+	input := `GET "http://test.com/a b"
+User-Agent: "my client \"1.0\""
+HTTP 200
+[Capture]
+id: "$.data[0].id"
+name: "unterminated`
+
+	tests := []token.Token{
+		{Type: token.Get, Literal: "GET", Line: 0},
+		{Type: token.String, Literal: `"http://test.com/a b"`, Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.String, Literal: "User-Agent", Line: 1},
+		{Type: token.Colon, Literal: ":", Line: 1},
+		{Type: token.String, Literal: `"my client \"1.0\""`, Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.Http, Literal: "HTTP", Line: 2},
+		{Type: token.Number, Literal: "200", Line: 2},
+		{Type: token.NewLine, Literal: "\n", Line: 2},
+		{Type: token.Capture, Literal: "[Capture]", Line: 3},
+		{Type: token.NewLine, Literal: "\n", Line: 3},
+		{Type: token.String, Literal: "id", Line: 4},
+		{Type: token.Colon, Literal: ":", Line: 4},
+		{Type: token.String, Literal: `"$.data[0].id"`, Line: 4},
+		{Type: token.NewLine, Literal: "\n", Line: 4},
+		{Type: token.String, Literal: "name", Line: 5},
+		{Type: token.Colon, Literal: ":", Line: 5},
+		{Type: token.String, Literal: `"unterminated`, Line: 5},
+		{Type: token.EOF, Literal: "", Line: 5},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenHeaderValues(t *testing.T) {
+	input := "GET http://test.com\n" +
+		"Authorization: Bearer abc\n" +
+		"Host: test.com:8080\n" +
+		"X-Empty:\n" +
+		"X-Padded :  \t padded  value \t\r\n" +
+		"X-Compact:value\n" +
+		"X-Hash: a#b # comment\n" +
+		"X-Unicode: héllo wörld\n" +
+		"ETag: W/\"xyzzy\"\n" +
+		"If-Match: \"a\", \"b\" # a list\n" +
+		"X-Msg: \"hello\" world\n" +
+		"X-Last:"
+
+	tests := []token.Token{
+		{Type: token.Get, Literal: "GET", Line: 0},
+		{Type: token.String, Literal: "http://test.com", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.String, Literal: "Authorization", Line: 1},
+		{Type: token.Colon, Literal: ":", Line: 1},
+		{Type: token.String, Literal: "Bearer abc", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.String, Literal: "Host", Line: 2},
+		{Type: token.Colon, Literal: ":", Line: 2},
+		{Type: token.String, Literal: "test.com:8080", Line: 2},
+		{Type: token.NewLine, Literal: "\n", Line: 2},
+		{Type: token.String, Literal: "X-Empty", Line: 3},
+		{Type: token.Colon, Literal: ":", Line: 3},
+		{Type: token.String, Literal: "", Line: 3},
+		{Type: token.NewLine, Literal: "\n", Line: 3},
+		{Type: token.String, Literal: "X-Padded", Line: 4},
+		{Type: token.Colon, Literal: ":", Line: 4},
+		{Type: token.String, Literal: "padded  value", Line: 4},
+		{Type: token.NewLine, Literal: "\n", Line: 4},
+		{Type: token.String, Literal: "X-Compact", Line: 5},
+		{Type: token.Colon, Literal: ":", Line: 5},
+		{Type: token.String, Literal: "value", Line: 5},
+		{Type: token.NewLine, Literal: "\n", Line: 5},
+		{Type: token.String, Literal: "X-Hash", Line: 6},
+		{Type: token.Colon, Literal: ":", Line: 6},
+		{Type: token.String, Literal: "a#b", Line: 6},
+		{Type: token.Comment, Literal: "# comment", Line: 6},
+		{Type: token.NewLine, Literal: "\n", Line: 6},
+		{Type: token.String, Literal: "X-Unicode", Line: 7},
+		{Type: token.Colon, Literal: ":", Line: 7},
+		{Type: token.String, Literal: "héllo wörld", Line: 7},
+		{Type: token.NewLine, Literal: "\n", Line: 7},
+		{Type: token.String, Literal: "ETag", Line: 8},
+		{Type: token.Colon, Literal: ":", Line: 8},
+		{Type: token.String, Literal: `W/"xyzzy"`, Line: 8},
+		{Type: token.NewLine, Literal: "\n", Line: 8},
+		{Type: token.String, Literal: "If-Match", Line: 9},
+		{Type: token.Colon, Literal: ":", Line: 9},
+		{Type: token.String, Literal: `"a", "b"`, Line: 9},
+		{Type: token.Comment, Literal: "# a list", Line: 9},
+		{Type: token.NewLine, Literal: "\n", Line: 9},
+		{Type: token.String, Literal: "X-Msg", Line: 10},
+		{Type: token.Colon, Literal: ":", Line: 10},
+		{Type: token.String, Literal: `"hello" world`, Line: 10},
+		{Type: token.NewLine, Literal: "\n", Line: 10},
+		{Type: token.String, Literal: "X-Last", Line: 11},
+		{Type: token.Colon, Literal: ":", Line: 11},
+		{Type: token.String, Literal: "", Line: 11},
+		{Type: token.EOF, Literal: "", Line: 11},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenWhitespace(t *testing.T) {
+	input := "GET  http://test.com\n\tX-Id: 1  \n{\n  \"a\": 1\n}"
+
+	tests := []token.Token{
+		{Type: token.Get, Literal: "GET", Line: 0},
+		{Type: token.Whitespace, Literal: "  ", Line: 0},
+		{Type: token.String, Literal: "http://test.com", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.Whitespace, Literal: "\t", Line: 1},
+		{Type: token.String, Literal: "X-Id", Line: 1},
+		{Type: token.Colon, Literal: ":", Line: 1},
+		{Type: token.Whitespace, Literal: " ", Line: 1},
+		{Type: token.String, Literal: "1", Line: 1},
+		{Type: token.Whitespace, Literal: "  ", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.LeftBrace, Literal: "{", Line: 2},
+		{Type: token.Whitespace, Literal: "\n  ", Line: 2},
+		{Type: token.String, Literal: `"a"`, Line: 3},
+		{Type: token.Colon, Literal: ":", Line: 3},
+		{Type: token.Whitespace, Literal: " ", Line: 3},
+		{Type: token.Number, Literal: "1", Line: 3},
+		{Type: token.Whitespace, Literal: "\n", Line: 3},
+		{Type: token.RightBrace, Literal: "}", Line: 4},
+		{Type: token.EOF, Literal: "", Line: 4},
+	}
+
+	l := New(input, EmitWhitespace())
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenAsserts(t *testing.T) {
+	input := `HTTP 200
+[Asserts]
+header "Content-Type" contains "json"
+jsonpath "$.count" >= 2
+jsonpath "$.a" == 1.5e3
+duration < 1000`
+
+	tests := []token.Token{
+		{Type: token.Http, Literal: "HTTP", Line: 0},
+		{Type: token.Number, Literal: "200", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.Asserts, Literal: "[Asserts]", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.String, Literal: "header", Line: 2},
+		{Type: token.String, Literal: `"Content-Type"`, Line: 2},
+		{Type: token.String, Literal: "contains", Line: 2},
+		{Type: token.String, Literal: `"json"`, Line: 2},
+		{Type: token.NewLine, Literal: "\n", Line: 2},
+		{Type: token.String, Literal: "jsonpath", Line: 3},
+		{Type: token.String, Literal: `"$.count"`, Line: 3},
+		{Type: token.String, Literal: ">=", Line: 3},
+		{Type: token.Number, Literal: "2", Line: 3},
+		{Type: token.NewLine, Literal: "\n", Line: 3},
+		{Type: token.String, Literal: "jsonpath", Line: 4},
+		{Type: token.String, Literal: `"$.a"`, Line: 4},
+		{Type: token.String, Literal: "==", Line: 4},
+		{Type: token.Number, Literal: "1.5e3", Line: 4},
+		{Type: token.NewLine, Literal: "\n", Line: 4},
+		{Type: token.String, Literal: "duration", Line: 5},
+		{Type: token.String, Literal: "<", Line: 5},
+		{Type: token.Number, Literal: "1000", Line: 5},
+		{Type: token.EOF, Literal: "", Line: 5},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenCaptures(t *testing.T) {
+	input := `HTTP 200
+[Capture]
+token: header "Authorization"
+id: $.data[0].id
+name: $.data[?(@.id > 1)].name # filtered
+GET http://test.com
+X-Token: Bearer {{token}}`
+
+	tests := []token.Token{
+		{Type: token.Http, Literal: "HTTP", Line: 0},
+		{Type: token.Number, Literal: "200", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.Capture, Literal: "[Capture]", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.String, Literal: "token", Line: 2},
+		{Type: token.Colon, Literal: ":", Line: 2},
+		{Type: token.String, Literal: "header", Line: 2},
+		{Type: token.String, Literal: `"Authorization"`, Line: 2},
+		{Type: token.NewLine, Literal: "\n", Line: 2},
+		{Type: token.String, Literal: "id", Line: 3},
+		{Type: token.Colon, Literal: ":", Line: 3},
+		{Type: token.String, Literal: "$.data[0].id", Line: 3},
+		{Type: token.NewLine, Literal: "\n", Line: 3},
+		{Type: token.String, Literal: "name", Line: 4},
+		{Type: token.Colon, Literal: ":", Line: 4},
+		{Type: token.String, Literal: "$.data[?(@.id > 1)].name", Line: 4},
+		{Type: token.Comment, Literal: "# filtered", Line: 4},
+		{Type: token.NewLine, Literal: "\n", Line: 4},
+		{Type: token.Get, Literal: "GET", Line: 5},
+		{Type: token.String, Literal: "http://test.com", Line: 5},
+		{Type: token.NewLine, Literal: "\n", Line: 5},
+		{Type: token.String, Literal: "X-Token", Line: 6},
+		{Type: token.Colon, Literal: ":", Line: 6},
+		{Type: token.String, Literal: "Bearer {{token}}", Line: 6},
+		{Type: token.EOF, Literal: "", Line: 6},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
+func TestNextTokenHTTPFile(t *testing.T) {
+	input := `@host = https://test.com
+// list the todos
+GET {{host}}/todos HTTP/1.1
+Accept: */*
+###
+X-Version: HTTP/2`
+
+	tests := []token.Token{
+		{Type: token.Definition, Literal: "@host = https://test.com", Line: 0},
+		{Type: token.NewLine, Literal: "\n", Line: 0},
+		{Type: token.Comment, Literal: "// list the todos", Line: 1},
+		{Type: token.NewLine, Literal: "\n", Line: 1},
+		{Type: token.Get, Literal: "GET", Line: 2},
+		{Type: token.String, Literal: "{{host}}/todos", Line: 2},
+		{Type: token.Http, Literal: "HTTP/1.1", Line: 2},
+		{Type: token.NewLine, Literal: "\n", Line: 2},
+		{Type: token.String, Literal: "Accept", Line: 3},
+		{Type: token.Colon, Literal: ":", Line: 3},
+		{Type: token.String, Literal: "*/*", Line: 3},
+		{Type: token.NewLine, Literal: "\n", Line: 3},
+		{Type: token.Comment, Literal: "###", Line: 4},
+		{Type: token.NewLine, Literal: "\n", Line: 4},
+		{Type: token.String, Literal: "X-Version", Line: 5},
+		{Type: token.Colon, Literal: ":", Line: 5},
+		{Type: token.String, Literal: "HTTP/2", Line: 5},
+		{Type: token.EOF, Literal: "", Line: 5},
+	}
+
+	assertLexerMatches(t, New(input, HTTPFile()), tests)
+
+	// without the option, the dialect is not recognized
+	l := New("@host = a\nGET a HTTP/1.1")
+	if tok := l.NextToken(); tok.Type == token.Definition {
+		t.Fatalf("error: unexpected definition %q", tok.Literal)
+	}
+}
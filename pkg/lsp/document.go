@@ -0,0 +1,369 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf16"
+
+	"nug/pkg/ast"
+	"nug/pkg/format"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+	"nug/pkg/token"
+)
+
+// document is an open nugget file, parsed with the Recover option so the
+// entries before and after an error are still known
+type document struct {
+	uri      string
+	text     string
+	lines    []string
+	httpFile bool        // the document is in the .http dialect
+	nugget   *ast.Nugget // nil when no entry could be parsed
+	errs     parser.ErrorList
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+
+	var opts []lexer.Option
+	if ext := path.Ext(uri); ext == ".http" || ext == ".rest" {
+		doc.httpFile = true
+		opts = append(opts, lexer.HTTPFile())
+	}
+	p := parser.New(lexer.New(text, opts...))
+	root, err := p.ParseProgram(parser.Recover())
+	doc.nugget = root.RootValue
+	errors.As(err, &doc.errs)
+	return doc
+}
+
+// diagnostics returns the syntax errors of the document, each one covers the
+// token it was found at
+func (d *document) diagnostics() []Diagnostic {
+	diags := []Diagnostic{}
+	for _, e := range d.errs {
+		start := d.position(token.Position{Line: e.Line, Column: e.Column})
+		end := start
+		if found := e.Found.EndPos; found.Line == e.Line && found.Column > e.Column {
+			end = d.position(found)
+		}
+		diags = append(diags, Diagnostic{
+			Range:    Range{Start: start, End: end},
+			Severity: SeverityError,
+			Source:   "nug",
+			Message:  e.Msg,
+		})
+	}
+	return diags
+}
+
+// completion returns what can be written at pos: a variable inside `{{`, a
+// section after `[`, or else a method, a version, a header or a section at
+// the start of a line
+func (d *document) completion(pos Position) interface{} {
+	line, col := d.line(pos)
+	prefix := string([]rune(line)[:col])
+	items := []CompletionItem{}
+
+	if open := strings.LastIndex(prefix, "{{"); open != -1 && !strings.Contains(prefix[open:], "}}") {
+		start := Position{Line: pos.Line, Character: utf16Len(prefix[:open+2])}
+		seen := map[string]bool{}
+		if d.nugget != nil {
+			for _, v := range d.nugget.Variables {
+				if seen[v.Key] {
+					continue
+				}
+				seen[v.Key] = true
+				items = append(items, CompletionItem{
+					Label:    v.Key,
+					Kind:     KindVariable,
+					Detail:   "defined as " + v.Value,
+					TextEdit: &TextEdit{Range: Range{Start: start, End: pos}, NewText: v.Key + "}}"},
+				})
+			}
+		}
+		for _, capture := range d.captures() {
+			if seen[capture.Name] {
+				continue
+			}
+			seen[capture.Name] = true
+			items = append(items, CompletionItem{
+				Label:    capture.Name,
+				Kind:     KindVariable,
+				Detail:   fmt.Sprintf("captured line %d", capture.StartPos.Line),
+				TextEdit: &TextEdit{Range: Range{Start: start, End: pos}, NewText: capture.Name + "}}"},
+			})
+		}
+		return items
+	}
+
+	word := strings.TrimLeft(prefix, " \t")
+	if strings.ContainsAny(word, " \t:") {
+		return items
+	}
+	edit := func(text string) *TextEdit {
+		start := Position{Line: pos.Line, Character: utf16Len(prefix[:len(prefix)-len(word)])}
+		return &TextEdit{Range: Range{Start: start, End: pos}, NewText: text}
+	}
+
+	for _, section := range sections {
+		items = append(items, CompletionItem{Label: section, Kind: KindKeyword, Detail: "section", TextEdit: edit(section)})
+	}
+	if strings.HasPrefix(word, "[") {
+		return items
+	}
+	for _, method := range methods {
+		items = append(items, CompletionItem{Label: method, Kind: KindKeyword, Detail: "method", TextEdit: edit(method + " ")})
+	}
+	for _, version := range versions {
+		items = append(items, CompletionItem{Label: version, Kind: KindKeyword, Detail: "response", TextEdit: edit(version + " ")})
+	}
+	for _, header := range headers {
+		items = append(items, CompletionItem{Label: header, Kind: KindField, Detail: "header", TextEdit: edit(header + ": ")})
+	}
+	return items
+}
+
+var sections = []string{"[Capture]", "[Asserts]"}
+
+var methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "TRACE", "CONNECT"}
+
+var versions = []string{"HTTP", "HTTP/1.0", "HTTP/1.1", "HTTP/2", "HTTP/3"}
+
+var headers = []string{
+	"Accept", "Accept-Encoding", "Accept-Language", "Authorization", "Cache-Control",
+	"Connection", "Content-Length", "Content-Type", "Cookie", "Host", "If-Match",
+	"If-Modified-Since", "If-None-Match", "Origin", "Referer", "User-Agent",
+	"X-Api-Key", "X-Requested-With",
+}
+
+// hover describes the variable or the entry at pos
+func (d *document) hover(pos Position) interface{} {
+	if name, r, ok := d.variable(pos); ok {
+		text := fmt.Sprintf("`{{%s}}` is not captured in this file", name)
+		if v, ok := d.defined(name, pos); ok {
+			text = fmt.Sprintf("`{{%s}}` is defined line %d: `@%s = %s`", name, v.StartPos.Line, v.Key, v.Value)
+		} else if capture, ok := d.capture(name, pos); ok {
+			text = fmt.Sprintf("`{{%s}}` is captured line %d: `%s: %s`",
+				name, capture.StartPos.Line, capture.Name, describeQuery(capture.Query))
+		}
+		return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+	}
+
+	entry, ok := d.entry(pos)
+	if !ok {
+		return nil
+	}
+
+	req, res := entry.Req, entry.Res
+	parts := []string{fmt.Sprintf("**%s** `%s`", req.Line.Method, req.Line.Url)}
+	details := []string{count(len(req.Header), "header", "headers")}
+	if req.Body != nil {
+		details = append(details, "JSON body")
+	}
+	parts = append(parts, strings.Join(details, ", "))
+
+	if res.Version != "" {
+		status := res.StatusPattern
+		if status == "" {
+			status = fmt.Sprint(res.Status)
+		}
+		parts = append(parts, fmt.Sprintf("expects `%s %s`", res.Version, status))
+	}
+	if len(res.Capture) > 0 {
+		names := make([]string, len(res.Capture))
+		for i, capture := range res.Capture {
+			names[i] = "`" + capture.Name + "`"
+		}
+		parts = append(parts, "captures "+strings.Join(names, ", "))
+	}
+	if len(res.Asserts) > 0 {
+		parts = append(parts, count(len(res.Asserts), "assert", "asserts"))
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: strings.Join(parts, "\n\n")}}
+}
+
+func describeQuery(q ast.Query) string {
+	if q.Arg == "" {
+		return q.Kind
+	}
+	return fmt.Sprintf("%s %q", q.Kind, q.Arg)
+}
+
+func count(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// definition returns the line of the capture, or of the `@name = value`
+// definition, giving the variable at pos its value
+func (d *document) definition(pos Position) interface{} {
+	name, _, ok := d.variable(pos)
+	if !ok {
+		return nil
+	}
+	if v, ok := d.defined(name, pos); ok {
+		return Location{
+			URI:   d.uri,
+			Range: Range{Start: d.position(v.StartPos), End: d.position(v.EndPos)},
+		}
+	}
+	capture, ok := d.capture(name, pos)
+	if !ok {
+		return nil
+	}
+	return Location{
+		URI:   d.uri,
+		Range: Range{Start: d.position(capture.StartPos), End: d.position(capture.EndPos)},
+	}
+}
+
+// format returns the edit replacing the document with its formatted source,
+// nothing when it is already formatted and nil when it does not parse
+func (d *document) format() interface{} {
+	source := format.Source
+	if d.httpFile {
+		source = format.HTTPFileSource
+	}
+	formatted, err := source([]byte(d.text))
+	if err != nil {
+		return nil
+	}
+	if string(formatted) == d.text {
+		return []TextEdit{}
+	}
+
+	last := len(d.lines) - 1
+	end := Position{Line: last, Character: utf16Len(d.lines[last])}
+	return []TextEdit{{Range: Range{End: end}, NewText: string(formatted)}}
+}
+
+// variable returns the name and the range of the `{{name}}` reference at pos,
+// the cursor can be on its `{{` or right after its `}}`
+func (d *document) variable(pos Position) (string, Range, bool) {
+	line, _ := d.line(pos)
+
+	for offset := 0; ; {
+		open := strings.Index(line[offset:], "{{")
+		if open == -1 {
+			return "", Range{}, false
+		}
+		open += offset
+		end := strings.Index(line[open+2:], "}}")
+		if end == -1 {
+			return "", Range{}, false
+		}
+		end += open + 4
+
+		r := Range{
+			Start: Position{Line: pos.Line, Character: utf16Len(line[:open])},
+			End:   Position{Line: pos.Line, Character: utf16Len(line[:end])},
+		}
+		if r.Start.Character <= pos.Character && pos.Character <= r.End.Character {
+			name := strings.TrimSpace(line[open+2 : end-2])
+			return name, r, name != ""
+		}
+		offset = end
+	}
+}
+
+// captures returns the captures of the document, in order
+func (d *document) captures() []ast.Capture {
+	if d.nugget == nil {
+		return nil
+	}
+	var captures []ast.Capture
+	for _, entry := range d.nugget.Entries {
+		captures = append(captures, entry.Res.Capture...)
+	}
+	return captures
+}
+
+// capture returns the capture of name used at pos, which is the last one
+// before pos, or the first one when all of them come after
+func (d *document) capture(name string, pos Position) (ast.Capture, bool) {
+	var found ast.Capture
+	ok := false
+	for _, capture := range d.captures() {
+		if capture.Name != name {
+			continue
+		}
+		if capture.StartPos.Line-1 < pos.Line || !ok {
+			found, ok = capture, true
+		}
+	}
+	return found, ok
+}
+
+// defined returns the `@name = value` definition of name used at pos, unless
+// a capture before pos overrides it. The first definition is the one used,
+// the later ones do not override it.
+func (d *document) defined(name string, pos Position) (ast.KeyValue, bool) {
+	if d.nugget == nil {
+		return ast.KeyValue{}, false
+	}
+	if capture, ok := d.capture(name, pos); ok && capture.StartPos.Line-1 < pos.Line {
+		return ast.KeyValue{}, false
+	}
+	for _, v := range d.nugget.Variables {
+		if v.Key == name {
+			return v, true
+		}
+	}
+	return ast.KeyValue{}, false
+}
+
+// entry returns the entry whose lines hold pos, from its request line to the
+// request line of the next entry
+func (d *document) entry(pos Position) (ast.Entry, bool) {
+	if d.nugget == nil {
+		return ast.Entry{}, false
+	}
+	var found ast.Entry
+	ok := false
+	for _, entry := range d.nugget.Entries {
+		if entry.Req.StartPos.Line-1 > pos.Line {
+			break
+		}
+		found, ok = entry, true
+	}
+	return found, ok
+}
+
+// line returns the line of pos and the index, in runes, of pos in it
+func (d *document) line(pos Position) (string, int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return "", 0
+	}
+	line := d.lines[pos.Line]
+
+	units := 0
+	for i, char := range []rune(line) {
+		if units >= pos.Character {
+			return line, i
+		}
+		units += len(utf16.Encode([]rune{char}))
+	}
+	return line, len([]rune(line))
+}
+
+// position converts a position of the parser into a position of the protocol
+func (d *document) position(pos token.Position) Position {
+	line := pos.Line - 1
+	if line < 0 || line >= len(d.lines) {
+		return Position{Line: max(line, 0)}
+	}
+	runes := []rune(d.lines[line])
+	col := min(max(pos.Column-1, 0), len(runes))
+	return Position{Line: line, Character: utf16Len(string(runes[:col]))}
+}
+
+// utf16Len returns the length of s in UTF-16 code units
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
@@ -0,0 +1,186 @@
+package lsp
+
+// The lsp package is a language server for nugget files, speaking the Language
+// Server Protocol over a pair of streams such as stdin and stdout. It reports
+// the syntax errors of the documents, completes methods, headers, sections
+// and variables, shows what an entry does on hover, goes from a `{{name}}`
+// reference to the capture defining it, and formats documents.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoShutdown is returned by Serve when the client exits without asking
+// the server to shut down first
+var ErrNoShutdown = errors.New("exit without shutdown")
+
+// Server is a language server reading its messages from a reader and writing
+// to a writer
+type Server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+// New creates a Server reading the client messages from r and writing its own
+// to w
+func New(r io.Reader, w io.Writer) *Server {
+	return &Server{in: bufio.NewReader(r), out: w, docs: map[string]*document{}}
+}
+
+// Serve handles the messages of the client one after the other, until the
+// client sends `exit` or closes the stream
+func (s *Server) Serve() error {
+	for {
+		content, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		var rpcErr *Error
+		if errors.As(err, &rpcErr) {
+			if err := s.replyError(json.RawMessage("null"), rpcErr.Code, rpcErr.Message); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(content, &msg); err != nil {
+			if err := s.replyError(json.RawMessage("null"), CodeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers a request, or acts on a notification. The returned error is
+// an error writing to the client.
+func (s *Server) handle(msg message) error {
+	// a message with a null id is a notification too
+	isRequest := len(msg.ID) > 0 && string(msg.ID) != "null"
+
+	// after a shutdown only the exit notification is expected
+	if s.shutdown {
+		if isRequest {
+			return s.replyError(msg.ID, CodeInvalidRequest, fmt.Sprintf("request `%s` after shutdown", msg.Method))
+		}
+		return nil
+	}
+
+	var result interface{}
+	var err error
+	switch msg.Method {
+	case "initialize":
+		result = InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:           TextDocumentSyncFull,
+				CompletionProvider:         CompletionOptions{TriggerCharacters: []string{"[", "{"}},
+				HoverProvider:              true,
+				DefinitionProvider:         true,
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "nug"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			return s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			return s.update(params.TextDocument.URI, text)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+			return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+				URI:         params.TextDocument.URI,
+				Diagnostics: []Diagnostic{},
+			})
+		}
+	case "textDocument/completion":
+		result, err = s.withPosition(msg.Params, (*document).completion)
+	case "textDocument/hover":
+		result, err = s.withPosition(msg.Params, (*document).hover)
+	case "textDocument/definition":
+		result, err = s.withPosition(msg.Params, (*document).definition)
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			if doc, ok := s.docs[params.TextDocument.URI]; ok {
+				result = doc.format()
+			} else {
+				err = fmt.Errorf("unknown document `%s`", params.TextDocument.URI)
+			}
+		}
+	default:
+		if isRequest {
+			return s.replyError(msg.ID, CodeMethodNotFound, fmt.Sprintf("unknown method `%s`", msg.Method))
+		}
+		return nil // notifications the server has no use for
+	}
+
+	if !isRequest {
+		return nil
+	}
+	if err != nil {
+		return s.replyError(msg.ID, CodeInvalidParams, err.Error())
+	}
+	return writeMessage(s.out, response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+// withPosition runs a request made at a position of a document
+func (s *Server) withPosition(params json.RawMessage, f func(*document, Position) interface{}) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("unknown document `%s`", p.TextDocument.URI)
+	}
+	return f(doc, p.Position), nil
+}
+
+// update parses the new text of a document and publishes its diagnostics
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics(),
+	})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) replyError(id json.RawMessage, code int, msg string) error {
+	return writeMessage(s.out, errorResponse{JSONRPC: "2.0", ID: id, Error: &Error{Code: code, Message: msg}})
+}
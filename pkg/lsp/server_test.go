@@ -0,0 +1,405 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a Server through in-memory pipes
+type client struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	msgs chan map[string]interface{}
+	done chan error
+	id   int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, msgs: make(chan map[string]interface{}, 16), done: make(chan error, 1)}
+
+	go func() {
+		c.done <- New(inR, outW).Serve()
+		outW.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			content, err := readMessage(r)
+			if err != nil {
+				close(c.msgs)
+				return
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(content, &msg); err != nil {
+				t.Errorf("error: invalid message %s", content)
+			}
+			c.msgs <- msg
+		}
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return c
+}
+
+func (c *client) send(method string, id int, params interface{}) {
+	c.t.Helper()
+
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if id != 0 {
+		msg["id"] = id
+	}
+	if err := writeMessage(c.in, msg); err != nil {
+		c.t.Fatalf("error: %v", err)
+	}
+}
+
+// request sends a request and returns its response
+func (c *client) request(method string, params interface{}) map[string]interface{} {
+	c.t.Helper()
+
+	c.id++
+	c.send(method, c.id, params)
+	msg := c.receive()
+	if id, _ := msg["id"].(float64); int(id) != c.id {
+		c.t.Fatalf("error: expected the response to request %d, got: %v", c.id, msg)
+	}
+	return msg
+}
+
+func (c *client) receive() map[string]interface{} {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("error: the server closed its output")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("error: no message from the server")
+	}
+	return nil
+}
+
+// result decodes the result of a response into v
+func (c *client) result(msg map[string]interface{}, v interface{}) {
+	c.t.Helper()
+
+	if msg["error"] != nil {
+		c.t.Fatalf("error: %v", msg["error"])
+	}
+	data, _ := json.Marshal(msg["result"])
+	if err := json.Unmarshal(data, v); err != nil {
+		c.t.Fatalf("error: %v", err)
+	}
+}
+
+const uri = "file:///todos.nug"
+
+const source = `POST https://todos.com/login
+HTTP 200
+[Capture]
+token: $.token
+
+get https://todos.com/todos
+Authorization: Bearer {{token}}
+HTTP 200
+[Asserts]
+status == 200
+`
+
+func position(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     Position{Line: line, Character: character},
+	}
+}
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+
+	var init InitializeResult
+	c.result(c.request("initialize", map[string]interface{}{}), &init)
+	if !init.Capabilities.HoverProvider || init.Capabilities.TextDocumentSync != TextDocumentSyncFull {
+		t.Fatalf("error: unexpected capabilities %+v", init.Capabilities)
+	}
+	c.send("initialized", 0, map[string]interface{}{})
+
+	// diagnostics
+	c.send("textDocument/didOpen", 0, map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: uri, LanguageID: "nug", Version: 1, Text: "GET https://todos.com\nHTTP abc\n"},
+	})
+	var diags PublishDiagnosticsParams
+	c.result(map[string]interface{}{"result": c.receive()["params"]}, &diags)
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("error: expected 1 diagnostic, got: %+v", diags.Diagnostics)
+	}
+	diag := diags.Diagnostics[0]
+	expected := Range{Start: Position{Line: 1, Character: 5}, End: Position{Line: 1, Character: 8}}
+	if diag.Range != expected || diag.Severity != SeverityError || diag.Message != "expected status code, got: `abc`" {
+		t.Fatalf("error: unexpected diagnostic %+v", diag)
+	}
+
+	c.send("textDocument/didChange", 0, map[string]interface{}{
+		"textDocument":   TextDocumentIdentifier{URI: uri},
+		"contentChanges": []map[string]string{{"text": source}},
+	})
+	c.result(map[string]interface{}{"result": c.receive()["params"]}, &diags)
+	if diags.URI != uri || diags.Diagnostics == nil || len(diags.Diagnostics) != 0 {
+		t.Fatalf("error: expected no diagnostic, got: %+v", diags)
+	}
+
+	// completion
+	completionTests := [...]struct {
+		line, character int
+		label           string
+		newText         string
+		count           int
+	}{
+		{5, 2, "GET", "GET ", -1},
+		{6, 0, "Content-Type", "Content-Type: ", -1},
+		{8, 1, "[Capture]", "[Capture]", 2},
+		{6, 24, "token", "token}}", 1},
+		{6, 16, "", "", 0},
+	}
+	for _, test := range completionTests {
+		var items []CompletionItem
+		c.result(c.request("textDocument/completion", position(test.line, test.character)), &items)
+		if test.count != -1 && len(items) != test.count {
+			t.Fatalf("error: %d:%d - expected %d items, got: %+v", test.line, test.character, test.count, items)
+		}
+		if test.label == "" {
+			continue
+		}
+		found := false
+		for _, item := range items {
+			if item.Label == test.label && item.TextEdit != nil && item.TextEdit.NewText == test.newText {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("error: %d:%d - expected %q in %+v", test.line, test.character, test.label, items)
+		}
+	}
+
+	// hover
+	hoverTests := [...]struct {
+		line, character int
+		contains        string
+	}{
+		{6, 25, "`{{token}}` is captured line 4: `token: jsonpath \"$.token\"`"},
+		{7, 0, "**GET** `https://todos.com/todos`"},
+		{9, 3, "1 header\n\nexpects `HTTP 200`\n\n1 assert"},
+		{2, 0, "captures `token`"},
+	}
+	for _, test := range hoverTests {
+		var hover Hover
+		c.result(c.request("textDocument/hover", position(test.line, test.character)), &hover)
+		if !strings.Contains(hover.Contents.Value, test.contains) {
+			t.Fatalf("error: %d:%d - expected %q in %q", test.line, test.character, test.contains, hover.Contents.Value)
+		}
+	}
+
+	// definition
+	var location Location
+	c.result(c.request("textDocument/definition", position(6, 26)), &location)
+	expected = Range{Start: Position{Line: 3, Character: 0}, End: Position{Line: 3, Character: 14}}
+	if location.URI != uri || location.Range != expected {
+		t.Fatalf("error: unexpected definition %+v", location)
+	}
+	msg := c.request("textDocument/definition", position(6, 5))
+	if msg["result"] != nil {
+		t.Fatalf("error: expected no definition, got: %v", msg["result"])
+	}
+
+	// formatting
+	var edits []TextEdit
+	c.result(c.request("textDocument/formatting", map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: uri},
+	}), &edits)
+	if len(edits) != 1 || !strings.HasPrefix(edits[0].NewText, "POST https://todos.com/login\nHTTP 200\n") ||
+		!strings.Contains(edits[0].NewText, "\nGET https://todos.com/todos\n") {
+		t.Fatalf("error: unexpected edits %+v", edits)
+	}
+	expected = Range{End: Position{Line: 10, Character: 0}}
+	if edits[0].Range != expected {
+		t.Fatalf("error: expected the edit to replace the document, got: %+v", edits[0].Range)
+	}
+
+	// .http files
+	httpURI := "file:///todos.http"
+	c.send("textDocument/didOpen", 0, map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: httpURI, LanguageID: "http", Version: 1, Text: "@host = todos.com\n###\nget https://{{host}}\n"},
+	})
+	c.result(map[string]interface{}{"result": c.receive()["params"]}, &diags)
+	if len(diags.Diagnostics) != 0 {
+		t.Fatalf("error: expected no diagnostic, got: %+v", diags.Diagnostics)
+	}
+	var items []CompletionItem
+	c.result(c.request("textDocument/completion", map[string]interface{}{
+		"textDocument": map[string]string{"uri": httpURI},
+		"position":     Position{Line: 2, Character: 14},
+	}), &items)
+	if len(items) != 1 || items[0].Label != "host" || items[0].Detail != "defined as todos.com" {
+		t.Fatalf("error: unexpected items %+v", items)
+	}
+	var hover Hover
+	c.result(c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]string{"uri": httpURI},
+		"position":     Position{Line: 2, Character: 15},
+	}), &hover)
+	if hover.Contents.Value != "`{{host}}` is defined line 1: `@host = todos.com`" {
+		t.Fatalf("error: unexpected hover %q", hover.Contents.Value)
+	}
+	c.result(c.request("textDocument/definition", map[string]interface{}{
+		"textDocument": map[string]string{"uri": httpURI},
+		"position":     Position{Line: 2, Character: 15},
+	}), &location)
+	expected = Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 0, Character: 17}}
+	if location.URI != httpURI || location.Range != expected {
+		t.Fatalf("error: unexpected definition %+v", location)
+	}
+	c.result(c.request("textDocument/formatting", map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: httpURI},
+	}), &edits)
+	if len(edits) != 1 || edits[0].NewText != "@host = todos.com\n\n###\nGET https://{{host}}\n" {
+		t.Fatalf("error: unexpected edits %+v", edits)
+	}
+
+	// errors
+	msg = c.request("workspace/symbol", map[string]interface{}{})
+	if e, _ := msg["error"].(map[string]interface{}); e == nil || e["code"] != float64(CodeMethodNotFound) {
+		t.Fatalf("error: expected a method not found error, got: %v", msg)
+	}
+	msg = c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]string{"uri": "file:///missing.nug"},
+	})
+	if e, _ := msg["error"].(map[string]interface{}); e == nil || e["code"] != float64(CodeInvalidParams) {
+		t.Fatalf("error: expected an invalid params error, got: %v", msg)
+	}
+
+	c.request("shutdown", nil)
+	c.send("exit", 0, nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("error: expected Serve to return nil, got: %v", err)
+	}
+}
+
+func TestDocumentVariable(t *testing.T) {
+	doc := newDocument(uri, "GET https://{{a}}/{{b}}?q={{c\n")
+
+	tests := [...]struct {
+		character int
+		name      string
+		start     int
+	}{
+		{5, "", 0},
+		{12, "a", 12},
+		{14, "a", 12},
+		{17, "a", 12},
+		{18, "b", 18},
+		{20, "b", 18},
+		{23, "b", 18},
+		{27, "", 0},
+	}
+	for _, test := range tests {
+		name, r, ok := doc.variable(Position{Line: 0, Character: test.character})
+		if name != test.name || ok != (test.name != "") || (ok && r.Start.Character != test.start) {
+			t.Fatalf("error: %d - expected %q at %d, got: %q %+v", test.character, test.name, test.start, name, r)
+		}
+	}
+}
+
+// zeros reads zero bytes forever
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestServerMessageTooLarge(t *testing.T) {
+	message := func(content string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(content), content)
+	}
+	in := io.MultiReader(
+		strings.NewReader(fmt.Sprintf("Content-Length: %d\r\n\r\n", maxContentLength+1)),
+		io.LimitReader(zeros{}, maxContentLength+1),
+		strings.NewReader(message(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`)+message(`{"jsonrpc":"2.0","method":"exit"}`)),
+	)
+	var out bytes.Buffer
+	if err := New(in, &out).Serve(); err != nil {
+		t.Fatalf("error: expected Serve to return nil, got: %v", err)
+	}
+	expected := message(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"message too large, expected at most 67108864 bytes, got: 67108865"}}`) +
+		message(`{"jsonrpc":"2.0","id":1,"result":null}`)
+	if out.String() != expected {
+		t.Fatalf("error: unexpected output %q", out.String())
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	message := func(content string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(content), content)
+	}
+	in := strings.NewReader(message(`{"jsonrpc":"2.0","id":null,"method":"workspace/symbol"}`) +
+		message(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`) +
+		message(`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover"}`) +
+		message(`{"jsonrpc":"2.0","method":"textDocument/didOpen"}`) +
+		message(`{"jsonrpc":"2.0","method":"exit"}`))
+	var out bytes.Buffer
+	if err := New(in, &out).Serve(); err != nil {
+		t.Fatalf("error: expected Serve to return nil, got: %v", err)
+	}
+	expected := message(`{"jsonrpc":"2.0","id":1,"result":null}`) +
+		message(`{"jsonrpc":"2.0","id":2,"error":{"code":-32600,"message":"request `+"`textDocument/hover`"+` after shutdown"}}`)
+	if out.String() != expected {
+		t.Fatalf("error: unexpected output %q", out.String())
+	}
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.send("exit", 0, nil)
+	if err := <-c.done; err != ErrNoShutdown {
+		t.Fatalf("error: expected %v, got: %v", ErrNoShutdown, err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{"Content-Length: 2\r\n\r\n{}", ""},
+		{"Content-Type: application/json\r\nContent-Length: 2\r\n\r\n{}", ""},
+		{"\r\n{}", "missing Content-Length header"},
+		{"Content-Length: x\r\n\r\n", "invalid Content-Length: `x`"},
+		{"Content-Length: 5\r\n\r\n{}", "reading the content: unexpected EOF"},
+		{"Content-Length: 99999999999\r\n\r\n{}", "reading the content: EOF"},
+	}
+
+	for _, test := range tests {
+		content, err := readMessage(bufio.NewReader(strings.NewReader(test.input)))
+		if test.err == "" {
+			if err != nil || string(content) != "{}" {
+				t.Fatalf("error: %q - expected `{}`, got: %q, %v", test.input, content, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("error: %q - expected %q, got: %v", test.input, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,563 @@
+package openapi
+
+// The openapi package generates a nugget from an OpenAPI 3 document, in JSON
+// or YAML: one entry per operation, sending an example of its request and
+// expecting its success status. The parameters of the path and the required
+// parameters, headers and credentials are left as `{{name}}` variables.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/format"
+)
+
+// Error is an operation, or a part of one, that cannot be generated
+type Error struct {
+	Operation string // e.g. "GET /todos/{id}", "" for the whole document
+	Msg       string
+}
+
+func (e *Error) Error() string {
+	if e.Operation == "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Operation, e.Msg)
+}
+
+// ErrorList is the list of errors returned by Import. Use errors.As to get it
+// back from the returned error.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// document is the part of an OpenAPI document the entries are generated from
+type document struct {
+	OpenAPI string `json:"openapi"`
+	Servers []struct {
+		URL       string `json:"url"`
+		Variables map[string]struct {
+			Default string `json:"default"`
+		} `json:"variables"`
+	} `json:"servers"`
+	Paths    map[string]map[string]json.RawMessage `json:"paths"`
+	Security []map[string][]string                 `json:"security"`
+
+	// the whole document, to resolve the `$ref` pointers
+	root interface{}
+}
+
+type operation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Parameters  []json.RawMessage          `json:"parameters"`
+	RequestBody json.RawMessage            `json:"requestBody"`
+	Responses   map[string]json.RawMessage `json:"responses"`
+	Security    *[]map[string][]string     `json:"security"` // nil to use the security of the document
+}
+
+type parameter struct {
+	Name     string          `json:"name"`
+	In       string          `json:"in"` // "path", "query", "header" or "cookie"
+	Required bool            `json:"required"`
+	Example  json.RawMessage `json:"example"`
+}
+
+type requestBody struct {
+	Content map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema   json.RawMessage            `json:"schema"`
+	Example  json.RawMessage            `json:"example"`
+	Examples map[string]json.RawMessage `json:"examples"`
+}
+
+type securityScheme struct {
+	Type   string `json:"type"`   // "apiKey", "http", "oauth2" or "openIdConnect"
+	Name   string `json:"name"`   // name of the header of an apiKey
+	In     string `json:"in"`     // "header", "query" or "cookie" for an apiKey
+	Scheme string `json:"scheme"` // "bearer" or "basic" for http
+}
+
+// methods are the operations of a path item, in the order of the entries
+var methods = []string{"get", "put", "post", "patch", "delete", "head", "options", "trace"}
+
+// Import generates the entries of the operations of an OpenAPI 3 document,
+// sorted by path. A part of an operation that cannot be generated is reported
+// in the returned ErrorList, and an entry that does not parse back from its
+// source is left out. The entries are parsed back so their templates, bodies
+// and positions are filled in.
+func Import(data []byte) (*ast.Nugget, error) {
+	doc, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported document, expected OpenAPI 3, got: `%s`", doc.OpenAPI)
+	}
+
+	base := "{{base_url}}"
+	if len(doc.Servers) > 0 {
+		server := doc.Servers[0]
+		base = server.URL
+		for name, v := range server.Variables {
+			base = strings.ReplaceAll(base, "{"+name+"}", v.Default)
+		}
+		if !strings.Contains(base, "://") {
+			base = "{{base_url}}" + base
+		}
+		base = strings.TrimSuffix(base, "/")
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs ErrorList
+	var entries []ast.Entry
+	for _, path := range paths {
+		item := doc.Paths[path]
+		var shared []json.RawMessage
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				errs = append(errs, &Error{Operation: path, Msg: "invalid parameters"})
+			}
+		}
+
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			g := &generator{doc: doc, name: strings.ToUpper(method) + " " + path}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				errs = append(errs, &Error{Operation: g.name, Msg: fmt.Sprintf("invalid operation: %v", err)})
+				continue
+			}
+			op.Parameters = append(shared, op.Parameters...)
+			entry := g.entry(strings.ToUpper(method), base, path, op)
+			errs = append(errs, g.errs...)
+			// an entry that would not parse back is left out, with the parser error
+			parsed, err := format.ReparseEntry(entry)
+			if err != nil {
+				errs = append(errs, &Error{Operation: g.name, Msg: fmt.Sprintf("cannot convert the operation: %v", err)})
+				continue
+			}
+			entries = append(entries, parsed)
+		}
+	}
+
+	nugget := &ast.Nugget{}
+	if len(entries) > 0 {
+		nugget.Entries = entries
+		// the positions become the ones in the source of all the entries
+		reparsed, err := format.Reparse(nugget)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the entries back: %v", err)
+		}
+		nugget = reparsed
+	}
+
+	if len(errs) > 0 {
+		return nugget, errs
+	}
+	return nugget, nil
+}
+
+// decode reads a JSON or YAML document
+func decode(data []byte) (*document, error) {
+	var root interface{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		if err := d.Decode(&root); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+	} else {
+		var err error
+		if root, err = parseYAML(string(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	// the document is read through JSON, which YAML documents are turned into
+	data, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{root: root}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %v", err)
+	}
+	return doc, nil
+}
+
+// generator generates the entry of an operation and collects its errors
+type generator struct {
+	doc  *document
+	name string
+	errs ErrorList
+}
+
+func (g *generator) errorf(format string, args ...interface{}) {
+	g.errs = append(g.errs, &Error{Operation: g.name, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (g *generator) entry(method, base, path string, op operation) ast.Entry {
+	req := ast.Request{Type: "Request", Line: ast.Endpoint{Type: "Endpoint", Method: method}}
+
+	// the path parameters are variables, the required parameters too
+	var query []string
+	for _, raw := range op.Parameters {
+		var param parameter
+		if !g.resolve(raw, &param, "parameter") || !param.Required {
+			continue
+		}
+		switch param.In {
+		case "query":
+			query = append(query, url.QueryEscape(param.Name)+"={{"+variable(param.Name)+"}}")
+		case "header":
+			req.Header = append(req.Header, header(param.Name, "{{"+variable(param.Name)+"}}"))
+		case "cookie":
+			req.Header = append(req.Header, header("Cookie", param.Name+"={{"+variable(param.Name)+"}}"))
+		}
+	}
+
+	security := g.doc.Security
+	if op.Security != nil {
+		security = *op.Security
+	}
+	if len(security) > 0 {
+		headers, params := g.credentials(security[0])
+		req.Header = append(req.Header, headers...)
+		query = append(query, params...)
+	}
+
+	req.Line.Url = base + templatePath(path)
+	if len(query) > 0 {
+		req.Line.Url += "?" + strings.Join(query, "&")
+	}
+
+	if len(op.RequestBody) > 0 {
+		var body requestBody
+		if g.resolve(op.RequestBody, &body, "request body") {
+			req.Header, req.Body = g.body(req.Header, body)
+		}
+	}
+
+	entry := ast.Entry{Type: "Entry", Req: req, Res: g.response(op.Responses)}
+	var about []string
+	for _, s := range []string{op.OperationID, firstLine(strings.TrimSpace(op.Summary))} {
+		if s != "" {
+			about = append(about, s)
+		}
+	}
+	if len(about) > 0 {
+		entry.Comments = []ast.Comment{{Type: "Comment", Text: "# " + strings.Join(about, ": ")}}
+	}
+	return entry
+}
+
+// templatePath returns path with its `{name}` parameters turned into
+// `{{name}}` variables
+func templatePath(path string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(path, '{')
+		end := strings.IndexByte(path[start+1:], '}')
+		if start == -1 || end == -1 {
+			b.WriteString(path)
+			return b.String()
+		}
+		end += start + 1
+		b.WriteString(path[:start] + "{{" + variable(path[start+1:end]) + "}}")
+		path = path[end+1:]
+	}
+}
+
+// credentials returns the headers and the query parameters sending the
+// credentials of a security requirement, as variables named after their
+// scheme
+func (g *generator) credentials(requirement map[string][]string) ([]ast.KeyValue, []string) {
+	names := make([]string, 0, len(requirement))
+	for name := range requirement {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers []ast.KeyValue
+	var query []string
+	for _, name := range names {
+		var scheme securityScheme
+		ref := json.RawMessage(`{"$ref": "#/components/securitySchemes/` + pointerEscape(name) + `"}`)
+		if !g.resolve(ref, &scheme, "security scheme") {
+			continue
+		}
+		v := "{{" + variable(name) + "}}"
+		switch {
+		case scheme.Type == "apiKey" && scheme.In == "query":
+			query = append(query, url.QueryEscape(scheme.Name)+"="+v)
+		case scheme.Type == "apiKey" && scheme.In == "header":
+			headers = append(headers, header(scheme.Name, v))
+		case scheme.Type == "apiKey" && scheme.In == "cookie":
+			headers = append(headers, header("Cookie", scheme.Name+"="+v))
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic"):
+			headers = append(headers, header("Authorization", "Basic "+v))
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"),
+			scheme.Type == "oauth2", scheme.Type == "openIdConnect":
+			headers = append(headers, header("Authorization", "Bearer "+v))
+		default:
+			g.errorf("unsupported security scheme `%s`", name)
+		}
+	}
+	return headers, query
+}
+
+// body returns the example of the JSON content of a request body, with a
+// Content-Type header unless it is application/json, which the runner sends
+func (g *generator) body(headers []ast.KeyValue, body requestBody) ([]ast.KeyValue, *ast.Body) {
+	types := make([]string, 0, len(body.Content))
+	for t := range body.Content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	for _, t := range types {
+		if t != "application/json" && !strings.HasSuffix(t, "+json") {
+			continue
+		}
+		example := bytes.TrimSpace(g.example(body.Content[t]))
+		if len(example) == 0 {
+			example = []byte("{}")
+		}
+		if !strings.ContainsAny(string(example[:1]), "{[") {
+			g.errorf("unsupported body example, expected a JSON object or array")
+			return headers, nil
+		}
+
+		var raw bytes.Buffer
+		json.Indent(&raw, example, "", "    ")
+		if t != "application/json" {
+			headers = append(headers, header("Content-Type", t))
+		}
+		return headers, &ast.Body{Type: "Body", Raw: raw.String()}
+	}
+
+	if len(types) > 0 {
+		g.errorf("unsupported body of type `%s`, expected JSON", types[0])
+	}
+	return headers, nil
+}
+
+// example returns the example of a media type, the first of its examples or
+// one made from its schema
+func (g *generator) example(media mediaType) json.RawMessage {
+	if len(media.Example) > 0 {
+		return media.Example
+	}
+	if len(media.Examples) > 0 {
+		names := make([]string, 0, len(media.Examples))
+		for name := range media.Examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var example struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if g.resolve(media.Examples[names[0]], &example, "example") && len(example.Value) > 0 {
+			return example.Value
+		}
+	}
+	if len(media.Schema) > 0 {
+		data, _ := json.Marshal(g.fromSchema(media.Schema, 0))
+		return data
+	}
+	return nil
+}
+
+// fromSchema returns an example of a value of schema: its example or default,
+// or else a value of its type
+func (g *generator) fromSchema(raw json.RawMessage, depth int) interface{} {
+	var schema struct {
+		Type       interface{}                `json:"type"` // a string, or a list in OpenAPI 3.1
+		Example    json.RawMessage            `json:"example"`
+		Examples   []json.RawMessage          `json:"examples"`
+		Default    json.RawMessage            `json:"default"`
+		Enum       []json.RawMessage          `json:"enum"`
+		Format     string                     `json:"format"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Items      json.RawMessage            `json:"items"`
+		AllOf      []json.RawMessage          `json:"allOf"`
+		OneOf      []json.RawMessage          `json:"oneOf"`
+		AnyOf      []json.RawMessage          `json:"anyOf"`
+	}
+	if depth > 8 || !g.resolve(raw, &schema, "schema") {
+		return nil
+	}
+
+	for _, v := range [][]byte{schema.Example, schema.Default} {
+		if len(v) > 0 {
+			return json.RawMessage(v)
+		}
+	}
+	if len(schema.Examples) > 0 {
+		return schema.Examples[0]
+	}
+	if len(schema.Enum) > 0 {
+		return schema.Enum[0]
+	}
+	if len(schema.AllOf) > 0 {
+		merged := map[string]interface{}{}
+		for _, part := range schema.AllOf {
+			if m, ok := g.fromSchema(part, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	if choices := append(schema.OneOf, schema.AnyOf...); len(choices) > 0 {
+		return g.fromSchema(choices[0], depth+1)
+	}
+
+	t, _ := schema.Type.(string)
+	if list, ok := schema.Type.([]interface{}); ok && len(list) > 0 {
+		t, _ = list[0].(string)
+	}
+	if t == "" && len(schema.Properties) > 0 {
+		t = "object"
+	}
+	switch t {
+	case "object":
+		obj := map[string]interface{}{}
+		for name, prop := range schema.Properties {
+			obj[name] = g.fromSchema(prop, depth+1)
+		}
+		return obj
+	case "array":
+		if len(schema.Items) == 0 {
+			return []interface{}{}
+		}
+		return []interface{}{g.fromSchema(schema.Items, depth+1)}
+	case "string":
+		switch schema.Format {
+		case "date":
+			return "2024-01-01"
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		}
+		return "string"
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	}
+	return nil
+}
+
+// response returns the response expecting the success status of an
+// operation: its lowest 2xx status, or any 2xx status
+func (g *generator) response(responses map[string]json.RawMessage) ast.Response {
+	res := ast.Response{Type: "Response", Version: "HTTP", StatusPattern: "2xx"}
+	for code := range responses {
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 200 || status > 299 {
+			continue
+		}
+		if res.Status == 0 || status < res.Status {
+			res.Status, res.StatusPattern = status, ""
+		}
+	}
+	return res
+}
+
+// resolve decodes raw into v, the value of what, following its `$ref` pointer
+// to the document if it has one. It reports the errors and returns false.
+func (g *generator) resolve(raw json.RawMessage, v interface{}, what string) bool {
+	for i := 0; i < 16; i++ {
+		var ref struct {
+			Ref string `json:"$ref"`
+		}
+		if err := json.Unmarshal(raw, &ref); err != nil || ref.Ref == "" {
+			break
+		}
+		target, err := g.pointer(ref.Ref)
+		if err != nil {
+			g.errorf("%v", err)
+			return false
+		}
+		raw, _ = json.Marshal(target)
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		g.errorf("invalid %s", what)
+		return false
+	}
+	return true
+}
+
+// pointer returns the value of the document a local `$ref` points to
+func (g *generator) pointer(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported reference `%s`, expected a reference to the document", ref)
+	}
+	v := g.doc.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := v.(map[string]interface{})
+		if !ok || m[token] == nil {
+			return nil, fmt.Errorf("reference `%s` not found", ref)
+		}
+		v = m[token]
+	}
+	return v, nil
+}
+
+func pointerEscape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// variable returns name as a variable name, its characters other than
+// letters, digits, `_`, `-` and `.` are replaced by `_`
+func variable(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || c == '-' || c == '.' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func header(key, value string) ast.KeyValue {
+	return ast.KeyValue{Type: "KeyValue", Key: key, Value: value}
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i]
+	}
+	return s
+}
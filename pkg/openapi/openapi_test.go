@@ -0,0 +1,279 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"nug/pkg/format"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a: 1\nb: true\nc: null\nd: ~\ne: text", `{"a":1,"b":true,"c":null,"d":null,"e":"text"}`},
+		{"a:\n  b:\n    c: 1.5\n  d: x", `{"a":{"b":{"c":1.5},"d":"x"}}`},
+		{"- a\n- 2\n-\n  - b", `["a",2,["b"]]`},
+		{"a:\n- b: 1\n  c: 2\n- d", `{"a":[{"b":1,"c":2},"d"]}`},
+		{"a: [1, 'b', {c: d}]\ne: {f: [g, h],\n  i: j}", `{"a":[1,"b",{"c":"d"}],"e":{"f":["g","h"],"i":"j"}}`},
+		{"a: 'it''s'\nb: \"tab\\t\"\nc: '#1' # comment\nd: x#y", `{"a":"it's","b":"tab\t","c":"#1","d":"x#y"}`},
+		{"# comment\n\na: |\n  line 1\n\n  line 2\nb: >-\n  folded\n  text\nc: 1", `{"a":"line 1\n\nline 2\n","b":"folded text","c":1}`},
+		{"\"/todos/{id}\":\n  get: {}\n'200': ok", `{"/todos/{id}":{"get":{}},"200":"ok"}`},
+		{"a: plain text\n  going on", `{"a":"plain text going on"}`},
+		{"---\na: 1", `{"a":1}`},
+	}
+
+	for _, tt := range tests {
+		v, err := parseYAML(tt.input)
+		if err != nil {
+			t.Fatalf("error: %q: %v", tt.input, err)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if string(data) != tt.expected {
+			t.Fatalf("error: %q: expected %s, got: %s", tt.input, tt.expected, data)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a: 'b", "invalid YAML, line 1: unterminated string, expected `'`"},
+		{"a: [b, c", "invalid YAML, line 1: unterminated flow collection"},
+		{"a: *ref", "invalid YAML, line 1: unsupported anchor, alias or tag `*ref`"},
+		{"a: 1\na: 2", "invalid YAML, line 2: duplicate key `a`"},
+		{"a: 1\n- b", "invalid YAML, line 2: expected a key, got: `- b`"},
+		{"paths:\n\t/a: {}", "invalid YAML, line 2: unexpected tab in the indentation"},
+		{"a:\n  b: 1\n  \tc: 2", "invalid YAML, line 3: unexpected tab in the indentation"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseYAML(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("error: %q: expected %q, got: %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+const spec = `openapi: 3.0.3
+info:
+  title: Todos
+  version: 1.0.0
+servers:
+  - url: https://{env}.test.com/v1
+    variables:
+      env:
+        default: api
+security:
+  - bearer: []
+paths:
+  /todos:
+    get:
+      operationId: listTodos
+      summary: List the todos
+      parameters:
+        - name: page
+          in: query
+        - name: X-Tenant
+          in: header
+          required: true
+      responses:
+        '200':
+          description: OK
+    post:
+      operationId: createTodo
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Todo'
+      responses:
+        '201':
+          description: Created
+        '400':
+          description: Bad request
+  /todos/{todo-id}:
+    parameters:
+      - $ref: '#/components/parameters/TodoId'
+    put:
+      security:
+        - apiKey: []
+      requestBody:
+        content:
+          application/merge-patch+json:
+            example: {title: b}
+      responses:
+        2XX:
+          description: Updated
+    delete:
+      summary: |
+        Delete a todo
+        and its items
+      security: []
+      responses:
+        '204':
+          description: Deleted
+  /upload:
+    post:
+      requestBody:
+        content:
+          multipart/form-data: {}
+      responses:
+        default:
+          description: Done
+components:
+  parameters:
+    TodoId:
+      name: todo-id
+      in: path
+      required: true
+  schemas:
+    Todo:
+      type: object
+      properties:
+        title:
+          type: string
+          example: Buy milk
+        done:
+          type: boolean
+        tags:
+          type: array
+          items:
+            type: string
+        due:
+          type: string
+          format: date
+  securitySchemes:
+    bearer:
+      type: http
+      scheme: bearer
+    apiKey:
+      type: apiKey
+      in: query
+      name: api_key
+`
+
+func TestImport(t *testing.T) {
+	nugget, err := Import([]byte(spec))
+
+	var errs ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("error: expected 1 error, got: %v", err)
+	}
+	if expected := "POST /upload: unsupported body of type `multipart/form-data`, expected JSON"; errs[0].Error() != expected {
+		t.Fatalf("error: expected %q, got: %q", expected, errs[0].Error())
+	}
+
+	got := format.Nugget(nugget)
+	want := `# listTodos: List the todos
+GET https://api.test.com/v1/todos
+X-Tenant: {{X-Tenant}}
+Authorization: Bearer {{bearer}}
+HTTP 200
+
+# createTodo
+POST https://api.test.com/v1/todos
+Authorization: Bearer {{bearer}}
+{
+    "done": false,
+    "due": "2024-01-01",
+    "tags": [
+        "string"
+    ],
+    "title": "Buy milk"
+}
+HTTP 201
+
+PUT https://api.test.com/v1/todos/{{todo-id}}?api_key={{apiKey}}
+Content-Type: application/merge-patch+json
+{
+    "title": "b"
+}
+HTTP 2xx
+
+# Delete a todo
+DELETE https://api.test.com/v1/todos/{{todo-id}}
+HTTP 204
+
+POST https://api.test.com/v1/upload
+Authorization: Bearer {{bearer}}
+HTTP 2xx
+`
+	if got != want {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", want, got)
+	}
+	if nugget.Entries[1].Req.Body.Value == nil {
+		t.Fatal("error: expected the body to be parsed")
+	}
+}
+
+func TestImportJSON(t *testing.T) {
+	input := `{
+  "openapi": "3.1.0",
+  "paths": {
+    "/users/{id}": {
+      "patch": {
+        "parameters": [{"name": "id", "in": "path", "required": true}],
+        "requestBody": {
+          "content": {
+            "application/json": {
+              "examples": {"b": {"value": {"name": "b"}}, "a": {"value": {"name": "a"}}}
+            }
+          }
+        },
+        "responses": {"200": {}, "202": {}}
+      }
+    }
+  }
+}`
+	nugget, err := Import([]byte(input))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := "PATCH {{base_url}}/users/{{id}}\n{\n    \"name\": \"a\"\n}\nHTTP 200\n"
+	if got := format.Nugget(nugget); got != want {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"swagger": "2.0"}`, "unsupported document, expected OpenAPI 3, got: ``"},
+		{"openapi: 3.0.0\npaths:\n  /a:\n    get:\n      parameters:\n        - $ref: other.yaml#/p\n",
+			"GET /a: unsupported reference `other.yaml#/p`, expected a reference to the document"},
+		{"openapi: 3.0.0\npaths:\n  /a:\n    get:\n      parameters:\n        - $ref: '#/components/parameters/p'\n",
+			"GET /a: reference `#/components/parameters/p` not found"},
+		{"openapi: 3.0.0\npaths:\n  /a:\n    post:\n      requestBody:\n        content:\n          application/json:\n            example: text\n",
+			"POST /a: unsupported body example, expected a JSON object or array"},
+		{"openapi: 3.0.0\nsecurity:\n  - mtls: []\ncomponents:\n  securitySchemes:\n    mtls:\n      type: mutualTLS\npaths:\n  /a:\n    get: {}\n",
+			"GET /a: unsupported security scheme `mtls`"},
+		{"{", "invalid JSON: unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		_, err := Import([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("error: %q: expected %q, got: %v", tt.input, tt.expected, err)
+		}
+	}
+
+	// an entry that does not parse back is left out, the others are kept
+	input := "openapi: 3.0.0\npaths:\n  /a:\n    get:\n      parameters:\n        - {name: X Y, in: header, required: true}\n  /b:\n    get: {}\n"
+	nugget, err := Import([]byte(input))
+	if err == nil || err.Error() != "GET /a: cannot convert the operation: line 2, column 3: expected `:`, got: `Y:`" {
+		t.Fatalf("error: unexpected error %v", err)
+	}
+	if got := format.Nugget(nugget); got != "GET {{base_url}}/b\nHTTP 2xx\n" {
+		t.Fatalf("error: unexpected entries:\n%s", got)
+	}
+}
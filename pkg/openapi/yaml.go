@@ -0,0 +1,512 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parseYAML decodes the YAML src into the values encoding/json decodes into
+// an interface{}, with json.Number for the numbers. It reads the subset of
+// YAML that OpenAPI documents are written in: block mappings and sequences,
+// flow collections, plain, quoted, literal (`|`) and folded (`>`) scalars,
+// and comments. Anchors, aliases, tags and multiple documents are not
+// supported.
+func parseYAML(src string) (interface{}, error) {
+	p := &yamlParser{lines: strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")}
+
+	// skip a leading document marker
+	if p.next() && strings.TrimRight(p.lines[p.n], " ") == "---" {
+		p.n++
+	}
+	if !p.next() {
+		return nil, nil
+	}
+
+	v, err := p.node(p.indent())
+	if err != nil {
+		return nil, err
+	}
+	if p.next() && strings.TrimRight(p.lines[p.n], " ") != "..." {
+		return nil, p.errorf("unexpected `%s`", strings.TrimSpace(p.lines[p.n]))
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []string
+	n     int // index of the current line
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid YAML, line %d: %s", p.n+1, fmt.Sprintf(format, args...))
+}
+
+// next skips the blank and comment lines, and reports whether a line is left
+func (p *yamlParser) next() bool {
+	for ; p.n < len(p.lines); p.n++ {
+		content := strings.TrimSpace(p.lines[p.n])
+		if content != "" && !strings.HasPrefix(content, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// indent returns the indentation of the current line
+func (p *yamlParser) indent() int {
+	return len(p.lines[p.n]) - len(strings.TrimLeft(p.lines[p.n], " "))
+}
+
+// content returns the current line without its indentation
+func (p *yamlParser) content() string {
+	return strings.TrimLeft(p.lines[p.n], " ")
+}
+
+// node parses the node starting on the current line, indented by indent
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	content := p.content()
+	if strings.HasPrefix(content, "\t") {
+		return nil, p.errorf("unexpected tab in the indentation")
+	}
+	if content == "-" || strings.HasPrefix(content, "- ") {
+		return p.sequence(indent)
+	}
+	if _, _, ok, err := splitKey(content); err != nil {
+		return nil, p.errorf("%v", err)
+	} else if ok {
+		return p.mapping(indent)
+	}
+
+	line := p.n
+	p.n++
+	return p.scalar(content, indent-1, line)
+}
+
+// child parses the value of a key or of a sequence item written on the lines
+// after it: a node indented by more than indent, or a sequence at indent for
+// the value of a key
+func (p *yamlParser) child(indent int, key bool) (interface{}, error) {
+	if !p.next() {
+		return nil, nil
+	}
+	i := p.indent()
+	if i > indent {
+		return p.node(i)
+	}
+	if i == indent && key {
+		if content := p.content(); content == "-" || strings.HasPrefix(content, "- ") {
+			return p.sequence(indent)
+		}
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	for p.next() && p.indent() == indent {
+		// a key after tabs would be read as a key of this mapping
+		if strings.HasPrefix(p.content(), "\t") {
+			return nil, p.errorf("unexpected tab in the indentation")
+		}
+		key, rest, ok, err := splitKey(p.content())
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if !ok {
+			return nil, p.errorf("expected a key, got: `%s`", strings.TrimSpace(p.content()))
+		}
+		if _, found := m[key]; found {
+			return nil, p.errorf("duplicate key `%s`", key)
+		}
+
+		line := p.n
+		p.n++
+		var v interface{}
+		if rest == "" {
+			v, err = p.child(indent, true)
+		} else {
+			v, err = p.scalar(rest, indent, line)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	if p.next() && p.indent() > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return m, nil
+}
+
+func (p *yamlParser) sequence(indent int) ([]interface{}, error) {
+	s := []interface{}{}
+	for p.next() && p.indent() == indent {
+		content := p.content()
+		if content != "-" && !strings.HasPrefix(content, "- ") {
+			break
+		}
+
+		rest := strings.TrimLeft(content[1:], " ")
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			p.n++
+			v, err := p.child(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			continue
+		}
+
+		// the item is read as if it started on a line of its own, indented
+		// like the text after the `-`
+		itemIndent := indent + len(content) - len(rest)
+		p.lines[p.n] = strings.Repeat(" ", itemIndent) + rest
+		v, err := p.node(itemIndent)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+// scalar parses the value text found on line, after a key or a `-` indented
+// by indent. Block scalars, flow collections and plain scalars can go on over
+// the next lines.
+func (p *yamlParser) scalar(text string, indent, line int) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return p.blockScalar(text, indent)
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{"):
+		for {
+			f := &flow{s: text}
+			v, err := f.value()
+			if err == errFlowEnd && p.n < len(p.lines) {
+				text += " " + strings.TrimSpace(p.lines[p.n])
+				p.n++
+				continue
+			}
+			if err == nil {
+				if rest := strings.TrimSpace(f.s[f.pos:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					err = fmt.Errorf("unexpected `%s`", rest)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid YAML, line %d: %v", line+1, err)
+			}
+			return v, nil
+		}
+	case strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "!"):
+		return nil, fmt.Errorf("invalid YAML, line %d: unsupported anchor, alias or tag `%s`", line+1, text)
+	case strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'"):
+		s, rest, err := quoted(text)
+		if err == nil && rest != "" && !strings.HasPrefix(rest, "#") {
+			err = fmt.Errorf("unexpected `%s`", rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML, line %d: %v", line+1, err)
+		}
+		return s, nil
+	}
+
+	// a plain scalar, continued by the lines indented by more than indent
+	text = stripComment(text)
+	for p.n < len(p.lines) {
+		next := strings.TrimSpace(p.lines[p.n])
+		if next == "" || strings.HasPrefix(next, "#") || len(p.lines[p.n])-len(strings.TrimLeft(p.lines[p.n], " ")) <= indent {
+			break
+		}
+		if _, _, ok, _ := splitKey(next); ok {
+			break
+		}
+		text += " " + stripComment(next)
+		p.n++
+	}
+	return plain(text), nil
+}
+
+// blockScalar reads a literal (`|`) or folded (`>`) scalar, whose lines are
+// indented by more than indent. The header can say to strip (`-`) or keep
+// (`+`) the final line breaks.
+func (p *yamlParser) blockScalar(header string, indent int) (string, error) {
+	header = stripComment(header)
+	folded := header[0] == '>'
+	chomp := byte(0)
+	contentIndent := -1
+	for _, c := range header[1:] {
+		switch {
+		case c == '-' || c == '+':
+			chomp = byte(c)
+		case '1' <= c && c <= '9':
+			contentIndent = indent + 1 + int(c-'1')
+		default:
+			return "", p.errorf("invalid block scalar header `%s`", header)
+		}
+	}
+
+	var lines []string
+	for p.n < len(p.lines) {
+		line := p.lines[p.n]
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			lines = append(lines, "")
+			p.n++
+			continue
+		}
+		i := len(line) - len(trimmed)
+		if contentIndent == -1 {
+			contentIndent = i
+		}
+		if i <= indent || i < contentIndent {
+			break
+		}
+		lines = append(lines, line[contentIndent:])
+		p.n++
+	}
+
+	// the blank lines at the end are only line breaks
+	trailing := 0
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	// a folded scalar joins its lines with spaces, an empty line is a line
+	// break and the more indented lines are kept as they are
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case !folded || strings.HasPrefix(line, " ") || strings.HasPrefix(prev, " ") || line == "":
+				b.WriteString("\n")
+			case prev == "":
+				// the empty line was the line break
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(line)
+	}
+
+	s := b.String()
+	switch chomp {
+	case '-':
+	case '+':
+		s += strings.Repeat("\n", trailing+1)
+	default:
+		if len(lines) > 0 {
+			s += "\n"
+		}
+	}
+	return s, nil
+}
+
+// splitKey splits `key: value` into its key and the rest of the line, ok is
+// false when content is not a key
+func splitKey(content string) (key, rest string, ok bool, err error) {
+	if strings.HasPrefix(content, `"`) || strings.HasPrefix(content, "'") {
+		key, rest, err := quoted(content)
+		if err != nil || !strings.HasPrefix(rest, ":") {
+			return "", "", false, nil
+		}
+		return key, strings.TrimSpace(rest[1:]), true, nil
+	}
+	if strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{") || strings.HasPrefix(content, "#") {
+		return "", "", false, nil
+	}
+
+	for i := 0; i < len(content); i++ {
+		if content[i] == '#' && i > 0 && content[i-1] == ' ' {
+			return "", "", false, nil
+		}
+		if content[i] == ':' && (i+1 == len(content) || content[i+1] == ' ') {
+			return strings.TrimSpace(content[:i]), strings.TrimSpace(stripCommentOnly(content[i+1:])), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// stripCommentOnly returns an empty string when s is only a comment, and s
+// otherwise
+func stripCommentOnly(s string) string {
+	if strings.HasPrefix(strings.TrimSpace(s), "#") {
+		return ""
+	}
+	return s
+}
+
+// stripComment removes the ` #` comment ending a plain scalar
+func stripComment(s string) string {
+	if i := strings.Index(s, " #"); i != -1 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// quoted reads the `"` or `'` string starting s, and returns it unquoted with
+// the rest of s after it
+func quoted(s string) (string, string, error) {
+	if s[0] == '\'' {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), strings.TrimSpace(s[i+1:]), nil
+		}
+		return "", "", fmt.Errorf("unterminated string, expected `'`")
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			var v string
+			if err := json.Unmarshal([]byte(s[:i+1]), &v); err != nil {
+				return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+			}
+			return v, strings.TrimSpace(s[i+1:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string, expected `\"`")
+}
+
+// plain returns the value of a plain scalar: null, a boolean, a number or a
+// string
+func plain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if (s[0] == '-' || '0' <= s[0] && s[0] <= '9') && json.Valid([]byte(s)) {
+		return json.Number(s)
+	}
+	return s
+}
+
+// errFlowEnd is returned when a flow collection goes on after the end of its
+// text
+var errFlowEnd = fmt.Errorf("unterminated flow collection")
+
+// flow parses a flow collection, like `[a, {b: 1}]`
+type flow struct {
+	s   string
+	pos int
+}
+
+func (f *flow) skip() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *flow) value() (interface{}, error) {
+	f.skip()
+	if f.pos == len(f.s) {
+		return nil, errFlowEnd
+	}
+
+	switch c := f.s[f.pos]; c {
+	case '[', '{':
+		f.pos++
+		closing := byte(']')
+		var seq []interface{}
+		var m map[string]interface{}
+		if c == '{' {
+			closing = '}'
+			m = map[string]interface{}{}
+		} else {
+			seq = []interface{}{}
+		}
+
+		for {
+			f.skip()
+			if f.pos == len(f.s) {
+				return nil, errFlowEnd
+			}
+			if f.s[f.pos] == closing {
+				f.pos++
+				if m != nil {
+					return m, nil
+				}
+				return seq, nil
+			}
+
+			if m != nil {
+				key, err := f.scalar(true)
+				if err != nil {
+					return nil, err
+				}
+				f.skip()
+				var v interface{}
+				if f.pos < len(f.s) && f.s[f.pos] == ':' {
+					f.pos++
+					if v, err = f.value(); err != nil {
+						return nil, err
+					}
+				}
+				m[fmt.Sprint(key)] = v
+			} else {
+				v, err := f.value()
+				if err != nil {
+					return nil, err
+				}
+				seq = append(seq, v)
+			}
+
+			f.skip()
+			if f.pos == len(f.s) {
+				return nil, errFlowEnd
+			}
+			switch f.s[f.pos] {
+			case ',':
+				f.pos++
+			case closing:
+			default:
+				return nil, fmt.Errorf("expected `,` or `%c`, got: `%c`", closing, f.s[f.pos])
+			}
+		}
+	default:
+		return f.scalar(false)
+	}
+}
+
+// scalar reads a quoted or plain scalar of a flow collection, a plain key
+// ends at a `:`
+func (f *flow) scalar(key bool) (interface{}, error) {
+	f.skip()
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		v, rest, err := quoted(f.s[f.pos:])
+		if err != nil {
+			return nil, err
+		}
+		f.pos = len(f.s) - len(rest)
+		return v, nil
+	}
+
+	start := f.pos
+	for f.pos < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.pos])) {
+		if f.s[f.pos] == ':' && (key || f.pos+1 == len(f.s) || f.s[f.pos+1] == ' ') {
+			break
+		}
+		f.pos++
+	}
+	text := strings.TrimSpace(f.s[start:f.pos])
+	if key {
+		return text, nil
+	}
+	return plain(text), nil
+}
@@ -0,0 +1,239 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/jsonpath"
+	"nug/pkg/token"
+	"nug/pkg/xpath"
+)
+
+// queryArgs tells for each query kind whether it takes an argument
+var queryArgs = map[string]bool{
+	"status":   false,
+	"header":   true,
+	"cookie":   true,
+	"jsonpath": true,
+	"regex":    true,
+	"xpath":    true,
+	"body":     false,
+	"url":      false,
+	"duration": false,
+}
+
+// predicateValues tells for each predicate whether it takes a value
+var predicateValues = map[string]bool{
+	"==":         true,
+	"!=":         true,
+	">":          true,
+	">=":         true,
+	"<":          true,
+	"<=":         true,
+	"contains":   true,
+	"startsWith": true,
+	"matches":    true,
+	"exists":     false,
+	"isInteger":  false,
+}
+
+// parseAssert parses a line of the `[Asserts]` section: a query, such as
+// `header "Content-Type"`, followed by a predicate, such as `contains "json"`.
+// The current token is the last one of the assert when it returns.
+func (p *Parser) parseAssert() ast.Assert {
+	assert := ast.Assert{Type: "Assert", StartPos: p.currentToken.StartPos}
+	assert.Comments = p.takeComments(false)
+
+	assert.Query = p.parseQuery()
+	if p.hasErrors() {
+		return ast.Assert{}
+	}
+	p.nextToken()
+
+	predicate := ast.Predicate{Type: "Predicate", Op: p.currentToken.Literal}
+	hasValue, ok := predicateValues[predicate.Op]
+	if !p.currentTokenTypeIs(token.String) || !ok {
+		p.parseError(fmt.Sprintf(
+			"expected a predicate, got: `%s`",
+			literal(p.currentToken),
+		), token.String)
+		return ast.Assert{}
+	}
+
+	if hasValue {
+		p.nextToken()
+		predicate.Value = p.parsePredicateValue(predicate.Op)
+		if p.hasErrors() {
+			return ast.Assert{}
+		}
+	}
+	assert.Predicate = predicate
+
+	assert.EndPos = p.currentToken.EndPos
+	assert.Comments = append(assert.Comments, p.takeComments(true)...)
+	return assert
+}
+
+// parseCapture parses a line of the `[Capture]` section: the name of the
+// variable, a `:` and a query, such as `id: jsonpath "$.data.id"`. A JSON path
+// alone, such as `id: $.data.id`, is a jsonpath query.
+func (p *Parser) parseCapture() ast.Capture {
+	capture := ast.Capture{Type: "Capture", StartPos: p.currentToken.StartPos}
+	capture.Comments = p.takeComments(false)
+
+	capture.Name = p.currentToken.Literal
+	if !isVariableName(capture.Name) {
+		p.parseError(fmt.Sprintf("invalid variable name: `%s`", capture.Name), token.String)
+		return ast.Capture{}
+	}
+	if !p.peekTokenTypeIs(token.Colon) {
+		p.errorAt(p.peekToken, fmt.Sprintf(
+			"expected `:`, got: `%s`",
+			literal(p.peekToken),
+		), token.Colon)
+		p.nextToken()
+		return ast.Capture{}
+	}
+	p.nextToken()
+	p.nextToken()
+
+	lit := p.currentToken.Literal
+	if p.currentTokenTypeIs(token.String) && (strings.HasPrefix(lit, "$") || strings.HasPrefix(lit, `"$`)) {
+		capture.Query = ast.Query{Type: "Query", Kind: "jsonpath", Arg: p.parseString()}
+		if !p.hasErrors() {
+			p.validateQuery(capture.Query)
+		}
+	} else {
+		capture.Query = p.parseQuery()
+	}
+	if p.hasErrors() {
+		return ast.Capture{}
+	}
+
+	capture.EndPos = p.currentToken.EndPos
+	capture.Comments = append(capture.Comments, p.takeComments(true)...)
+	return capture
+}
+
+// parseQuery parses a query kind and its argument. The current token is the
+// last one of the query when it returns.
+func (p *Parser) parseQuery() ast.Query {
+	query := ast.Query{Type: "Query", Kind: p.currentToken.Literal}
+	hasArg, ok := queryArgs[query.Kind]
+	if !p.currentTokenTypeIs(token.String) || !ok {
+		p.parseError(fmt.Sprintf(
+			"expected a query (status, header, cookie, jsonpath, regex, xpath, body, url or duration), got: `%s`",
+			literal(p.currentToken),
+		), token.String)
+		return ast.Query{}
+	}
+
+	if hasArg {
+		p.nextToken()
+		if !p.currentTokenTypeIs(token.String) {
+			p.parseError(fmt.Sprintf(
+				"expected string, got: `%s`",
+				literal(p.currentToken),
+			), token.String)
+			return ast.Query{}
+		}
+		query.Arg = p.parseString()
+		if p.hasErrors() {
+			return ast.Query{}
+		}
+		p.validateQuery(query)
+	}
+	return query
+}
+
+// validateQuery reports the errors of the argument of a query, which is the
+// current token. A malformed JSON path is reported at the offending char.
+func (p *Parser) validateQuery(query ast.Query) {
+	var err error
+	switch query.Kind {
+	case "jsonpath":
+		_, err = jsonpath.Parse(query.Arg)
+		if pathErr, ok := err.(*jsonpath.Error); ok {
+			p.templateError(argPos(p.currentToken, query.Arg, pathErr.Pos), err.Error())
+			return
+		}
+	case "xpath":
+		_, err = xpath.Parse(query.Arg)
+	case "regex":
+		if _, err = regexp.Compile(query.Arg); err != nil {
+			err = fmt.Errorf("invalid regex `%s`: %v", query.Arg, err)
+		}
+	}
+	if err != nil {
+		p.parseError(err.Error())
+	}
+}
+
+// argPos returns the position of the char at index i, in runes, of arg, the
+// value of t. The start of t is returned when escape sequences in t make it
+// unknown.
+func argPos(t token.Token, arg string, i int) token.Position {
+	prefix := ""
+	switch t.Literal {
+	case arg:
+	case `"` + arg + `"`:
+		prefix = `"`
+	default:
+		return t.StartPos
+	}
+	return advance(t.StartPos, prefix+string([]rune(arg)[:i]))
+}
+
+// parsePredicateValue parses the value compared by a predicate: a quoted
+// string, a number, true, false or null
+func (p *Parser) parsePredicateValue(op string) *ast.Literal {
+	lit := &ast.Literal{Type: "Literal"}
+
+	switch {
+	case p.currentTokenTypeIs(token.Number):
+		n, err := parseNumber(p.currentToken.Literal)
+		if err != nil {
+			p.parseError(err.Error())
+			return nil
+		}
+		lit.Value = n
+	case p.currentTokenTypeIs(token.String) && strings.HasPrefix(p.currentToken.Literal, `"`):
+		lit.Value = p.parseString()
+		if p.hasErrors() {
+			return nil
+		}
+	case p.currentTokenTypeIs(token.String) && p.currentToken.Literal == "true":
+		lit.Value = true
+	case p.currentTokenTypeIs(token.String) && p.currentToken.Literal == "false":
+		lit.Value = false
+	case p.currentTokenTypeIs(token.String) && p.currentToken.Literal == "null":
+		lit.Value = nil
+	default:
+		p.parseError(fmt.Sprintf(
+			"expected a value, got: `%s`",
+			literal(p.currentToken),
+		), token.String, token.Number)
+		return nil
+	}
+
+	if op == "startsWith" || op == "matches" {
+		s, ok := lit.Value.(string)
+		if !ok {
+			p.parseError(fmt.Sprintf(
+				"expected a string after `%s`, got: `%s`",
+				op, p.currentToken.Literal,
+			), token.String)
+			return nil
+		}
+		if op == "matches" {
+			if _, err := regexp.Compile(s); err != nil {
+				p.parseError(fmt.Sprintf("invalid regex `%s`: %v", s, err))
+				return nil
+			}
+		}
+	}
+
+	return lit
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"nug/pkg/token"
+)
+
+// Error is a syntax error found by the parser. Line and Column are 1-based,
+// Offset is the byte offset of the offending token in the input.
+type Error struct {
+	Line     int
+	Column   int
+	Offset   int
+	Expected []token.Type // token types that would have been valid, if known
+	Found    token.Token
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// ErrorList is the list of errors returned by ParseProgram. Use errors.As to
+// get it back from the returned error, or to get its first *Error.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the list, so errors.As and errors.Is can
+// inspect each of them.
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// literal returns the text of a token as shown in error messages, where a new
+// line is written as `\n`
+func literal(t token.Token) string {
+	if t.Type == token.NewLine {
+		return `\n`
+	}
+	return t.Literal
+}
@@ -0,0 +1,450 @@
+package parser
+
+// JSON request bodies are parsed with the same state machine approach as the
+// rest of the nugget grammar. Each parse function leaves the parser on the last
+// token of the value it parsed, the caller is in charge of moving forward.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"nug/pkg/ast"
+	"nug/pkg/token"
+)
+
+// jsonValues are the tokens that can start a JSON value
+var jsonValues = []token.Type{
+	token.LeftBrace, token.LeftBracket, token.String, token.Number,
+	token.True, token.False, token.Null, token.Variable,
+}
+
+// parseBody is called when a `{` or `[` token starts a line after the request
+// headers. The whole JSON value is validated and kept both as a tree and as
+// the raw text that will be sent to the server.
+func (p *Parser) parseBody() ast.Body {
+	body := ast.Body{
+		Type:     "Body",
+		Start:    p.currentToken.Start,
+		StartPos: p.currentToken.StartPos,
+	}
+
+	value := p.parseJSONValue()
+	if p.hasErrors() {
+		return ast.Body{}
+	}
+
+	body.Value = value
+	body.End = p.currentToken.End
+	body.EndPos = p.currentToken.EndPos
+	body.Raw = string(p.lexer.Input[body.Start:body.End])
+	body.Template = p.parseTemplate(body.Raw, body.StartPos)
+	if p.hasErrors() {
+		return ast.Body{}
+	}
+	return body
+}
+
+// parseJSONValue dispatches to the right parse function depending on the
+// current token.
+func (p *Parser) parseJSONValue() ast.Value {
+	switch p.currentToken.Type {
+	case token.LeftBrace:
+		return p.parseObject()
+	case token.LeftBracket:
+		return p.parseArray()
+	default:
+		return p.parseLiteral()
+	}
+}
+
+// parseObject is called when an open left brace `{` token is found
+func (p *Parser) parseObject() ast.Value {
+	obj := ast.Object{Type: "Object"}
+	objState := ast.ObjStart
+
+	for !p.currentTokenTypeIs(token.EOF) {
+		switch objState {
+		case ast.ObjStart:
+			if !p.currentTokenTypeIs(token.LeftBrace) {
+				p.parseError(fmt.Sprintf(
+					"expected `{`, got: `%s`",
+					p.currentToken.Literal,
+				), token.LeftBrace)
+				return nil
+			}
+			obj.Start = p.currentToken.Start
+			objState = ast.ObjOpen
+			p.nextToken()
+
+		case ast.ObjOpen:
+			if p.currentTokenTypeIs(token.RightBrace) {
+				obj.End = p.currentToken.End
+				return obj
+			}
+			prop := p.parseProperty()
+			if p.hasErrors() {
+				return nil
+			}
+			obj.Children = append(obj.Children, prop)
+			objState = ast.ObjProperty
+			p.nextToken()
+
+		case ast.ObjProperty:
+			if p.currentTokenTypeIs(token.RightBrace) {
+				obj.End = p.currentToken.End
+				return obj
+			}
+			if !p.currentTokenTypeIs(token.Comma) {
+				p.parseError(fmt.Sprintf(
+					"expected `,` or `}`, got: `%s`",
+					p.currentToken.Literal,
+				), token.Comma, token.RightBrace)
+				return nil
+			}
+			objState = ast.ObjComma
+			p.nextToken()
+
+		case ast.ObjComma:
+			prop := p.parseProperty()
+			if p.hasErrors() {
+				return nil
+			}
+			obj.Children = append(obj.Children, prop)
+			objState = ast.ObjProperty
+			p.nextToken()
+		}
+	}
+
+	p.parseError("unterminated object, expected `}`", token.RightBrace)
+	return nil
+}
+
+// parseProperty parses a `"key": value` pair of an object
+func (p *Parser) parseProperty() ast.Property {
+	prop := ast.Property{Type: "Property"}
+	propertyState := ast.PropertyStart
+
+	for !p.currentTokenTypeIs(token.EOF) {
+		switch propertyState {
+		case ast.PropertyStart:
+			if !p.currentTokenTypeIs(token.String) {
+				p.parseError(fmt.Sprintf(
+					"expected property key, got: `%s`",
+					p.currentToken.Literal,
+				), token.String)
+				return ast.Property{}
+			}
+			key, err := unquote(p.currentToken.Literal)
+			if err != nil {
+				p.parseError(err.Error())
+				return ast.Property{}
+			}
+			prop.Key = ast.Identifier{Type: "Identifier", Value: key}
+			propertyState = ast.PropertyKey
+			p.nextToken()
+
+		case ast.PropertyKey:
+			if !p.currentTokenTypeIs(token.Colon) {
+				p.parseError(fmt.Sprintf(
+					"expected `:`, got: `%s`",
+					p.currentToken.Literal,
+				), token.Colon)
+				return ast.Property{}
+			}
+			propertyState = ast.PropertyColon
+			p.nextToken()
+
+		case ast.PropertyColon:
+			prop.Value = p.parseJSONValue()
+			return prop
+		}
+	}
+
+	p.parseError("unterminated property, expected a value")
+	return ast.Property{}
+}
+
+// parseArray is called when an open left bracket `[` token is found
+func (p *Parser) parseArray() ast.Value {
+	arr := ast.Array{Type: "Array"}
+	arrayState := ast.ArrayStart
+
+	for !p.currentTokenTypeIs(token.EOF) {
+		switch arrayState {
+		case ast.ArrayStart:
+			if !p.currentTokenTypeIs(token.LeftBracket) {
+				p.parseError(fmt.Sprintf(
+					"expected `[`, got: `%s`",
+					p.currentToken.Literal,
+				), token.LeftBracket)
+				return nil
+			}
+			arr.Start = p.currentToken.Start
+			arrayState = ast.ArrayOpen
+			p.nextToken()
+
+		case ast.ArrayOpen:
+			if p.currentTokenTypeIs(token.RightBracket) {
+				arr.End = p.currentToken.End
+				return arr
+			}
+			value := p.parseJSONValue()
+			if p.hasErrors() {
+				return nil
+			}
+			arr.Children = append(arr.Children, value)
+			arrayState = ast.ArrayValue
+			p.nextToken()
+
+		case ast.ArrayValue:
+			if p.currentTokenTypeIs(token.RightBracket) {
+				arr.End = p.currentToken.End
+				return arr
+			}
+			if !p.currentTokenTypeIs(token.Comma) {
+				p.parseError(fmt.Sprintf(
+					"expected `,` or `]`, got: `%s`",
+					p.currentToken.Literal,
+				), token.Comma, token.RightBracket)
+				return nil
+			}
+			arrayState = ast.ArrayComma
+			p.nextToken()
+
+		case ast.ArrayComma:
+			value := p.parseJSONValue()
+			if p.hasErrors() {
+				return nil
+			}
+			arr.Children = append(arr.Children, value)
+			arrayState = ast.ArrayValue
+			p.nextToken()
+		}
+	}
+
+	p.parseError("unterminated array, expected `]`", token.RightBracket)
+	return nil
+}
+
+// parseLiteral parses a JSON string, number, boolean or null, or a bare
+// `{{name}}` reference standing for a value
+func (p *Parser) parseLiteral() ast.Value {
+	lit := ast.Literal{Type: "Literal"}
+
+	switch p.currentToken.Type {
+	case token.String:
+		s, err := unquote(p.currentToken.Literal)
+		if err != nil {
+			p.parseError(err.Error())
+			return nil
+		}
+		lit.Value = s
+	case token.Number:
+		n, err := parseNumber(p.currentToken.Literal)
+		if err != nil {
+			p.parseError(err.Error())
+			return nil
+		}
+		lit.Value = n
+	case token.True:
+		lit.Value = true
+	case token.False:
+		lit.Value = false
+	case token.Null:
+		lit.Value = nil
+	case token.Variable:
+		tmpl := p.parseTemplate(p.currentToken.Literal, p.currentToken.StartPos)
+		if p.hasErrors() {
+			return nil
+		}
+		return *tmpl
+	default:
+		p.parseError(fmt.Sprintf(
+			"expected a JSON value, got: `%s`",
+			p.currentToken.Literal,
+		), jsonValues...)
+		return nil
+	}
+
+	return lit
+}
+
+// unquote validates a double quoted string literal, including its quotes, and
+// returns its content with all the escape sequences applied.
+func unquote(s string) (string, error) {
+	var b strings.Builder
+	chars := []rune(s)
+	stringState := ast.StringStart
+
+	for i := 0; i < len(chars); i++ {
+		char := chars[i]
+
+		switch stringState {
+		case ast.StringStart:
+			if char != '"' {
+				return "", fmt.Errorf("expected `\"`, got: `%s`", s)
+			}
+			stringState = ast.StringQuoteOrChar
+
+		case ast.StringQuoteOrChar:
+			if char == '"' {
+				if i != len(chars)-1 {
+					return "", fmt.Errorf("unexpected characters after string: `%s`", s)
+				}
+				return b.String(), nil
+			}
+			if char == '\\' {
+				stringState = ast.Escape
+				continue
+			}
+			if char < 0x20 {
+				return "", fmt.Errorf("invalid control character in string: `%s`", s)
+			}
+			b.WriteRune(char)
+
+		case ast.Escape:
+			switch char {
+			case '"', '\\', '/':
+				b.WriteRune(char)
+			case 'b':
+				b.WriteRune('\b')
+			case 'f':
+				b.WriteRune('\f')
+			case 'n':
+				b.WriteRune('\n')
+			case 'r':
+				b.WriteRune('\r')
+			case 't':
+				b.WriteRune('\t')
+			case 'u':
+				r, ok := readHex(chars, i+1)
+				if !ok {
+					return "", fmt.Errorf("invalid unicode escape in string: `%s`", s)
+				}
+				i += 4
+				if utf16.IsSurrogate(r) {
+					if r2, ok := readHex(chars, i+3); ok && chars[i+1] == '\\' && chars[i+2] == 'u' {
+						if decoded := utf16.DecodeRune(r, r2); decoded != unicode.ReplacementChar {
+							r = decoded
+							i += 6
+						}
+					}
+				}
+				b.WriteRune(r)
+			default:
+				return "", fmt.Errorf("invalid escape sequence `\\%c` in string: `%s`", char, s)
+			}
+			stringState = ast.StringQuoteOrChar
+		}
+	}
+
+	return "", fmt.Errorf("unterminated string: `%s`", s)
+}
+
+// readHex decodes the 4 hexadecimal digits of a `\uXXXX` escape starting at i
+func readHex(chars []rune, i int) (rune, bool) {
+	if i+4 > len(chars) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(string(chars[i:i+4]), 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// parseNumber validates a JSON number with the number states and returns its
+// value, a float64 or, out of the range of a float64, the number as written in
+// a json.Number
+func parseNumber(s string) (ast.Value, error) {
+	numberState := ast.NumberStart
+
+	for _, char := range s {
+		switch numberState {
+		case ast.NumberStart:
+			switch {
+			case char == '-':
+				numberState = ast.NumberMinus
+			case char == '0':
+				numberState = ast.NumberZero
+			case isDigit(char):
+				numberState = ast.NumberDigit
+			default:
+				return 0, fmt.Errorf("invalid number: `%s`", s)
+			}
+
+		case ast.NumberMinus:
+			switch {
+			case char == '0':
+				numberState = ast.NumberZero
+			case isDigit(char):
+				numberState = ast.NumberDigit
+			default:
+				return 0, fmt.Errorf("invalid number: `%s`", s)
+			}
+
+		case ast.NumberZero, ast.NumberDigit:
+			switch {
+			case isDigit(char) && numberState == ast.NumberDigit:
+				numberState = ast.NumberDigit
+			case char == '.':
+				numberState = ast.NumberPoint
+			case char == 'e' || char == 'E':
+				numberState = ast.NumberExp
+			default:
+				return 0, fmt.Errorf("invalid number: `%s`", s)
+			}
+
+		case ast.NumberPoint:
+			if !isDigit(char) {
+				return 0, fmt.Errorf("invalid number: `%s`", s)
+			}
+			numberState = ast.NumberDigitFraction
+
+		case ast.NumberDigitFraction:
+			switch {
+			case isDigit(char):
+				numberState = ast.NumberDigitFraction
+			case char == 'e' || char == 'E':
+				numberState = ast.NumberExp
+			default:
+				return 0, fmt.Errorf("invalid number: `%s`", s)
+			}
+
+		case ast.NumberExp:
+			switch {
+			case char == '+' || char == '-':
+				numberState = ast.NumberExpDigitOrSign
+			case isDigit(char):
+				numberState = ast.NumberExpDigit
+			default:
+				return 0, fmt.Errorf("invalid number: `%s`", s)
+			}
+
+		case ast.NumberExpDigitOrSign, ast.NumberExpDigit:
+			if !isDigit(char) {
+				return 0, fmt.Errorf("invalid number: `%s`", s)
+			}
+			numberState = ast.NumberExpDigit
+		}
+	}
+
+	switch numberState {
+	case ast.NumberZero, ast.NumberDigit, ast.NumberDigitFraction, ast.NumberExpDigit:
+		n, err := strconv.ParseFloat(s, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return json.Number(s), nil
+		}
+		return n, err
+	}
+	return 0, fmt.Errorf("invalid number: `%s`", s)
+}
+
+func isDigit(char rune) bool {
+	return '0' <= char && char <= '9'
+}
@@ -4,7 +4,6 @@ package parser
 // Parser methods handle iterating through tokens and building and AST.
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -16,9 +15,25 @@ import (
 
 type Parser struct {
 	lexer        *lexer.Lexer
-	errors       []string
+	errors       ErrorList
 	currentToken token.Token
 	peekToken    token.Token
+	comments     []ast.Comment // comments read but not attached to a node yet
+	failed       bool          // an error was found since the last recovery
+	recover      bool          // keep parsing after an error, see Recover
+}
+
+// Option configures how ParseProgram parses the input
+type Option func(*Parser)
+
+// Recover makes ParseProgram keep going after an error instead of stopping at
+// the first one. The tokens up to the next line starting with an HTTP method
+// are skipped, and parsing resumes from there. ParseProgram then returns the
+// entries that parsed along with the list of all the errors found.
+func Recover() Option {
+	return func(p *Parser) {
+		p.recover = true
+	}
 }
 
 // New takes a Lexer, creates a Parser with that Lexer, sets the current and
@@ -34,25 +49,36 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 // ParseProgram parses tokens and creates an AST. It returns the RootNode
-// which holds a slice of Values (and in turn, the rest of the tree)
-func (p *Parser) ParseProgram() (ast.RootNode, error) {
+// which holds a slice of Values (and in turn, the rest of the tree).
+// By default parsing stops at the first error and no AST is returned, with the
+// Recover option the RootNode holds every entry that could be parsed.
+func (p *Parser) ParseProgram(opts ...Option) (ast.RootNode, error) {
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.skipNewLines()
+
 	var rootNode ast.RootNode
-	if p.currentTokenTypeIs(token.Get) || p.currentTokenTypeIs(token.Post) {
+	if p.currentTokenIsMethod() {
 		rootNode.Type = ast.NuggetRoot
 	}
 
 	nugget := p.parseNugget()
 
-	if p.Errors() != "" {
-		return ast.RootNode{}, errors.New(p.Errors())
+	if len(p.errors) > 0 {
+		if !p.recover {
+			return ast.RootNode{}, p.errors
+		}
+		rootNode.RootValue = &nugget
+		return rootNode, p.errors
 	}
 
 	if len(nugget.Entries) == 0 {
 		p.parseError(fmt.Sprintf(
-            "line %v, expected a request, got: `%v`",
-			p.currentToken.Line+1, p.currentToken.Literal,
-		))
-		return ast.RootNode{}, errors.New(p.Errors())
+			"expected a request, got: `%v`",
+			literal(p.currentToken),
+		), token.Methods()...)
+		return ast.RootNode{}, p.errors
 	}
 
 	rootNode.RootValue = &nugget
@@ -60,45 +86,121 @@ func (p *Parser) ParseProgram() (ast.RootNode, error) {
 }
 
 // nextToken sets our current token to the peek token and the peek token to
-// p.lexer.NextToken() which ends up scanning and returning the next token
+// p.lexer.NextToken() which ends up scanning and returning the next token.
+// Comment tokens never reach the grammar, they are kept aside until a node
+// takes them with takeComments. Whitespace tokens are skipped.
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
+
+	for p.peekTokenTypeIs(token.Comment) || p.peekTokenTypeIs(token.Whitespace) {
+		if p.peekTokenTypeIs(token.Whitespace) {
+			p.peekToken = p.lexer.NextToken()
+			continue
+		}
+		p.comments = append(p.comments, ast.Comment{
+			Type:     "Comment",
+			Text:     p.peekToken.Literal,
+			Inline:   p.currentToken.Type != "" && p.peekToken.Line == p.currentToken.Line,
+			Line:     p.peekToken.Line,
+			Start:    p.peekToken.Start,
+			End:      p.peekToken.End,
+			StartPos: p.peekToken.StartPos,
+			EndPos:   p.peekToken.EndPos,
+		})
+		p.peekToken = p.lexer.NextToken()
+	}
+}
+
+// takeComments returns the pending comments and forgets them. With inline set,
+// only the comment trailing the current token on its line is taken.
+func (p *Parser) takeComments(inline bool) []ast.Comment {
+	var taken, rest []ast.Comment
+	for _, c := range p.comments {
+		if !inline || c.Inline {
+			taken = append(taken, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	p.comments = rest
+	return taken
 }
 
 func (p *Parser) currentTokenTypeIs(t token.Type) bool {
 	return p.currentToken.Type == t
 }
 
+// currentTokenIsMethod reports whether the current token is any HTTP method
+func (p *Parser) currentTokenIsMethod() bool {
+	return token.IsMethod(p.currentToken.Type)
+}
+
 func (p *Parser) parseNugget() ast.Nugget {
 	nugget := ast.Nugget{Type: "Nugget"}
 	nuggetState := ast.NuggetStart
 	var entries []ast.Entry
 
 	for !p.currentTokenTypeIs(token.EOF) {
+		// the variables of the .http dialect are defined between the entries
+		if p.currentTokenTypeIs(token.Definition) {
+			variable := p.parseDefinition()
+			if p.hasErrors() {
+				if !p.recover {
+					return ast.Nugget{}
+				}
+				p.nextToken()
+				p.synchronize()
+				continue
+			}
+			nugget.Variables = append(nugget.Variables, variable)
+			p.nextToken()
+			p.skipNewLines()
+			continue
+		}
+
 		switch nuggetState {
 		case ast.NuggetStart:
-			if p.currentTokenTypeIs(token.Get) || p.currentTokenTypeIs(token.Post) {
+			if p.currentTokenIsMethod() {
 				entry := p.parseEntry()
+				if p.hasErrors() {
+					if !p.recover {
+						return ast.Nugget{}
+					}
+					p.synchronize()
+					continue
+				}
 				entries = append(entries, entry)
 				nuggetState = ast.NuggetEntry
 			} else {
 				p.parseError(fmt.Sprintf(
-					"line %v, expected HTTP method, got: %s",
-					p.currentToken.Line+1, p.currentToken.Literal,
-				))
-				return ast.Nugget{}
+					"expected HTTP method, got: %s",
+					literal(p.currentToken),
+				), token.Methods()...)
+				if !p.recover {
+					return ast.Nugget{}
+				}
+				p.synchronize()
 			}
 		case ast.NuggetEntry:
-			if p.currentTokenTypeIs(token.Get) || p.currentTokenTypeIs(token.Post) {
+			if p.currentTokenIsMethod() {
 				nuggetState = ast.NuggetStart
 			} else {
-				return ast.Nugget{}
+				p.parseError(fmt.Sprintf(
+					"expected HTTP method, got: %s",
+					literal(p.currentToken),
+				), token.Methods()...)
+				if !p.recover {
+					return ast.Nugget{}
+				}
+				p.synchronize()
+				nuggetState = ast.NuggetStart
 			}
 		}
 	}
 
 	nugget.Entries = entries
+	nugget.Comments = p.takeComments(false)
 	return nugget
 }
 
@@ -106,8 +208,12 @@ func (p *Parser) parseNugget() ast.Nugget {
 // this parser fall under these 3 actions.
 func (p *Parser) parseEntry() ast.Entry {
 	entry := ast.Entry{Type: "Entry"}
+	entry.Comments = p.takeComments(false)
 
 	entry.Req = p.parseRequest()
+	if p.hasErrors() {
+		return ast.Entry{}
+	}
 	entry.Res = p.parseResponse()
 
 	return entry
@@ -121,14 +227,15 @@ func (p *Parser) parseRequest() ast.Request {
 	for !p.currentTokenTypeIs(token.EOF) {
 		switch reqState {
 		case ast.ReqStart:
-			if p.currentTokenTypeIs(token.Get) || p.currentTokenTypeIs(token.Post) {
+			if p.currentTokenIsMethod() {
 				reqState = ast.ReqOpen
 				req.Start = p.currentToken.Start
+				req.StartPos = p.currentToken.StartPos
 			} else {
 				p.parseError(fmt.Sprintf(
-					"line %v, expected HTTP method, got: %s",
-					p.currentToken.Line+1, p.currentToken.Literal,
-				))
+					"expected HTTP method, got: %s",
+					literal(p.currentToken),
+				), token.Methods()...)
 				return ast.Request{}
 			}
 
@@ -136,26 +243,67 @@ func (p *Parser) parseRequest() ast.Request {
 			// we haven't advanced to the next token
 			if p.peekTokenTypeIs(token.EOF) {
 				req.End = p.currentToken.End
+				req.EndPos = p.currentToken.EndPos
 				p.nextToken()
 				return req
 			}
 			reqState = ast.ReqLine
 			line := p.parseLine()
+			if p.hasErrors() {
+				return ast.Request{}
+			}
 			req.Line = line
-            req.End = p.currentToken.End
+			if p.peekTokenTypeIs(token.Http) {
+				// the HTTP version ending a request line of the .http
+				// dialect, the runner negotiates its own
+				p.nextToken()
+			}
+			req.Comments = p.takeComments(true)
+			req.End = p.currentToken.End
+			req.EndPos = p.currentToken.EndPos
 			p.nextToken()
+			if p.endLine(); p.hasErrors() {
+				return ast.Request{}
+			}
 
 		case ast.ReqLine:
+			// a `{` or `[` starting a line opens the request body
+			if p.currentTokenTypeIs(token.LeftBrace) || p.currentTokenTypeIs(token.LeftBracket) {
+				reqState = ast.ReqBody
+				continue
+			}
+
 			// if the next token is a string, it might be a header
 			if !p.currentTokenTypeIs(token.String) {
-				req.End = p.currentToken.Start
 				return req
 			}
 
 			header := p.parseKeyValue()
+			if p.hasErrors() {
+				return ast.Request{}
+			}
 			req.Header = append(req.Header, header)
-            req.End = p.currentToken.End
+			req.End = p.currentToken.End
+			req.EndPos = p.currentToken.EndPos
+			p.nextToken()
+			if p.endLine(); p.hasErrors() {
+				return ast.Request{}
+			}
+
+		case ast.ReqBody:
+			req.Comments = append(req.Comments, p.takeComments(false)...)
+			body := p.parseBody()
+			if p.hasErrors() {
+				return ast.Request{}
+			}
+			req.Body = &body
+			req.End = body.End
+			req.EndPos = body.EndPos
 			p.nextToken()
+			if p.endLine(); p.hasErrors() {
+				return ast.Request{}
+			}
+			return req
 		}
 	}
 
@@ -166,44 +314,96 @@ func (p *Parser) parseResponse() ast.Response {
 	res := ast.Response{Type: "Response"} // Struct of type Response
 
 	if !p.currentTokenTypeIs(token.Http) {
-		return res 
-	} 
+		return res
+	}
+
+	res.Comments = p.takeComments(false)
 
 	res.Version = p.parseString()
 	res.Start = p.currentToken.Start
+	res.StartPos = p.currentToken.StartPos
 	p.nextToken()
 
-	if !p.currentTokenTypeIs(token.Number) {
+	switch {
+	case p.currentTokenTypeIs(token.Number):
+		res.Status, _ = strconv.Atoi(p.currentToken.Literal)
+	case isStatusPattern(p.currentToken.Literal):
+		res.StatusPattern = p.currentToken.Literal
+	default:
 		p.parseError(fmt.Sprintf(
-			"line %v, expected number, got: `%s`",
-			p.currentToken.Line+1, p.currentToken.Literal,
-		))
+			"expected status code, got: `%s`",
+			literal(p.currentToken),
+		), token.Number)
 		return ast.Response{}
 	}
-
-	res.Status, _ = strconv.Atoi(p.currentToken.Literal)
+	res.Comments = append(res.Comments, p.takeComments(true)...)
 
 	res.End = p.currentToken.End
+	res.EndPos = p.currentToken.EndPos
 	p.nextToken()
+	if p.endLine(); p.hasErrors() {
+		return ast.Response{}
+	}
+
+	// the sections come in any order, each of them at most once
+	sections := map[token.Type]bool{}
+	for p.currentTokenTypeIs(token.Capture) || p.currentTokenTypeIs(token.Asserts) {
+		section := p.currentToken.Type
+		if sections[section] {
+			p.parseError(fmt.Sprintf(
+				"duplicate section `%s`",
+				p.currentToken.Literal,
+			))
+			return ast.Response{}
+		}
+		sections[section] = true
 
-	if p.currentTokenTypeIs(token.Capture) {
+		res.Comments = append(res.Comments, p.takeComments(true)...)
+		res.End = p.currentToken.End
+		res.EndPos = p.currentToken.EndPos
 		p.nextToken()
+		if p.endLine(); p.hasErrors() {
+			return ast.Response{}
+		}
 		for !p.currentTokenTypeIs(token.EOF) {
 			if !p.currentTokenTypeIs(token.String) {
-				res.End = p.currentToken.Start
-				return res
+				break
 			}
 
-			capture := p.parseKeyValue()
-			res.Capture = append(res.Capture, capture)
-            res.End = p.currentToken.End
+			if section == token.Capture {
+				capture := p.parseCapture()
+				if p.hasErrors() {
+					return ast.Response{}
+				}
+				res.Capture = append(res.Capture, capture)
+			} else {
+				assert := p.parseAssert()
+				if p.hasErrors() {
+					return ast.Response{}
+				}
+				res.Asserts = append(res.Asserts, assert)
+			}
+			res.End = p.currentToken.End
+			res.EndPos = p.currentToken.EndPos
 			p.nextToken()
+			if p.endLine(); p.hasErrors() {
+				return ast.Response{}
+			}
 		}
 	}
 
 	return res
 }
 
+// isStatusPattern reports whether s is a status wildcard: `*` for any status,
+// or a class of status codes from `1xx` to `5xx`
+func isStatusPattern(s string) bool {
+	if s == "*" {
+		return true
+	}
+	return len(s) == 3 && '1' <= s[0] && s[0] <= '5' && s[1:] == "xx"
+}
+
 // parseCommand is used to parse an object command and doing so handles setting command keyword and the parameter
 func (p *Parser) parseLine() ast.Endpoint {
 	endpoint := ast.Endpoint{Type: "Endpoint"}
@@ -212,15 +412,16 @@ func (p *Parser) parseLine() ast.Endpoint {
 	for !p.currentTokenTypeIs(token.EOF) {
 		switch lineState {
 		case ast.LineStart:
-			if p.currentTokenTypeIs(token.Get) {
-				endpoint.Method = p.currentToken.Literal
+			if p.currentTokenIsMethod() {
+				endpoint.Method = strings.ToUpper(p.currentToken.Literal)
 				lineState = ast.LineMethod
 				p.nextToken()
 			} else {
 				p.parseError(fmt.Sprintf(
-					"line %v, expected HTTP method, got: %s",
-					p.currentToken.Line+1, p.currentToken.Literal,
-				))
+					"expected HTTP method, got: %s",
+					literal(p.currentToken),
+				), token.Methods()...)
+				return ast.Endpoint{}
 			}
 
 		case ast.LineMethod:
@@ -228,64 +429,183 @@ func (p *Parser) parseLine() ast.Endpoint {
 				lineState = ast.LineNewLine
 			} else {
 				p.parseError(fmt.Sprintf(
-					"line %v, expected url, got: `%s`",
-					p.currentToken.Line+1, p.currentToken.Literal,
-				))
+					"expected url, got: `%s`",
+					literal(p.currentToken),
+				), token.String)
+				return ast.Endpoint{}
 			}
 
 		case ast.LineNewLine:
 			param := p.parseString()
+			if p.hasErrors() {
+				return ast.Endpoint{}
+			}
 			endpoint.Url = param
+			endpoint.UrlTemplate = p.parseTemplate(param, p.stringPos())
+			if p.hasErrors() {
+				return ast.Endpoint{}
+			}
 			return endpoint
 		}
 	}
 	return endpoint
 }
 
-func (p *Parser) parseKeyValue() ast.KeyValue {
+// parseDefinition parses a `@name = value` line of the .http dialect, the
+// value is the rest of the line and can reference the variables defined
+// before it
+func (p *Parser) parseDefinition() ast.KeyValue {
 	kv := ast.KeyValue{Type: "KeyValue"}
+	kv.Comments = p.takeComments(false)
 
-	strToken := p.parseString()
-	if strToken[len(strToken)-1] != ':' {
-		p.parseError(fmt.Sprintf(
-			"line %v, expected `:`, got:`%s`",
-			p.currentToken.Line+1, p.peekToken.Literal,
-		))
+	line := p.currentToken.Literal
+	name, value, ok := strings.Cut(line[1:], "=")
+	if !ok {
+		p.parseError(fmt.Sprintf("expected `@name = value`, got: `%s`", line), token.Definition)
+		return ast.KeyValue{}
+	}
+	kv.Key = strings.TrimSpace(name)
+	if !isVariableName(kv.Key) {
+		p.parseError(fmt.Sprintf("invalid variable name: `%s`", kv.Key), token.Definition)
+		return ast.KeyValue{}
+	}
+
+	kv.Value = strings.TrimSpace(value)
+	pos := advance(p.currentToken.StartPos, line[:len(line)-len(strings.TrimLeft(value, " \t"))])
+	kv.ValueTemplate = p.parseTemplate(kv.Value, pos)
+	if p.hasErrors() {
+		return ast.KeyValue{}
+	}
+	kv.StartPos = p.currentToken.StartPos
+	kv.EndPos = p.currentToken.EndPos
+	return kv
+}
+
+// parseKeyValue parses a `key: value` header, the value is the rest of the
+// line as it is written
+func (p *Parser) parseKeyValue() ast.KeyValue {
+	kv := ast.KeyValue{Type: "KeyValue"}
+	kv.Comments = p.takeComments(false)
+
+	kv.Key = p.currentToken.Literal
+	kv.StartPos = p.currentToken.StartPos
+	if !p.peekTokenTypeIs(token.Colon) {
+		p.errorAt(p.peekToken, fmt.Sprintf(
+			"expected `:`, got: `%s`",
+			literal(p.peekToken),
+		), token.Colon)
 		p.nextToken()
 		return ast.KeyValue{}
 	}
 
-	kv.Key = strToken[:len(strToken)-1]
+	p.nextToken()
 	p.nextToken()
 
 	if !p.currentTokenTypeIs(token.String) {
 		p.parseError(fmt.Sprintf(
-			"line %v, expected string, got: `%s`",
-			p.currentToken.Line+1, p.currentToken.Literal,
-		))
+			"expected string, got: `%s`",
+			literal(p.currentToken),
+		), token.String)
 		return ast.KeyValue{}
 	}
 
-	kv.Value = p.parseString()
+	// the value is verbatim, its quotes included
+	kv.Value = p.currentToken.Literal
+	kv.ValueTemplate = p.parseTemplate(kv.Value, p.currentToken.StartPos)
+	if p.hasErrors() {
+		return ast.KeyValue{}
+	}
+	kv.EndPos = p.currentToken.EndPos
+	kv.Comments = append(kv.Comments, p.takeComments(true)...)
 	return kv
 }
 
-// TODO: all the tedius escaping, etc still needs to be applied here
+// parseString returns the value of the current string token. Double quoted
+// strings are unquoted and their escape sequences (`\"`, `\\`, `\n`, `\t`,
+// `\uXXXX`...) applied, an unterminated string is reported as an error.
 func (p *Parser) parseString() string {
-	return p.currentToken.Literal
+	if !strings.HasPrefix(p.currentToken.Literal, `"`) {
+		return p.currentToken.Literal
+	}
+
+	s, err := unquote(p.currentToken.Literal)
+	if err != nil {
+		p.parseError(err.Error(), token.String)
+		return ""
+	}
+	return s
+}
+
+// stringPos returns the position of the content of the current string token,
+// after its opening quote when it is quoted
+func (p *Parser) stringPos() token.Position {
+	pos := p.currentToken.StartPos
+	if strings.HasPrefix(p.currentToken.Literal, `"`) {
+		pos = advance(pos, `"`)
+	}
+	return pos
 }
 
 func (p *Parser) peekTokenTypeIs(t token.Type) bool {
 	return p.peekToken.Type == t
 }
 
+// endLine checks that the current token ends its line, then moves to the
+// first token of the next line that isn't blank
+func (p *Parser) endLine() {
+	if !p.currentTokenTypeIs(token.NewLine) && !p.currentTokenTypeIs(token.EOF) {
+		p.parseError(fmt.Sprintf(
+			"expected new line, got: `%s`",
+			literal(p.currentToken),
+		), token.NewLine)
+		return
+	}
+	p.skipNewLines()
+}
+
+// skipNewLines moves past blank lines
+func (p *Parser) skipNewLines() {
+	for p.currentTokenTypeIs(token.NewLine) {
+		p.nextToken()
+	}
+}
+
+// hasErrors reports whether an error was found since parsing started, or
+// since the last recovery
+func (p *Parser) hasErrors() bool {
+	return p.failed
+}
+
+// synchronize is the panic mode recovery: it skips tokens until the current
+// token is an HTTP method (the lexer only emits them at the start of a line),
+// so a new entry can be parsed from there, or the definition of a variable.
+func (p *Parser) synchronize() {
+	for !p.currentTokenTypeIs(token.EOF) && !p.currentTokenIsMethod() && !p.currentTokenTypeIs(token.Definition) {
+		p.nextToken()
+	}
+	p.failed = false
+}
+
 // parseError is very similar to `peekError`, except it simply takes a string message that
-// gets appended to the parser's errors
-func (p *Parser) parseError(msg string) {
-	p.errors = append(p.errors, msg)
+// gets appended to the parser's errors, positioned at the current token
+func (p *Parser) parseError(msg string, expected ...token.Type) {
+	p.errorAt(p.currentToken, msg, expected...)
+}
+
+// errorAt appends an error positioned at the given token
+func (p *Parser) errorAt(t token.Token, msg string, expected ...token.Type) {
+	p.failed = true
+	p.errors = append(p.errors, &Error{
+		Line:     t.StartPos.Line,
+		Column:   t.StartPos.Column,
+		Offset:   t.StartPos.Offset,
+		Expected: expected,
+		Found:    t,
+		Msg:      msg,
+	})
 }
 
 // Errors is simply a helper function that returns the parser's errors
-func (p *Parser) Errors() string {
-	return strings.Join(p.errors, ", ")
+func (p *Parser) Errors() ErrorList {
+	return p.errors
 }
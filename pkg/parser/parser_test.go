@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"nug/pkg/ast"
 	"nug/pkg/lexer"
+	"nug/pkg/token"
 	"testing"
 	"reflect"
 )
@@ -76,6 +79,8 @@ func TestParseSingleGet(t *testing.T) {
 						Header: nil,
 						Start: 0,
 						End: 27,
+						StartPos: token.Position{Line: 1, Column: 1, Offset: 0},
+						EndPos: token.Position{Line: 1, Column: 28, Offset: 27},
 					},
 					Res: ast.Response{
 						Type: "Response",
@@ -133,10 +138,14 @@ header_3: value_3`,
                                 Type: "KeyValue",
                                 Key: "header_1",
                                 Value: "value_1",
+                                StartPos: token.Position{Line: 2, Column: 1, Offset: 30},
+                                EndPos: token.Position{Line: 2, Column: 18, Offset: 47},
                             },
                         },
 						Start: 0,
-						End: 48,
+						End: 47,
+						StartPos: token.Position{Line: 1, Column: 1, Offset: 0},
+						EndPos: token.Position{Line: 2, Column: 18, Offset: 47},
 					},
 					Res: ast.Response{
 						Type: "Response",
@@ -161,15 +170,21 @@ header_3: value_3`,
                                 Type: "KeyValue",
                                 Key: "header_2",
                                 Value: "value_2",
+                                StartPos: token.Position{Line: 4, Column: 1, Offset: 78},
+                                EndPos: token.Position{Line: 4, Column: 18, Offset: 95},
                             },
                             {
                                 Type: "KeyValue",
                                 Key: "header_3",
                                 Value: "value_3",
+                                StartPos: token.Position{Line: 5, Column: 1, Offset: 96},
+                                EndPos: token.Position{Line: 5, Column: 18, Offset: 113},
                             },
                         },
 						Start: 48,
 						End: 113,
+						StartPos: token.Position{Line: 3, Column: 1, Offset: 48},
+						EndPos: token.Position{Line: 5, Column: 18, Offset: 113},
 					},
 					Res: ast.Response{
 						Type: "Response",
@@ -221,7 +236,9 @@ HTTP 200`,
 						},
 						Header: nil,
 						Start: 0,
-						End: 28,
+						End: 27,
+						StartPos: token.Position{Line: 1, Column: 1, Offset: 0},
+						EndPos: token.Position{Line: 1, Column: 28, Offset: 27},
 					},
 					Res: ast.Response{
 						Type: "Response",
@@ -230,6 +247,8 @@ HTTP 200`,
 						Capture: nil,
 						Start: 28,
 						End: 36,
+						StartPos: token.Position{Line: 2, Column: 1, Offset: 28},
+						EndPos: token.Position{Line: 2, Column: 9, Offset: 36},
 					},
 				},
 			},
@@ -256,7 +275,7 @@ func TestParseGetWithCapture(t *testing.T) {
 		input: `GET https://test.com/v1/api
 HTTP 200
 [Capture]
-capture_1: value_1`,
+capture_1: header "value_1"`,
     }
 
 	result := ast.RootNode{
@@ -275,21 +294,27 @@ capture_1: value_1`,
 						},
 						Header: nil,
 						Start: 0,
-						End: 28,
+						End: 27,
+						StartPos: token.Position{Line: 1, Column: 1, Offset: 0},
+						EndPos: token.Position{Line: 1, Column: 28, Offset: 27},
 					},
 					Res: ast.Response{
 						Type: "Response",
 						Version: "HTTP",
 						Status: 200,
-						Capture: []ast.KeyValue{
+						Capture: []ast.Capture{
                             {
-                                Type: "KeyValue",
-                                Key: "capture_1",
-                                Value: "value_1",
+                                Type: "Capture",
+                                Name: "capture_1",
+                                Query: ast.Query{Type: "Query", Kind: "header", Arg: "value_1"},
+                                StartPos: token.Position{Line: 4, Column: 1, Offset: 47},
+                                EndPos: token.Position{Line: 4, Column: 28, Offset: 74},
                             },
                         },
 						Start: 28,
-						End: 65,
+						End: 74,
+						StartPos: token.Position{Line: 2, Column: 1, Offset: 28},
+						EndPos: token.Position{Line: 4, Column: 28, Offset: 74},
 					},
 				},
 			},
@@ -317,7 +342,7 @@ func TestParseMultipleRequests(t *testing.T) {
 header_1: value_1
 HTTP 200
 [Capture]
-capture_1: value_1
+capture_1: header "value_1"
 
 GET https://test.com/v1/api/b
 header_2: value_2
@@ -327,7 +352,7 @@ HTTP 200
 GET https://test.com/v1/api/c
 HTTP 200
 [Capture]
-capture_4: value_4
+capture_4: header "value_4"
 
 GET https://test.com/v1/api/d`,
     }
@@ -351,24 +376,32 @@ GET https://test.com/v1/api/d`,
                                 Type: "KeyValue",
                                 Key: "header_1",
                                 Value: "value_1",
+                                StartPos: token.Position{Line: 2, Column: 1, Offset: 30},
+                                EndPos: token.Position{Line: 2, Column: 18, Offset: 47},
                             },
                         },
 						Start: 0,
-						End: 48,
+						End: 47,
+						StartPos: token.Position{Line: 1, Column: 1, Offset: 0},
+						EndPos: token.Position{Line: 2, Column: 18, Offset: 47},
 					},
 					Res: ast.Response{
 						Type: "Response",
 						Version: "HTTP",
 						Status: 200,
-						Capture: []ast.KeyValue{
+						Capture: []ast.Capture{
                             {
-                                Type: "KeyValue",
-                                Key: "capture_1",
-                                Value: "value_1",
+                                Type: "Capture",
+                                Name: "capture_1",
+                                Query: ast.Query{Type: "Query", Kind: "header", Arg: "value_1"},
+                                StartPos: token.Position{Line: 5, Column: 1, Offset: 67},
+                                EndPos: token.Position{Line: 5, Column: 28, Offset: 94},
                             },
                         },
 						Start: 48,
-						End: 87,
+						End: 94,
+						StartPos: token.Position{Line: 3, Column: 1, Offset: 48},
+						EndPos: token.Position{Line: 5, Column: 28, Offset: 94},
 					},
 				},
 
@@ -386,23 +419,31 @@ GET https://test.com/v1/api/d`,
                                 Type: "KeyValue",
                                 Key: "header_2",
                                 Value: "value_2",
+                                StartPos: token.Position{Line: 8, Column: 1, Offset: 126},
+                                EndPos: token.Position{Line: 8, Column: 18, Offset: 143},
                             },
                             {
                                 Type: "KeyValue",
                                 Key: "header_3",
                                 Value: "value_3",
+                                StartPos: token.Position{Line: 9, Column: 1, Offset: 144},
+                                EndPos: token.Position{Line: 9, Column: 18, Offset: 161},
                             },
                         },
-						Start: 87,
-						End: 153,
+						Start: 96,
+						End: 161,
+						StartPos: token.Position{Line: 7, Column: 1, Offset: 96},
+						EndPos: token.Position{Line: 9, Column: 18, Offset: 161},
 					},
 					Res: ast.Response{
 						Type: "Response",
 						Version: "HTTP",
 						Status: 200,
 						Capture: nil,
-                        Start: 153,
-						End: 161,
+                        Start: 162,
+						End: 170,
+						StartPos: token.Position{Line: 10, Column: 1, Offset: 162},
+						EndPos: token.Position{Line: 10, Column: 9, Offset: 170},
 					},
 				},
 
@@ -416,22 +457,28 @@ GET https://test.com/v1/api/d`,
 							Url: "https://test.com/v1/api/c",
 						},
 						Header: nil,
-                        Start: 163,
-						End: 193,
+                        Start: 172,
+						End: 201,
+						StartPos: token.Position{Line: 12, Column: 1, Offset: 172},
+						EndPos: token.Position{Line: 12, Column: 30, Offset: 201},
 					},
 					Res: ast.Response{
 						Type: "Response",
 						Version: "HTTP",
 						Status: 200,
-						Capture: []ast.KeyValue{
+						Capture: []ast.Capture{
                             {
-                                Type: "KeyValue",
-                                Key: "capture_4",
-                                Value: "value_4",
+                                Type: "Capture",
+                                Name: "capture_4",
+                                Query: ast.Query{Type: "Query", Kind: "header", Arg: "value_4"},
+                                StartPos: token.Position{Line: 15, Column: 1, Offset: 221},
+                                EndPos: token.Position{Line: 15, Column: 28, Offset: 248},
                             },
                         },
-						Start: 193,
-						End: 232,
+						Start: 202,
+						End: 248,
+						StartPos: token.Position{Line: 13, Column: 1, Offset: 202},
+						EndPos: token.Position{Line: 15, Column: 28, Offset: 248},
 					},
 				},
 
@@ -445,8 +492,10 @@ GET https://test.com/v1/api/d`,
 							Url: "https://test.com/v1/api/d",
 						},
 						Header: nil,
-                        Start: 232,
-						End: 261,
+                        Start: 250,
+						End: 279,
+						StartPos: token.Position{Line: 17, Column: 1, Offset: 250},
+						EndPos: token.Position{Line: 17, Column: 30, Offset: 279},
 					},
 					Res: ast.Response{
 						Type: "Response",
@@ -473,3 +522,1051 @@ GET https://test.com/v1/api/d`,
 		t.Fatalf("error: expected %+v, got: %+v", *result.RootValue, *program.RootValue)
 	}
 }
+
+func TestParseGetWithBody(t *testing.T) {
+	test := struct{
+		input string
+	}{
+		input: `GET https://test.com/v1/api
+Content-Type: application/json
+{"name": "nug\tget", "ids": [1, 2.5e1], "ok": true, "next": null}
+HTTP 200`,
+	}
+
+	body := `{"name": "nug\tget", "ids": [1, 2.5e1], "ok": true, "next": null}`
+
+	result := ast.RootNode{
+		Type: ast.NuggetRoot,
+		RootValue: &ast.Nugget{
+			Type: "Nugget",
+			Entries: []ast.Entry{
+				{
+					Type: "Entry",
+					Req: ast.Request{
+						Type: "Request",
+						Line: ast.Endpoint{
+							Type: "Endpoint",
+							Method: "GET",
+							Url: "https://test.com/v1/api",
+						},
+						Header: []ast.KeyValue{
+							{
+								Type: "KeyValue",
+								Key: "Content-Type",
+								Value: "application/json",
+								StartPos: token.Position{Line: 2, Column: 1, Offset: 28},
+								EndPos: token.Position{Line: 2, Column: 31, Offset: 58},
+							},
+						},
+						Body: &ast.Body{
+							Type: "Body",
+							Value: ast.Object{
+								Type: "Object",
+								Children: []ast.Property{
+									{
+										Type: "Property",
+										Key: ast.Identifier{Type: "Identifier", Value: "name"},
+										Value: ast.Literal{Type: "Literal", Value: "nug\tget"},
+									},
+									{
+										Type: "Property",
+										Key: ast.Identifier{Type: "Identifier", Value: "ids"},
+										Value: ast.Array{
+											Type: "Array",
+											Children: []ast.Value{
+												ast.Literal{Type: "Literal", Value: 1.0},
+												ast.Literal{Type: "Literal", Value: 25.0},
+											},
+											Start: 87,
+											End: 97,
+										},
+									},
+									{
+										Type: "Property",
+										Key: ast.Identifier{Type: "Identifier", Value: "ok"},
+										Value: ast.Literal{Type: "Literal", Value: true},
+									},
+									{
+										Type: "Property",
+										Key: ast.Identifier{Type: "Identifier", Value: "next"},
+										Value: ast.Literal{Type: "Literal", Value: nil},
+									},
+								},
+								Start: 59,
+								End: 124,
+							},
+							Raw: body,
+							Start: 59,
+							End: 124,
+							StartPos: token.Position{Line: 3, Column: 1, Offset: 59},
+							EndPos: token.Position{Line: 3, Column: 66, Offset: 124},
+						},
+						Start: 0,
+						End: 124,
+						StartPos: token.Position{Line: 1, Column: 1, Offset: 0},
+						EndPos: token.Position{Line: 3, Column: 66, Offset: 124},
+					},
+					Res: ast.Response{
+						Type: "Response",
+						Version: "HTTP",
+						Status: 200,
+						Capture: nil,
+						Start: 125,
+						End: 133,
+						StartPos: token.Position{Line: 4, Column: 1, Offset: 125},
+						EndPos: token.Position{Line: 4, Column: 9, Offset: 133},
+					},
+				},
+			},
+		},
+	}
+
+	l := lexer.New(test.input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	if !reflect.DeepEqual(*program.RootValue, *result.RootValue) {
+		t.Fatalf("error: expected %+v, got: %+v", *result.RootValue, *program.RootValue)
+	}
+}
+
+func TestParseNumbersOutOfRange(t *testing.T) {
+	input := `POST https://test.com
+{"big": 1e999, "small": -1e999, "tiny": 1e-999}`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	expected := []ast.Value{json.Number("1e999"), json.Number("-1e999"), 0.0}
+	entry := program.RootValue.Entries[0]
+	for i, property := range entry.Req.Body.Value.(ast.Object).Children {
+		if value := property.Value.(ast.Literal).Value; value != expected[i] {
+			t.Fatalf("error: expected %#v for `%s`, got: %#v", expected[i], property.Key.Value, value)
+		}
+	}
+}
+
+func TestParseBodyErrors(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{input: "GET https://test.com\n{\"a\": 01}", err: "line 2, column 7: invalid number: `01`"},
+		{input: "GET https://test.com\n{\"a\": 1.}", err: "line 2, column 7: invalid number: `1.`"},
+		{input: "GET https://test.com\n{\"a\" 1}", err: "line 2, column 6: expected `:`, got: `1`"},
+		{input: "GET https://test.com\n{\"a\": 1 \"b\": 2}", err: "line 2, column 9: expected `,` or `}`, got: `\"b\"`"},
+		{input: "GET https://test.com\n[1, 2", err: "line 2, column 6: unterminated array, expected `]`"},
+		{input: "GET https://test.com\n{\"a\": \"\\x\"}", err: "line 2, column 7: invalid escape sequence `\\x` in string: `\"\\x\"`"},
+		{input: "GET https://test.com\n{\"a\": nope}", err: "line 2, column 7: expected a JSON value, got: `nope`"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		_, err := p.ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error for %q", test.input)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+	}
+}
+
+func TestParseMethods(t *testing.T) {
+	tests := [...]struct {
+		input  string
+		method string
+	}{
+		{input: `GET https://test.com`, method: "GET"},
+		{input: `POST https://test.com`, method: "POST"},
+		{input: `PUT https://test.com`, method: "PUT"},
+		{input: `PATCH https://test.com`, method: "PATCH"},
+		{input: `DELETE https://test.com`, method: "DELETE"},
+		{input: `HEAD https://test.com`, method: "HEAD"},
+		{input: `OPTIONS https://test.com`, method: "OPTIONS"},
+		{input: `TRACE https://test.com`, method: "TRACE"},
+		{input: `CONNECT test.com:443`, method: "CONNECT"},
+		{input: `get https://test.com`, method: "GET"},
+		{input: `Post https://test.com`, method: "POST"},
+		{input: `delete https://test.com`, method: "DELETE"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input + "\nX-Method: get\nHTTP 200")
+		p := New(l)
+
+		program, err := p.ParseProgram()
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", test.input, err)
+		}
+
+		entry := program.RootValue.Entries[0]
+		if entry.Req.Line.Method != test.method {
+			t.Fatalf("error: expected method %s, got: %s", test.method, entry.Req.Line.Method)
+		}
+		if len(entry.Req.Header) != 1 || entry.Req.Header[0].Value != "get" {
+			t.Fatalf("error: expected header value `get`, got: %+v", entry.Req.Header)
+		}
+		if entry.Res.Status != 200 {
+			t.Fatalf("error: expected status 200, got: %d", entry.Res.Status)
+		}
+	}
+}
+
+func TestParseMultipleMethods(t *testing.T) {
+	input := `POST https://test.com/users
+{"name": "nugget"}
+HTTP 201
+
+put https://test.com/users/1
+HTTP 200
+
+DELETE https://test.com/users/1
+HTTP 204`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	methods := []string{"POST", "PUT", "DELETE"}
+	if len(program.RootValue.Entries) != len(methods) {
+		t.Fatalf("the length of the entries is not correct: got %d", len(program.RootValue.Entries))
+	}
+	for i, entry := range program.RootValue.Entries {
+		if entry.Req.Line.Method != methods[i] {
+			t.Fatalf("entries[%d] - expected method %s, got: %s", i, methods[i], entry.Req.Line.Method)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := [...]struct {
+		input   string
+		version string
+		status  int
+		pattern string
+	}{
+		{input: "HTTP 200", version: "HTTP", status: 200},
+		{input: "HTTP/1.1 201", version: "HTTP/1.1", status: 201},
+		{input: "HTTP/2 204", version: "HTTP/2", status: 204},
+		{input: "HTTP 2xx", version: "HTTP", pattern: "2xx"},
+		{input: "HTTP/1.0 *", version: "HTTP/1.0", pattern: "*"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("GET https://test.com\n" + test.input)
+		p := New(l)
+
+		program, err := p.ParseProgram()
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", test.input, err)
+		}
+
+		res := program.RootValue.Entries[0].Res
+		if res.Version != test.version || res.Status != test.status || res.StatusPattern != test.pattern {
+			t.Fatalf("error: expected %s %d %q for %q, got: %s %d %q",
+				test.version, test.status, test.pattern, test.input, res.Version, res.Status, res.StatusPattern)
+		}
+	}
+
+	for _, input := range []string{"HTTP 6xx", "HTTP 2XX", "HTTP 20x", "HTTP/4 200"} {
+		l := lexer.New("GET https://test.com\n" + input)
+		p := New(l)
+
+		if _, err := p.ParseProgram(); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParseAsserts(t *testing.T) {
+	input := `GET https://test.com
+HTTP 200
+[Asserts]
+status == 200
+header "Content-Type" startsWith "application/json" # utf-8 too
+jsonpath "$.data[0].id" != null
+body matches "^\\{"
+duration <= 1000
+jsonpath "$.total" isInteger
+[Capture]
+id: $.data[0].id`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	expected := []ast.Assert{
+		{
+			Type:      "Assert",
+			Query:     ast.Query{Type: "Query", Kind: "status"},
+			Predicate: ast.Predicate{Type: "Predicate", Op: "==", Value: &ast.Literal{Type: "Literal", Value: 200.0}},
+			StartPos:  token.Position{Line: 4, Column: 1, Offset: 40},
+			EndPos:    token.Position{Line: 4, Column: 14, Offset: 53},
+		},
+		{
+			Type:      "Assert",
+			Query:     ast.Query{Type: "Query", Kind: "header", Arg: "Content-Type"},
+			Predicate: ast.Predicate{Type: "Predicate", Op: "startsWith", Value: &ast.Literal{Type: "Literal", Value: "application/json"}},
+			Comments:  []ast.Comment{{Type: "Comment", Text: "# utf-8 too", Inline: true, Line: 4, Start: 106, End: 117,
+				StartPos: token.Position{Line: 5, Column: 53, Offset: 106}, EndPos: token.Position{Line: 5, Column: 64, Offset: 117}}},
+			StartPos:  token.Position{Line: 5, Column: 1, Offset: 54},
+			EndPos:    token.Position{Line: 5, Column: 52, Offset: 105},
+		},
+		{
+			Type:      "Assert",
+			Query:     ast.Query{Type: "Query", Kind: "jsonpath", Arg: "$.data[0].id"},
+			Predicate: ast.Predicate{Type: "Predicate", Op: "!=", Value: &ast.Literal{Type: "Literal", Value: nil}},
+			StartPos:  token.Position{Line: 6, Column: 1, Offset: 118},
+			EndPos:    token.Position{Line: 6, Column: 32, Offset: 149},
+		},
+		{
+			Type:      "Assert",
+			Query:     ast.Query{Type: "Query", Kind: "body"},
+			Predicate: ast.Predicate{Type: "Predicate", Op: "matches", Value: &ast.Literal{Type: "Literal", Value: "^\\{"}},
+			StartPos:  token.Position{Line: 7, Column: 1, Offset: 150},
+			EndPos:    token.Position{Line: 7, Column: 20, Offset: 169},
+		},
+		{
+			Type:      "Assert",
+			Query:     ast.Query{Type: "Query", Kind: "duration"},
+			Predicate: ast.Predicate{Type: "Predicate", Op: "<=", Value: &ast.Literal{Type: "Literal", Value: 1000.0}},
+			StartPos:  token.Position{Line: 8, Column: 1, Offset: 170},
+			EndPos:    token.Position{Line: 8, Column: 17, Offset: 186},
+		},
+		{
+			Type:      "Assert",
+			Query:     ast.Query{Type: "Query", Kind: "jsonpath", Arg: "$.total"},
+			Predicate: ast.Predicate{Type: "Predicate", Op: "isInteger"},
+			StartPos:  token.Position{Line: 9, Column: 1, Offset: 187},
+			EndPos:    token.Position{Line: 9, Column: 29, Offset: 215},
+		},
+	}
+
+	res := program.RootValue.Entries[0].Res
+	if !reflect.DeepEqual(res.Asserts, expected) {
+		t.Fatalf("error: unexpected asserts\nexpected: %+v\ngot: %+v", expected, res.Asserts)
+	}
+	if len(res.Capture) != 1 || res.Capture[0].Query.Arg != "$.data[0].id" {
+		t.Fatalf("error: expected the capture after the asserts, got: %+v", res.Capture)
+	}
+}
+
+func TestParseAssertErrors(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{input: "[Asserts]\nsize == 1", err: "line 4, column 1: expected a query (status, header, cookie, jsonpath, regex, xpath, body, url or duration), got: `size`"},
+		{input: "[Asserts]\nheader\n", err: "line 4, column 7: expected string, got: `\\n`"},
+		{input: "[Asserts]\nstatus is 200", err: "line 4, column 8: expected a predicate, got: `is`"},
+		{input: "[Asserts]\nstatus == ok", err: "line 4, column 11: expected a value, got: `ok`"},
+		{input: "[Asserts]\nbody startsWith 1", err: "line 4, column 17: expected a string after `startsWith`, got: `1`"},
+		{input: "[Asserts]\nbody matches \"(\"", err: "line 4, column 14: invalid regex `(`: error parsing regexp: missing closing ): `(`"},
+		{input: "[Asserts]\njsonpath \"data\" exists", err: "line 4, column 11: invalid JSON path, expected `$`: `data`"},
+		{input: "[Asserts]\njsonpath \"$.data[?(@.id > )]\" exists", err: "line 4, column 27: invalid JSON path, expected a path or a value, got: `)`: `$.data[?(@.id > )]`"},
+		{input: "[Asserts]\nstatus exists\n[Asserts]", err: "line 5, column 1: duplicate section `[Asserts]`"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("GET https://test.com\nHTTP 200\n" + test.input)
+		p := New(l)
+
+		_, err := p.ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error for %q", test.input)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+	}
+}
+
+func TestParseCaptures(t *testing.T) {
+	input := `GET https://test.com
+HTTP 200
+[Capture]
+status: status
+token: header "Authorization"
+session: cookie "SID"
+id: jsonpath "$.data[0].id"
+legacy_id: $.data[0].id
+code: regex "code=(\\d+)"
+title: xpath "//head/title"
+raw: body
+location: url`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	expected := []ast.Query{
+		{Type: "Query", Kind: "status"},
+		{Type: "Query", Kind: "header", Arg: "Authorization"},
+		{Type: "Query", Kind: "cookie", Arg: "SID"},
+		{Type: "Query", Kind: "jsonpath", Arg: "$.data[0].id"},
+		{Type: "Query", Kind: "jsonpath", Arg: "$.data[0].id"},
+		{Type: "Query", Kind: "regex", Arg: "code=(\\d+)"},
+		{Type: "Query", Kind: "xpath", Arg: "//head/title"},
+		{Type: "Query", Kind: "body"},
+		{Type: "Query", Kind: "url"},
+	}
+	names := []string{"status", "token", "session", "id", "legacy_id", "code", "title", "raw", "location"}
+
+	captures := program.RootValue.Entries[0].Res.Capture
+	if len(captures) != len(expected) {
+		t.Fatalf("error: expected %d captures, got: %+v", len(expected), captures)
+	}
+	for i, capture := range captures {
+		if capture.Type != "Capture" || capture.Name != names[i] || capture.Query != expected[i] {
+			t.Fatalf("captures[%d] - expected %s: %+v, got: %+v", i, names[i], expected[i], capture)
+		}
+		if capture.StartPos.Line != i+4 || capture.StartPos.Column != 1 {
+			t.Fatalf("captures[%d] - unexpected position: %+v", i, capture.StartPos)
+		}
+	}
+}
+
+func TestParseCaptureErrors(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{input: "id: value", err: "line 4, column 5: expected a query (status, header, cookie, jsonpath, regex, xpath, body, url or duration), got: `value`"},
+		{input: "id header \"X\"", err: "line 4, column 4: expected `:`, got: `header`"},
+		{input: "user id: body", err: "line 4, column 6: expected `:`, got: `id:`"},
+		{input: "id: header", err: "line 4, column 11: expected string, got: ``"},
+		{input: "id: $.data[0", err: "line 4, column 11: invalid JSON path, expected `]`: `$.data[0`"},
+		{input: "id: jsonpath \"$.data[1:2:0]\"", err: "line 4, column 27: invalid JSON path, slice step cannot be zero: `$.data[1:2:0]`"},
+		{input: "id: regex \"(\"", err: "line 4, column 11: invalid regex `(`: error parsing regexp: missing closing ): `(`"},
+		{input: "id: xpath \"title\"", err: "line 4, column 11: invalid XPath, expected `/`: `title`"},
+		{input: "id: body extra", err: "line 4, column 10: expected new line, got: `extra`"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("GET https://test.com\nHTTP 200\n[Capture]\n" + test.input)
+		p := New(l)
+
+		_, err := p.ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error for %q", test.input)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	input := `# create a user
+POST https://test.com/users # no auth
+# json payload
+Content-Type: application/json # required
+{"name": "nugget"}
+# expect created
+HTTP 201
+[Capture]
+id: $.id
+
+# delete it
+DELETE https://test.com/users/#
+# the end`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	checkParserErrors(t, p)
+
+	entries := program.RootValue.Entries
+	if len(entries) != 2 {
+		t.Fatalf("the length of the entries is not correct: got %d", len(entries))
+	}
+
+	tests := [...]struct {
+		comments []ast.Comment
+		expected []string
+		inline   []bool
+	}{
+		{comments: entries[0].Comments, expected: []string{"# create a user"}, inline: []bool{false}},
+		{comments: entries[0].Req.Comments, expected: []string{"# no auth"}, inline: []bool{true}},
+		{comments: entries[0].Req.Header[0].Comments, expected: []string{"# json payload", "# required"}, inline: []bool{false, true}},
+		{comments: entries[0].Res.Comments, expected: []string{"# expect created"}, inline: []bool{false}},
+		{comments: entries[0].Res.Capture[0].Comments, expected: nil, inline: nil},
+		{comments: entries[1].Comments, expected: []string{"# delete it"}, inline: []bool{false}},
+		{comments: program.RootValue.Comments, expected: []string{"# the end"}, inline: []bool{false}},
+	}
+
+	for i, test := range tests {
+		if len(test.comments) != len(test.expected) {
+			t.Fatalf("tests[%d] - expected %d comments, got: %+v", i, len(test.expected), test.comments)
+		}
+		for j, c := range test.comments {
+			if c.Text != test.expected[j] || c.Inline != test.inline[j] {
+				t.Fatalf("tests[%d] - expected comment %q (inline: %v), got: %+v", i, test.expected[j], test.inline[j], c)
+			}
+		}
+	}
+
+	if entries[1].Req.Line.Url != "https://test.com/users/#" {
+		t.Fatalf("error: expected url with fragment, got: %s", entries[1].Req.Line.Url)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := [...]struct {
+		input    string
+		line     int
+		column   int
+		offset   int
+		expected []token.Type
+		found    token.Type
+		msg      string
+	}{
+		{
+			input: "HTTP 200", line: 1, column: 1, offset: 0,
+			expected: token.Methods(), found: token.Http,
+			msg: "expected HTTP method, got: HTTP",
+		},
+		{
+			input: "GET https://test.com\nHTTP ok", line: 2, column: 6, offset: 26,
+			expected: []token.Type{token.Number}, found: token.String,
+			msg: "expected status code, got: `ok`",
+		},
+		{
+			input: "GET https://test.com\n  header value", line: 2, column: 10, offset: 30,
+			expected: []token.Type{token.Colon}, found: token.String,
+			msg: "expected `:`, got: `value`",
+		},
+		{
+			input: "GET https://test.com\nHTTP 200\n200", line: 3, column: 1, offset: 30,
+			expected: token.Methods(), found: token.Number,
+			msg: "expected HTTP method, got: 200",
+		},
+		{
+			input: "# café\nHTTP 200", line: 2, column: 1, offset: 8,
+			expected: token.Methods(), found: token.Http,
+			msg: "expected HTTP method, got: HTTP",
+		},
+		{
+			input: "", line: 1, column: 1, offset: 0,
+			expected: token.Methods(), found: token.EOF,
+			msg: "expected a request, got: ``",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		_, err := p.ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error for %q", test.input)
+		}
+
+		var list ErrorList
+		if !errors.As(err, &list) || len(list) != 1 {
+			t.Fatalf("error: expected an ErrorList with one error, got: %#v", err)
+		}
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("error: expected a *Error, got: %#v", err)
+		}
+
+		if e.Line != test.line || e.Column != test.column || e.Offset != test.offset {
+			t.Fatalf("error: expected position %d:%d (offset %d), got: %d:%d (offset %d)",
+				test.line, test.column, test.offset, e.Line, e.Column, e.Offset)
+		}
+		if !reflect.DeepEqual(e.Expected, test.expected) {
+			t.Fatalf("error: expected %v to be expected, got: %v", test.expected, e.Expected)
+		}
+		if e.Found.Type != test.found {
+			t.Fatalf("error: expected to find %s, got: %s", test.found, e.Found.Type)
+		}
+		if e.Msg != test.msg {
+			t.Fatalf("error: expected message %q, got: %q", test.msg, e.Msg)
+		}
+	}
+}
+
+func TestParseRecover(t *testing.T) {
+	input := `GET https://test.com/a
+HTTP 200
+
+GET https://test.com/b
+HTTP ok
+
+POST https://test.com/c
+{"name": "nugget"
+GET https://test.com/d
+header value
+HTTP 200
+
+DELETE https://test.com/e
+HTTP 204`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram(Recover())
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("error: expected an ErrorList, got: %#v", err)
+	}
+
+	expectedErrors := []string{
+		"line 5, column 6: expected status code, got: `ok`",
+		"line 9, column 1: expected `,` or `}`, got: `GET`",
+		"line 10, column 8: expected `:`, got: `value`",
+	}
+	if len(list) != len(expectedErrors) {
+		t.Fatalf("error: expected %d errors, got: %v", len(expectedErrors), list)
+	}
+	for i, e := range list {
+		if e.Error() != expectedErrors[i] {
+			t.Fatalf("errors[%d] - expected %q, got: %q", i, expectedErrors[i], e.Error())
+		}
+	}
+
+	if program.RootValue == nil {
+		t.Fatal("error: expected the entries that parsed")
+	}
+
+	urls := []string{"https://test.com/a", "https://test.com/e"}
+	if len(program.RootValue.Entries) != len(urls) {
+		t.Fatalf("the length of the entries is not correct: got %d", len(program.RootValue.Entries))
+	}
+	for i, entry := range program.RootValue.Entries {
+		if entry.Req.Line.Url != urls[i] {
+			t.Fatalf("entries[%d] - expected url %s, got: %s", i, urls[i], entry.Req.Line.Url)
+		}
+	}
+}
+
+func TestParseStrictStopsAtFirstError(t *testing.T) {
+	input := `GET https://test.com/a
+HTTP ok
+GET https://test.com/b
+HTTP nope`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if program.RootValue != nil {
+		t.Fatalf("error: expected no AST, got: %+v", program.RootValue)
+	}
+	if len(p.Errors()) != 1 {
+		t.Fatalf("error: expected 1 error, got: %v", p.Errors())
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{input: "GET https://test.com header: v", err: "line 1, column 22: expected new line, got: `header:`"},
+		{input: "GET\nHTTP 200", err: "line 1, column 4: expected url, got: `\\n`"},
+		{input: "GET https://test.com\nHTTP\n200", err: "line 2, column 5: expected status code, got: `\\n`"},
+		{input: "GET https://test.com\nHTTP 200 OK", err: "line 2, column 10: expected new line, got: `OK`"},
+		{input: "GET https://test.com\nHTTP 200\n[Capture] id: $.id", err: "line 3, column 11: expected new line, got: `id:`"},
+		{input: "GET https://test.com\n{\"a\": 1} x", err: "line 2, column 10: expected new line, got: `x`"},
+		{input: "GET https://test.com\nX-Id\nHTTP 200", err: "line 2, column 5: expected `:`, got: `\\n`"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		_, err := p.ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error for %q", test.input)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+	}
+}
+
+func TestParseWhitespaceTokens(t *testing.T) {
+	input := `
+
+	# list the users
+	GET https://test.com/users  # all of them
+	Accept:   application/json
+
+
+	HTTP 200
+	[Capture]
+	id:   $.data[0].id
+
+POST https://test.com/users
+{
+	"name": "nugget",
+	"tags": [ "a", "b" ]
+}
+HTTP 201
+`
+
+	expected, err := New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	program, err := New(lexer.New(input, lexer.EmitWhitespace())).ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	if len(program.RootValue.Entries) != 2 {
+		t.Fatalf("error: expected 2 entries, got: %d", len(program.RootValue.Entries))
+	}
+	if !reflect.DeepEqual(program, expected) {
+		t.Fatalf("error: the whitespace tokens changed the AST\nexpected: %+v\ngot: %+v", expected.RootValue, program.RootValue)
+	}
+}
+
+func TestParseTemplates(t *testing.T) {
+	input := `POST {{host}}/users
+Authorization: {{token}}
+{"id": {{id}}}`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	req := program.RootValue.Entries[0].Req
+
+	expectedUrl := &ast.Template{
+		Type: "Template",
+		Parts: []ast.TemplatePart{
+			{Type: "Variable", Value: "host", Pos: token.Position{Line: 1, Column: 6, Offset: 5}},
+			{Type: "Text", Value: "/users", Pos: token.Position{Line: 1, Column: 14, Offset: 13}},
+		},
+	}
+	if !reflect.DeepEqual(req.Line.UrlTemplate, expectedUrl) {
+		t.Fatalf("error: expected %+v, got: %+v", expectedUrl, req.Line.UrlTemplate)
+	}
+
+	expectedHeader := &ast.Template{
+		Type: "Template",
+		Parts: []ast.TemplatePart{
+			{Type: "Variable", Value: "token", Pos: token.Position{Line: 2, Column: 16, Offset: 35}},
+		},
+	}
+	if !reflect.DeepEqual(req.Header[0].ValueTemplate, expectedHeader) {
+		t.Fatalf("error: expected %+v, got: %+v", expectedHeader, req.Header[0].ValueTemplate)
+	}
+
+	expectedValue := ast.Object{
+		Type: "Object",
+		Children: []ast.Property{
+			{
+				Type: "Property",
+				Key: ast.Identifier{Type: "Identifier", Value: "id"},
+				Value: ast.Template{
+					Type: "Template",
+					Parts: []ast.TemplatePart{
+						{Type: "Variable", Value: "id", Pos: token.Position{Line: 3, Column: 8, Offset: 52}},
+					},
+				},
+			},
+		},
+		Start: 45,
+		End: 59,
+	}
+	if !reflect.DeepEqual(req.Body.Value, expectedValue) {
+		t.Fatalf("error: expected %+v, got: %+v", expectedValue, req.Body.Value)
+	}
+	if req.Body.Template == nil || len(req.Body.Template.Parts) != 3 {
+		t.Fatalf("error: expected a body template with 3 parts, got: %+v", req.Body.Template)
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{input: "GET https://test.com/{{id", err: "line 1, column 22: unterminated variable, expected `}}`: `{{id`"},
+		{input: "GET https://test.com/{{}}", err: "line 1, column 22: invalid variable name: `{{}}`"},
+		{input: "GET https://test.com\nX-Id: a{{user id}}", err: "line 2, column 8: invalid variable name: `{{user id}}`"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		_, err := p.ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error for %q", test.input)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+	}
+}
+
+func TestParseHeaderValues(t *testing.T) {
+	input := "GET https://test.com\n" +
+		"Authorization: Bearer abc\n" +
+		"Date: Tue, 15 Nov 1994 08:12:31 GMT\n" +
+		"Accept : text/html, application/json;q=0.9\n" +
+		"X-Empty:\n" +
+		"X-Compact:value\n" +
+		"X-Token: Bearer {{token}} # from login\n" +
+		"If-None-Match: \"abc\"\n" +
+		"If-Match: \"a\", \"b\" # a list\n" +
+		"X-Msg: \"hello\" world\n" +
+		"HTTP 200\n" +
+		"[Capture]\n" +
+		"id: $.data[0].id"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	entry := program.RootValue.Entries[0]
+
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"Authorization", "Bearer abc"},
+		{"Date", "Tue, 15 Nov 1994 08:12:31 GMT"},
+		{"Accept", "text/html, application/json;q=0.9"},
+		{"X-Empty", ""},
+		{"X-Compact", "value"},
+		{"X-Token", "Bearer {{token}}"},
+		{"If-None-Match", `"abc"`},
+		{"If-Match", `"a", "b"`},
+		{"X-Msg", `"hello" world`},
+	}
+	if len(entry.Req.Header) != len(tests) {
+		t.Fatalf("error: expected %d headers, got: %+v", len(tests), entry.Req.Header)
+	}
+	for i, tt := range tests {
+		header := entry.Req.Header[i]
+		if header.Key != tt.key || header.Value != tt.value {
+			t.Fatalf("headers[%d] - expected %q: %q, got: %q: %q", i, tt.key, tt.value, header.Key, header.Value)
+		}
+	}
+
+	header := entry.Req.Header[5]
+	if len(header.Comments) != 1 || header.Comments[0].Text != "# from login" {
+		t.Fatalf("error: unexpected comments: %+v", header.Comments)
+	}
+	tmpl := header.ValueTemplate
+	if tmpl == nil || len(tmpl.Parts) != 2 || tmpl.Parts[1].Pos != (token.Position{Line: 7, Column: 17, Offset: 167}) {
+		t.Fatalf("error: unexpected template: %+v", tmpl)
+	}
+
+	if entry.Res.Capture[0].Name != "id" || entry.Res.Capture[0].Query.Arg != "$.data[0].id" {
+		t.Fatalf("error: unexpected capture: %+v", entry.Res.Capture[0])
+	}
+}
+
+func TestParseQuotedStrings(t *testing.T) {
+	input := `GET "https://test.com/search?q=a b"
+If-None-Match: "abc"
+X-Template: "Bearer {{token}}"
+HTTP 200
+[Capture]
+id: "$.data[0].id"
+escaped: header "say \"hi\"\t\\ \u00e9\n"`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	entry := program.RootValue.Entries[0]
+
+	if entry.Req.Line.Url != "https://test.com/search?q=a b" {
+		t.Fatalf("error: unexpected url: %q", entry.Req.Line.Url)
+	}
+
+	// header values are verbatim, their quotes included
+	headers := []string{`"abc"`, `"Bearer {{token}}"`}
+	if len(entry.Req.Header) != len(headers) {
+		t.Fatalf("error: expected %d headers, got: %+v", len(headers), entry.Req.Header)
+	}
+	for i, header := range entry.Req.Header {
+		if header.Value != headers[i] {
+			t.Fatalf("headers[%d] - expected %q, got: %q", i, headers[i], header.Value)
+		}
+	}
+
+	tmpl := entry.Req.Header[1].ValueTemplate
+	if tmpl == nil || len(tmpl.Parts) != 3 || tmpl.Parts[1].Pos != (token.Position{Line: 3, Column: 21, Offset: 77}) {
+		t.Fatalf("error: unexpected template: %+v", tmpl)
+	}
+
+	if entry.Res.Capture[0].Query.Arg != "$.data[0].id" {
+		t.Fatalf("error: unexpected capture: %q", entry.Res.Capture[0].Query.Arg)
+	}
+	if entry.Res.Capture[1].Query.Arg != "say \"hi\"\t\\ é\n" {
+		t.Fatalf("error: unexpected capture: %q", entry.Res.Capture[1].Query.Arg)
+	}
+}
+
+func TestParseQuotedStringErrors(t *testing.T) {
+	tests := [...]struct {
+		input string
+		err   string
+	}{
+		{input: "GET \"https://test.com", err: "line 1, column 5: unterminated string: `\"https://test.com`"},
+		{input: "GET https://test.com\nHTTP 200\n[Capture]\na: header \"nug", err: "line 4, column 11: unterminated string: `\"nug`"},
+		{input: "GET https://test.com\nHTTP 200\n[Capture]\na: header \"\\q\"", err: "line 4, column 11: invalid escape sequence `\\q` in string: `\"\\q\"`"},
+		{input: "GET https://test.com\nHTTP 200\n[Capture]\na: header \"\\u12\"", err: "line 4, column 11: invalid unicode escape in string: `\"\\u12\"`"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input)
+		p := New(l)
+
+		_, err := p.ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error for %q", test.input)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+	}
+}
+
+func TestParseHTTPFile(t *testing.T) {
+	input := `# the server
+@host = https://test.com
+@url = {{host}}/api
+
+### create a todo
+POST {{url}}/todos HTTP/1.1
+Content-Type: application/json
+
+{"title": "a"}
+
+###
+@user = bob
+// list the todos of bob
+GET {{url}}/todos?user={{user}}`
+
+	p := New(lexer.New(input, lexer.HTTPFile()))
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	nugget := program.RootValue
+	if len(nugget.Variables) != 3 || len(nugget.Entries) != 2 {
+		t.Fatalf("error: unexpected nugget %+v", nugget)
+	}
+	expected := []struct{ key, value string }{{"host", "https://test.com"}, {"url", "{{host}}/api"}, {"user", "bob"}}
+	for i, v := range nugget.Variables {
+		if v.Key != expected[i].key || v.Value != expected[i].value {
+			t.Fatalf("variables[%d] - expected %v, got: %+v", i, expected[i], v)
+		}
+	}
+	if len(nugget.Variables[0].Comments) != 1 || nugget.Variables[0].Comments[0].Text != "# the server" {
+		t.Fatalf("error: unexpected comments %+v", nugget.Variables[0].Comments)
+	}
+	parts := nugget.Variables[1].ValueTemplate.Parts
+	if parts[0].Value != "host" || parts[0].Pos != (token.Position{Line: 3, Column: 8, Offset: 45}) {
+		t.Fatalf("error: unexpected template %+v", parts)
+	}
+
+	first := nugget.Entries[0]
+	if first.Req.Line.Url != "{{url}}/todos" || first.Req.Body == nil || first.Req.Body.Raw != `{"title": "a"}` {
+		t.Fatalf("error: unexpected request %+v", first.Req)
+	}
+	if len(first.Comments) != 1 || first.Comments[0].Text != "### create a todo" {
+		t.Fatalf("error: unexpected comments %+v", first.Comments)
+	}
+	second := nugget.Entries[1]
+	if len(second.Comments) != 1 || second.Comments[0].Text != "// list the todos of bob" {
+		t.Fatalf("error: unexpected comments %+v", second.Comments)
+	}
+	if comments := nugget.Variables[2].Comments; len(comments) != 1 || comments[0].Text != "###" {
+		t.Fatalf("error: unexpected comments %+v", comments)
+	}
+}
+
+func TestParseHTTPFileErrors(t *testing.T) {
+	input := "@host\nGET a\n@1 x = b\nGET b HTTP/1.1 c\n@ok = 1\nGET c"
+
+	p := New(lexer.New(input, lexer.HTTPFile()))
+	program, err := p.ParseProgram(Recover())
+
+	var errs ErrorList
+	if !errors.As(err, &errs) {
+		t.Fatalf("error: expected an ErrorList, got: %v", err)
+	}
+	expected := []string{
+		"line 1, column 1: expected `@name = value`, got: `@host`",
+		"line 3, column 1: invalid variable name: `1 x`",
+		"line 4, column 16: expected new line, got: `c`",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("error: expected %d errors, got: %v", len(expected), err)
+	}
+	for i, e := range errs {
+		if e.Error() != expected[i] {
+			t.Fatalf("errors[%d] - expected %q, got: %q", i, expected[i], e.Error())
+		}
+	}
+	if nugget := program.RootValue; len(nugget.Entries) != 2 || len(nugget.Variables) != 1 || nugget.Variables[0].Key != "ok" {
+		t.Fatalf("error: unexpected nugget %+v", nugget)
+	}
+
+	// the dialect needs the lexer option
+	if _, err := New(lexer.New("@host = a\nGET {{host}}")).ParseProgram(); err == nil {
+		t.Fatal("error: expected an error without the HTTPFile option")
+	}
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"nug/pkg/ast"
+	"nug/pkg/token"
+)
+
+// parseTemplate splits a string found at pos into its literal text and its
+// `{{name}}` references. It returns nil when the string has no reference, so
+// plain strings don't carry an extra node.
+func (p *Parser) parseTemplate(s string, pos token.Position) *ast.Template {
+	if !strings.Contains(s, "{{") {
+		return nil
+	}
+
+	tmpl := &ast.Template{Type: "Template"}
+
+	for len(s) > 0 {
+		open := strings.Index(s, "{{")
+		if open == -1 {
+			tmpl.Parts = append(tmpl.Parts, ast.TemplatePart{Type: "Text", Value: s, Pos: pos})
+			break
+		}
+		if open > 0 {
+			tmpl.Parts = append(tmpl.Parts, ast.TemplatePart{Type: "Text", Value: s[:open], Pos: pos})
+			pos = advance(pos, s[:open])
+			s = s[open:]
+		}
+
+		end := strings.Index(s, "}}")
+		if end == -1 || strings.Contains(s[:end], "\n") {
+			p.templateError(pos, fmt.Sprintf("unterminated variable, expected `}}`: `%s`", firstLine(s)))
+			return nil
+		}
+
+		name := strings.TrimSpace(s[2:end])
+		if !isVariableName(name) {
+			p.templateError(pos, fmt.Sprintf("invalid variable name: `%s`", s[:end+2]))
+			return nil
+		}
+
+		tmpl.Parts = append(tmpl.Parts, ast.TemplatePart{Type: "Variable", Value: name, Pos: pos})
+		pos = advance(pos, s[:end+2])
+		s = s[end+2:]
+	}
+
+	return tmpl
+}
+
+// templateError reports an error inside the current token, at pos
+func (p *Parser) templateError(pos token.Position, msg string) {
+	t := p.currentToken
+	t.StartPos = pos
+	p.errorAt(t, msg)
+}
+
+// advance returns the position found after reading text from pos
+func advance(pos token.Position, text string) token.Position {
+	for _, char := range text {
+		pos.Offset += utf8.RuneLen(char)
+		if char == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
+// isVariableName reports whether name is made of letters, digits, `_`, `-`
+// and `.` only
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, char := range name {
+		if !isDigit(char) && !strings.ContainsRune("_-.", char) &&
+			!('a' <= char && char <= 'z' || 'A' <= char && char <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,471 @@
+package postman
+
+// The postman package converts a Postman collection (format v2.1) to nugget
+// entries: one entry per request, in the order of the folders. The variables
+// of the collection and of an environment are listed in a comment, to be
+// given to `nug run`, and the variables the test scripts set from the
+// response become captures. Nuggets only send JSON bodies, so a request with
+// any other body, such as a form, is converted without it and reported. See
+// https://schema.postman.com
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/format"
+	"nug/pkg/token"
+)
+
+// Error is a part of a collection that cannot be converted. Item is the path
+// of the request or the folder, e.g. "Users / Create a user".
+type Error struct {
+	Item string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Item == "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Item, e.Msg)
+}
+
+// ErrorList is the list of errors returned by Import. Use errors.As to get it
+// back from the returned error.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type collection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []item     `json:"item"`
+	Variable []variable `json:"variable"`
+	Auth     *auth      `json:"auth"`
+	Event    []event    `json:"event"`
+}
+
+// item is a request, or a folder of items when Request is nil
+type item struct {
+	Name    string          `json:"name"`
+	Item    []item          `json:"item"`
+	Request json.RawMessage `json:"request"` // a request, or its url for a GET
+	Auth    *auth           `json:"auth"`    // auth of the requests of a folder
+	Event   []event         `json:"event"`
+}
+
+type request struct {
+	Method string          `json:"method"`
+	Header []keyValue      `json:"header"`
+	URL    json.RawMessage `json:"url"` // a string or an object
+	Body   *body           `json:"body"`
+	Auth   *auth           `json:"auth"`
+}
+
+type keyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+type urlObject struct {
+	Raw      string     `json:"raw"`
+	Variable []keyValue `json:"variable"` // values of the `:name` path segments
+}
+
+type body struct {
+	Mode     string `json:"mode"` // "raw", "urlencoded", "formdata", "file" or "graphql"
+	Raw      string `json:"raw"`
+	Disabled bool   `json:"disabled"`
+}
+
+// auth is the authorization of a request, its parameters are listed under the
+// name of its type
+type auth struct {
+	Type   string     `json:"type"` // "noauth", "bearer", "basic", "apikey", ...
+	Bearer []keyValue `json:"bearer"`
+	Basic  []keyValue `json:"basic"`
+	Apikey []keyValue `json:"apikey"`
+}
+
+// param returns the value of a parameter of an auth
+func param(params []keyValue, key string) string {
+	for _, p := range params {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+type event struct {
+	Listen string `json:"listen"` // "prerequest" or "test"
+	Script struct {
+		Exec json.RawMessage `json:"exec"` // a list of lines or a string
+	} `json:"script"`
+}
+
+// lines returns the lines of the script, without the blank ones
+func (e event) lines() []string {
+	var lines []string
+	if err := json.Unmarshal(e.Script.Exec, &lines); err != nil {
+		var s string
+		json.Unmarshal(e.Script.Exec, &s)
+		lines = strings.Split(s, "\n")
+	}
+	var text []string
+	for _, line := range lines {
+		for _, l := range strings.Split(line, "\n") {
+			if strings.TrimSpace(l) != "" {
+				text = append(text, l)
+			}
+		}
+	}
+	return text
+}
+
+// variable is a variable of a collection or of an environment, whose value
+// can be of any JSON type
+type variable struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	Disabled bool            `json:"disabled"` // in a collection
+	Enabled  *bool           `json:"enabled"`  // in an environment, nil for true
+	Type     string          `json:"type"`     // "secret" for a value to leave out
+}
+
+func (v variable) text() string {
+	var s string
+	if err := json.Unmarshal(v.Value, &s); err != nil {
+		return string(v.Value)
+	}
+	return s
+}
+
+type environment struct {
+	Values []variable `json:"values"`
+}
+
+// Option configures Import
+type Option func(*importer)
+
+// Environment gives the variables of a Postman environment, exported as JSON,
+// which override the variables of the collection
+func Environment(data []byte) Option {
+	return func(im *importer) {
+		im.environment = data
+	}
+}
+
+type importer struct {
+	environment []byte
+	errs        ErrorList
+}
+
+func (im *importer) errorf(item, format string, args ...interface{}) {
+	im.errs = append(im.errs, &Error{Item: item, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Import converts the requests of a collection to nugget entries. A request
+// that cannot be converted, or a part of it, is reported in the returned
+// ErrorList. The entries are parsed back from their source so their
+// templates, bodies and positions are filled in.
+func Import(data []byte, opts ...Option) (*ast.Nugget, error) {
+	im := &importer{}
+	for _, opt := range opts {
+		opt(im)
+	}
+
+	var c collection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid collection: %v", err)
+	}
+	if !strings.Contains(c.Info.Schema, "/v2.1") {
+		return nil, fmt.Errorf("unsupported collection, expected the v2.1 format, got: `%s`", c.Info.Schema)
+	}
+	vars := c.Variable
+	if im.environment != nil {
+		var env environment
+		if err := json.Unmarshal(im.environment, &env); err != nil {
+			return nil, fmt.Errorf("invalid environment: %v", err)
+		}
+		vars = append(vars, env.Values...)
+	}
+
+	for _, e := range c.Event {
+		if len(e.lines()) > 0 {
+			im.errorf("", "unsupported %s script of the collection", e.Listen)
+		}
+	}
+	entries := im.items(c.Item, "", c.Auth)
+
+	nugget := &ast.Nugget{}
+	if len(entries) > 0 {
+		if comment := variables(vars); comment != "" {
+			entries[0].Comments = append([]ast.Comment{{Type: "Comment", Text: comment}}, entries[0].Comments...)
+		}
+		nugget.Entries = entries
+		// the positions become the ones in the source of all the entries
+		reparsed, err := format.Reparse(nugget)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the entries back: %v", err)
+		}
+		nugget = reparsed
+	}
+
+	if len(im.errs) > 0 {
+		return nugget, im.errs
+	}
+	return nugget, nil
+}
+
+// items converts the requests of a folder, whose path is folder and auth is
+// the one its requests inherit
+func (im *importer) items(items []item, folder string, inherited *auth) []ast.Entry {
+	var entries []ast.Entry
+	for _, it := range items {
+		path := it.Name
+		if folder != "" {
+			path = folder + " / " + it.Name
+		}
+
+		if it.Request == nil {
+			a := inherited
+			if it.Auth != nil {
+				a = it.Auth
+			}
+			for _, e := range it.Event {
+				if len(e.lines()) > 0 {
+					im.errorf(path, "unsupported %s script of the folder", e.Listen)
+				}
+			}
+			entries = append(entries, im.items(it.Item, path, a)...)
+			continue
+		}
+
+		entry, ok := im.convert(it, path, inherited)
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// convert converts a request, it returns false when it cannot be converted
+func (im *importer) convert(it item, path string, inherited *auth) (ast.Entry, bool) {
+	var req request
+	if it.Request[0] == '"' {
+		req.Method, req.URL = "GET", it.Request
+	} else if err := json.Unmarshal(it.Request, &req); err != nil {
+		im.errorf(path, "invalid request: %v", err)
+		return ast.Entry{}, false
+	}
+
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+	if !token.IsMethod(token.Type(method)) {
+		im.errorf(path, "unsupported method `%s`", req.Method)
+		return ast.Entry{}, false
+	}
+
+	r := ast.Request{Type: "Request", Line: ast.Endpoint{Type: "Endpoint", Method: method, Url: im.dynamic(path, requestURL(req.URL))}}
+	if r.Line.Url == "" {
+		im.errorf(path, "expected a URL")
+		return ast.Entry{}, false
+	}
+	for _, h := range req.Header {
+		if !h.Disabled {
+			r.Header = append(r.Header, header(h.Key, im.dynamic(path, h.Value)))
+		}
+	}
+
+	a := inherited
+	if req.Auth != nil {
+		a = req.Auth
+	}
+	if a != nil {
+		im.auth(&r, a, path)
+	}
+
+	if req.Body != nil && !req.Body.Disabled {
+		switch raw := strings.TrimSpace(req.Body.Raw); {
+		case req.Body.Mode != "raw":
+			im.errorf(path, "unsupported %s body, converted without it: only raw JSON bodies are supported", req.Body.Mode)
+		case raw == "":
+		case !strings.ContainsAny(raw[:1], "{["):
+			im.errorf(path, "unsupported raw body, converted without it: only JSON objects and arrays are supported")
+		default:
+			r.Body = &ast.Body{Type: "Body", Raw: im.dynamic(path, raw)}
+		}
+	}
+
+	entry := ast.Entry{Type: "Entry", Req: r, Comments: []ast.Comment{{Type: "Comment", Text: "# " + path}}}
+	for _, e := range it.Event {
+		lines := e.lines()
+		switch {
+		case len(lines) == 0:
+		case e.Listen == "test":
+			s := parseScript(lines)
+			for _, stmt := range s.unsupported {
+				im.errorf(path, "unsupported test script statement `%s`", stmt)
+			}
+			if s.status > 0 || len(s.captures) > 0 {
+				entry.Res = ast.Response{Type: "Response", Version: "HTTP", Status: s.status, Capture: s.captures}
+				if s.status == 0 {
+					entry.Res.StatusPattern = "*"
+				}
+			}
+		default:
+			im.errorf(path, "unsupported %s script", e.Listen)
+		}
+	}
+
+	// an entry that would not parse back is left out, with the parser error
+	parsed, err := format.ReparseEntry(entry)
+	if err != nil {
+		im.errorf(path, "cannot convert the request: %v", err)
+		return ast.Entry{}, false
+	}
+	return parsed, true
+}
+
+// requestURL returns the url of a request, with the values of its path
+// variables
+func requestURL(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var u urlObject
+	json.Unmarshal(raw, &u)
+
+	path, rest, _ := strings.Cut(u.Raw, "?")
+	if rest != "" {
+		rest = "?" + rest
+	}
+	segments := strings.Split(path, "/")
+	for _, v := range u.Variable {
+		value := v.Value
+		if value == "" {
+			value = "{{" + v.Key + "}}"
+		}
+		for i, segment := range segments {
+			if segment == ":"+v.Key {
+				segments[i] = value
+			}
+		}
+	}
+	return strings.Join(segments, "/") + rest
+}
+
+// auth adds the header, or the query parameter, of the authorization of a
+// request, unless it already has an Authorization header
+func (im *importer) auth(r *ast.Request, a *auth, path string) {
+	for _, h := range r.Header {
+		if strings.EqualFold(h.Key, "Authorization") {
+			return
+		}
+	}
+
+	switch a.Type {
+	case "noauth", "":
+	case "bearer":
+		r.Header = append(r.Header, header("Authorization", "Bearer "+param(a.Bearer, "token")))
+	case "basic":
+		user, password := param(a.Basic, "username"), param(a.Basic, "password")
+		if strings.Contains(user+password, "{{") {
+			im.errorf(path, "unsupported basic auth with variables, expected a literal username and password")
+			return
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+		r.Header = append(r.Header, header("Authorization", "Basic "+credentials))
+	case "apikey":
+		key, value := param(a.Apikey, "key"), param(a.Apikey, "value")
+		if param(a.Apikey, "in") == "query" {
+			sep := "?"
+			if strings.Contains(r.Line.Url, "?") {
+				sep = "&"
+			}
+			r.Line.Url += sep + key + "=" + value
+			return
+		}
+		r.Header = append(r.Header, header(key, value))
+	default:
+		im.errorf(path, "unsupported auth of type `%s`", a.Type)
+	}
+}
+
+// dynamic replaces the dynamic variables of Postman, like `{{$guid}}`, by
+// variables of the same name, which have to be given a value
+func (im *importer) dynamic(path, s string) string {
+	for {
+		i := strings.Index(s, "{{$")
+		if i == -1 {
+			return s
+		}
+		end := strings.Index(s[i:], "}}")
+		if end == -1 {
+			return s
+		}
+		name := s[i+3 : i+end]
+		im.errorf(path, "unsupported dynamic variable `{{$%s}}`, replaced by `{{%s}}`", name, name)
+		s = s[:i] + "{{" + name + s[i+end:]
+	}
+}
+
+// variables returns the comment giving the values of the variables to
+// `nug run`, "" when there is none. The secret values, and the ones on several
+// lines that the comment cannot hold, are left empty.
+func variables(vars []variable) string {
+	values := map[string]string{}
+	var names []string
+	for _, v := range vars {
+		if v.Key == "" || v.Disabled || v.Enabled != nil && !*v.Enabled {
+			continue
+		}
+		if _, ok := values[v.Key]; !ok {
+			names = append(names, v.Key)
+		}
+		values[v.Key] = v.text()
+		if v.Type == "secret" || strings.ContainsAny(values[v.Key], "\r\n") {
+			values[v.Key] = ""
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+
+	comment := "# nug run"
+	for _, name := range names {
+		comment += " --var " + shellQuote(name+"="+values[name])
+	}
+	return comment
+}
+
+// shellQuote returns s as a single word of the shell
+func shellQuote(s string) string {
+	for _, char := range s {
+		if !(char == '_' || char == '-' || char == '.' || char == '/' || char == ':' || char == '=' ||
+			'0' <= char && char <= '9' || 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z') {
+			return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+		}
+	}
+	return s
+}
+
+func header(key, value string) ast.KeyValue {
+	return ast.KeyValue{Type: "KeyValue", Key: key, Value: value}
+}
@@ -0,0 +1,243 @@
+package postman
+
+import (
+	"errors"
+	"testing"
+
+	"nug/pkg/ast"
+	"nug/pkg/format"
+)
+
+const todos = `{
+  "info": {
+    "name": "Todos",
+    "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+  },
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+  "variable": [
+    {"key": "base_url", "value": "https://test.com"},
+    {"key": "page", "value": 1},
+    {"key": "old", "value": "x", "disabled": true}
+  ],
+  "item": [
+    {
+      "name": "Auth",
+      "auth": {"type": "noauth"},
+      "item": [
+        {
+          "name": "Login",
+          "event": [
+            {
+              "listen": "test",
+              "script": {
+                "type": "text/javascript",
+                "exec": [
+                  "pm.test(\"Status code is 200\", function () {",
+                  "    pm.response.to.have.status(200);",
+                  "});",
+                  "var json = pm.response.json();",
+                  "pm.environment.set(\"token\", json.access_token); // for the next requests",
+                  "pm.collectionVariables.set('user-id', json.user[\"id\"]);",
+                  "pm.globals.set(\"first\", pm.response.json().items[0].id);",
+                  "postman.setEnvironmentVariable(\"session\", pm.response.headers.get(\"X-Session\"));",
+                  "console.log(json);"
+                ]
+              }
+            }
+          ],
+          "request": {
+            "method": "POST",
+            "header": [
+              {"key": "Content-Type", "value": "application/json"},
+              {"key": "X-Debug", "value": "1", "disabled": true}
+            ],
+            "body": {
+              "mode": "raw",
+              "raw": "{\n  \"user\": \"{{user}}\",\n  \"id\": {{$guid}}\n}",
+              "options": {"raw": {"language": "json"}}
+            },
+            "url": {"raw": "{{base_url}}/login", "host": ["{{base_url}}"], "path": ["login"]}
+          }
+        }
+      ]
+    },
+    {
+      "name": "Get a todo",
+      "request": {
+        "method": "GET",
+        "header": [],
+        "url": {
+          "raw": "{{base_url}}/todos/:id?page={{page}}",
+          "variable": [{"key": "id", "value": "42"}]
+        }
+      }
+    },
+    {
+      "name": "Create a todo",
+      "event": [{"listen": "prerequest", "script": {"exec": ["pm.variables.set('x', 1);"]}}],
+      "request": {
+        "method": "POST",
+        "auth": {"type": "basic", "basic": [{"key": "username", "value": "bob"}, {"key": "password", "value": "secret"}]},
+        "body": {
+          "mode": "urlencoded",
+          "urlencoded": [{"key": "title", "value": "a"}]
+        },
+        "url": "{{base_url}}/todos"
+      }
+    },
+    {
+      "name": "Purge",
+      "request": {"method": "PURGE", "url": "{{base_url}}/cache"}
+    },
+    {
+      "name": "Health",
+      "request": "{{base_url}}/health"
+    }
+  ]
+}`
+
+func TestImport(t *testing.T) {
+	env := `{"name": "Local", "values": [
+		{"key": "base_url", "value": "http://localhost:8080", "enabled": true},
+		{"key": "user", "value": "bob smith", "enabled": true},
+		{"key": "token", "value": "abc", "type": "secret", "enabled": true},
+		{"key": "debug", "value": "1", "enabled": false},
+		{"key": "cert", "value": "-----BEGIN-----\nabc\n-----END-----", "enabled": true}
+	]}`
+	nugget, err := Import([]byte(todos), Environment([]byte(env)))
+
+	var errs ErrorList
+	if !errors.As(err, &errs) {
+		t.Fatalf("error: expected an ErrorList, got: %v", err)
+	}
+	expected := []string{
+		"Auth / Login: unsupported dynamic variable `{{$guid}}`, replaced by `{{guid}}`",
+		"Auth / Login: unsupported test script statement `console.log(json)`",
+		"Create a todo: unsupported urlencoded body, converted without it: only raw JSON bodies are supported",
+		"Create a todo: unsupported prerequest script",
+		"Purge: unsupported method `PURGE`",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("error: expected %d errors, got: %v", len(expected), err)
+	}
+	for i, e := range errs {
+		if e.Error() != expected[i] {
+			t.Fatalf("error: expected %q, got: %q", expected[i], e.Error())
+		}
+	}
+
+	got := format.Nugget(nugget)
+	want := `# nug run --var base_url=http://localhost:8080 --var page=1 --var 'user=bob smith' --var token= --var cert=
+# Auth / Login
+POST {{base_url}}/login
+Content-Type: application/json
+{
+  "user": "{{user}}",
+  "id": {{guid}}
+}
+HTTP 200
+[Capture]
+token: jsonpath "$.access_token"
+user-id: jsonpath "$.user['id']"
+first: jsonpath "$.items[0].id"
+session: header "X-Session"
+
+# Get a todo
+GET {{base_url}}/todos/42?page={{page}}
+Authorization: Bearer {{token}}
+
+# Create a todo
+POST {{base_url}}/todos
+Authorization: Basic Ym9iOnNlY3JldA==
+
+# Health
+GET {{base_url}}/health
+Authorization: Bearer {{token}}
+`
+	if got != want {
+		t.Fatalf("error: expected:\n%s\ngot:\n%s", want, got)
+	}
+	if nugget.Entries[0].Req.Body.Value == nil {
+		t.Fatal("error: expected the body to be parsed")
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[", "invalid collection: unexpected end of JSON input"},
+		{`{"info": {"schema": "https://schema.getpostman.com/json/collection/v2.0.0/collection.json"}}`,
+			"unsupported collection, expected the v2.1 format, got: `https://schema.getpostman.com/json/collection/v2.0.0/collection.json`"},
+	}
+
+	for _, tt := range tests {
+		_, err := Import([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("error: expected %q, got: %v", tt.expected, err)
+		}
+	}
+
+	_, err := Import([]byte(todos), Environment([]byte("{")))
+	if err == nil || err.Error() != "invalid environment: unexpected end of JSON input" {
+		t.Fatalf("error: unexpected error %v", err)
+	}
+	// an entry that does not parse back is left out, the others are kept
+	input := `{"info": {"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"}, "item": [
+		{"name": "Debug", "request": {"method": "GET", "url": "https://test.com/debug", "header": [{"key": "X Y", "value": "1"}]}},
+		{"name": "Home", "request": "https://test.com"}
+	]}`
+	nugget, err := Import([]byte(input))
+	if err == nil || err.Error() != "Debug: cannot convert the request: line 3, column 3: expected `:`, got: `Y:`" {
+		t.Fatalf("error: unexpected error %v", err)
+	}
+	if got := format.Nugget(nugget); got != "# Home\nGET https://test.com\n" {
+		t.Fatalf("error: unexpected entries:\n%s", got)
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		lines       []string
+		status      int
+		captures    []ast.Capture
+		unsupported []string
+	}{
+		{
+			[]string{"pm.expect(pm.response.code).to.eql(201);", "const data = JSON.parse(responseBody); postman.setGlobalVariable('id', data.id)"},
+			201,
+			[]ast.Capture{{Type: "Capture", Name: "id", Query: ast.Query{Type: "Query", Kind: "jsonpath", Arg: "$.id"}}},
+			nil,
+		},
+		{
+			[]string{"pm.test('ok', () => {", "  pm.variables.set(\"body\", pm.response.text()); // a;b", "})"},
+			0,
+			[]ast.Capture{{Type: "Capture", Name: "body", Query: ast.Query{Type: "Query", Kind: "body"}}},
+			nil,
+		},
+		{
+			[]string{"pm.environment.set('a', 'b;c')", "pm.environment.set('n', data.id)"},
+			0,
+			nil,
+			[]string{"pm.environment.set('a', 'b;c')", "pm.environment.set('n', data.id)"},
+		},
+	}
+
+	for _, tt := range tests {
+		s := parseScript(tt.lines)
+		if s.status != tt.status || len(s.captures) != len(tt.captures) || len(s.unsupported) != len(tt.unsupported) {
+			t.Fatalf("error: %q: unexpected script %+v", tt.lines, s)
+		}
+		for i, c := range s.captures {
+			if c.Name != tt.captures[i].Name || c.Query != tt.captures[i].Query {
+				t.Fatalf("error: expected %+v, got: %+v", tt.captures[i], c)
+			}
+		}
+		for i, stmt := range s.unsupported {
+			if stmt != tt.unsupported[i] {
+				t.Fatalf("error: expected %q, got: %q", tt.unsupported[i], stmt)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package postman
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"nug/pkg/ast"
+)
+
+// script is what a test script translates to: the expected status and the
+// captures of the variables it sets from the response
+type script struct {
+	status      int
+	captures    []ast.Capture
+	unsupported []string // the statements that cannot be translated
+}
+
+var (
+	// `pm.test("name", function () {` and `});` wrap the statements of a test
+	testStart = regexp.MustCompile(`^pm\.test\(.*(function\s*\(\)|\(\)\s*=>)\s*\{$`)
+	testEnd   = regexp.MustCompile(`^\}\)$`)
+
+	// `var data = pm.response.json()` names the JSON of the response
+	jsonVar = regexp.MustCompile(`^(?:var|let|const)\s+([A-Za-z_$][\w$]*)\s*=\s*(pm\.response\.json\(\)|JSON\.parse\(responseBody\))$`)
+
+	// `pm.environment.set("token", data.token)`, or the setters of the old API
+	setter = regexp.MustCompile(`^(?:pm\.(?:environment|collectionVariables|globals|variables)\.set|postman\.set(?:Environment|Global)Variable)\(\s*(?:"([^"]*)"|'([^']*)')\s*,\s*(.+?)\s*\)$`)
+
+	status = regexp.MustCompile(`^(?:pm\.response\.to\.have\.status|pm\.expect\(pm\.response\.code\)\.to\.(?:eql|equal|be\.equal))\((\d{3})\)$`)
+
+	headerGet = regexp.MustCompile(`^pm\.response\.headers\.get\(\s*(?:"([^"]*)"|'([^']*)')\s*\)$`)
+
+	// `.name`, `[0]` and `["name"]` select a member of a JSON value
+	member = regexp.MustCompile(`^(?:\.([A-Za-z_$][\w$]*)|\[(\d+)\]|\[\s*(?:"([^"]*)"|'([^']*)')\s*\])`)
+)
+
+// parseScript translates the lines of a test script. It knows the statements
+// that check the status of the response and set variables from it, each on
+// a line of their own, inside or outside of a `pm.test`.
+func parseScript(lines []string) script {
+	var s script
+	jsonVars := map[string]bool{"pm.response.json()": true, "JSON.parse(responseBody)": true}
+	for _, stmt := range statements(lines) {
+		if testStart.MatchString(stmt) || testEnd.MatchString(stmt) {
+			continue
+		}
+		if m := jsonVar.FindStringSubmatch(stmt); m != nil {
+			jsonVars[m[1]] = true
+			continue
+		}
+		if m := status.FindStringSubmatch(stmt); m != nil {
+			s.status, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if m := setter.FindStringSubmatch(stmt); m != nil {
+			if q, ok := responseQuery(m[3], jsonVars); ok {
+				name := m[1] + m[2]
+				s.captures = append(s.captures, ast.Capture{Type: "Capture", Name: name, Query: q})
+				continue
+			}
+		}
+		s.unsupported = append(s.unsupported, stmt)
+	}
+	return s
+}
+
+// statements splits the lines of a script into statements, at the end of
+// the lines and at the `;` out of strings, without the comments
+func statements(lines []string) []string {
+	var stmts []string
+	for _, line := range lines {
+		var quote byte
+		start := 0
+		for i := 0; i <= len(line); i++ {
+			if i == len(line) || quote == 0 && (line[i] == ';' || strings.HasPrefix(line[i:], "//")) {
+				if stmt := strings.TrimSpace(line[start:i]); stmt != "" {
+					stmts = append(stmts, stmt)
+				}
+				if i < len(line) && line[i] == '/' {
+					break
+				}
+				start = i + 1
+				continue
+			}
+			switch {
+			case quote != 0 && line[i] == quote:
+				quote = 0
+			case quote == 0 && strings.IndexByte("\"'`", line[i]) != -1:
+				quote = line[i]
+			}
+		}
+	}
+	return stmts
+}
+
+// responseQuery returns the query selecting the value an expression reads
+// from the response, jsonVars are the expressions holding its JSON
+func responseQuery(expr string, jsonVars map[string]bool) (ast.Query, bool) {
+	switch expr {
+	case "pm.response.text()", "responseBody":
+		return ast.Query{Type: "Query", Kind: "body"}, true
+	case "pm.response.code", "responseCode.code":
+		return ast.Query{Type: "Query", Kind: "status"}, true
+	}
+	if m := headerGet.FindStringSubmatch(expr); m != nil {
+		return ast.Query{Type: "Query", Kind: "header", Arg: m[1] + m[2]}, true
+	}
+
+	for v := range jsonVars {
+		if expr != v && !strings.HasPrefix(expr, v+".") && !strings.HasPrefix(expr, v+"[") {
+			continue
+		}
+		path := "$"
+		rest := expr[len(v):]
+		for rest != "" {
+			m := member.FindStringSubmatch(rest)
+			switch {
+			case m == nil:
+				return ast.Query{}, false
+			case m[1] != "":
+				path += "." + m[1]
+			case m[2] != "":
+				path += "[" + m[2] + "]"
+			default:
+				path += "['" + strings.ReplaceAll(m[3]+m[4], "'", `\'`) + "']"
+			}
+			rest = rest[len(m[0]):]
+		}
+		return ast.Query{Type: "Query", Kind: "jsonpath", Arg: path}, true
+	}
+	return ast.Query{}, false
+}
@@ -0,0 +1,40 @@
+package query
+
+import (
+	"fmt"
+
+	"nug/pkg/ast"
+	"nug/pkg/token"
+)
+
+// CaptureError is a capture whose query failed or selected nothing
+type CaptureError struct {
+	Name string
+	Pos  token.Position
+	Err  error // nil when the query selected nothing
+}
+
+func (e *CaptureError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("line %d, column %d: nothing to capture in `%s`", e.Pos.Line, e.Pos.Column, e.Name)
+	}
+	return fmt.Sprintf("line %d, column %d: cannot capture `%s`: %v", e.Pos.Line, e.Pos.Column, e.Name, e.Err)
+}
+
+func (e *CaptureError) Unwrap() error {
+	return e.Err
+}
+
+// Capture evaluates the captures of a response in order and stores their
+// values in vars, so the next entries can use them. It stops at the first
+// capture that fails.
+func Capture(captures []ast.Capture, res Response, vars map[string]string) error {
+	for _, capture := range captures {
+		value, found, err := Eval(capture.Query, res)
+		if err != nil || !found {
+			return &CaptureError{Name: capture.Name, Pos: capture.StartPos, Err: err}
+		}
+		vars[capture.Name] = String(value)
+	}
+	return nil
+}
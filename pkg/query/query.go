@@ -0,0 +1,152 @@
+package query
+
+// The query package selects values of an HTTP response, for the asserts and
+// the captures of a nugget.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"nug/pkg/ast"
+	"nug/pkg/jsonpath"
+	"nug/pkg/xpath"
+)
+
+// Response is the part of an HTTP response the queries read
+type Response struct {
+	Status   int
+	Header   http.Header
+	Body     []byte
+	URL      *url.URL // url of the response, after the redirects
+	Duration time.Duration
+}
+
+// NewResponse reads the whole body of res and closes it
+func NewResponse(res *http.Response) (Response, error) {
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return Response{}, err
+	}
+
+	response := Response{Status: res.StatusCode, Header: res.Header, Body: body}
+	if res.Request != nil {
+		response.URL = res.Request.URL
+	}
+	return response, nil
+}
+
+// Eval returns the value of res selected by q, and whether there is such a
+// value. Numbers are float64 or json.Number, and a query selecting several
+// values returns all of them in a []interface{}.
+func Eval(q ast.Query, res Response) (interface{}, bool, error) {
+	switch q.Kind {
+	case "status":
+		return float64(res.Status), true, nil
+	case "duration":
+		return float64(res.Duration.Milliseconds()), true, nil
+	case "body":
+		return string(res.Body), true, nil
+	case "url":
+		if res.URL == nil {
+			return nil, false, nil
+		}
+		return res.URL.String(), true, nil
+	case "header":
+		return values(res.Header.Values(q.Arg))
+	case "cookie":
+		var cookies []string
+		for _, cookie := range (&http.Response{Header: res.Header}).Cookies() {
+			if cookie.Name == q.Arg {
+				cookies = append(cookies, cookie.Value)
+			}
+		}
+		return values(cookies)
+	case "regex":
+		re, err := regexp.Compile(q.Arg)
+		if err != nil {
+			return nil, false, err
+		}
+		match := re.FindSubmatch(res.Body)
+		switch {
+		case match == nil:
+			return nil, false, nil
+		case len(match) > 1:
+			// the first group is the value, when there is one
+			return string(match[1]), true, nil
+		}
+		return string(match[0]), true, nil
+	case "xpath":
+		path, err := xpath.Parse(q.Arg)
+		if err != nil {
+			return nil, false, err
+		}
+		found, err := path.Eval(res.Body)
+		if err != nil {
+			return nil, false, err
+		}
+		return values(found)
+	case "jsonpath":
+		path, err := jsonpath.Parse(q.Arg)
+		if err != nil {
+			return nil, false, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(res.Body))
+		decoder.UseNumber()
+		var doc interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, false, fmt.Errorf("invalid JSON body: %v", err)
+		}
+		found := path.Eval(doc)
+		switch len(found) {
+		case 0:
+			return nil, false, nil
+		case 1:
+			return found[0], true, nil
+		}
+		return found, true, nil
+	}
+	return nil, false, fmt.Errorf("unknown query `%s`", q.Kind)
+}
+
+// values returns nothing, a single string or all the strings
+func values(s []string) (interface{}, bool, error) {
+	switch len(s) {
+	case 0:
+		return nil, false, nil
+	case 1:
+		return s[0], true, nil
+	}
+	all := make([]interface{}, len(s))
+	for i, value := range s {
+		all[i] = value
+	}
+	return all, true, nil
+}
+
+// String writes a value selected by a query as the text of a variable:
+// strings as they are, numbers and booleans in their JSON form, and objects
+// and arrays as JSON
+func String(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
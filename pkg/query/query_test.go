@@ -0,0 +1,123 @@
+package query
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nug/pkg/ast"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Nugget {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("failed to parse program: %v", err)
+	}
+	return program.RootValue
+}
+
+func TestCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.Redirect(w, r, "/users/42", http.StatusFound)
+			return
+		}
+		w.Header().Set("Authorization", "Bearer abc")
+		w.Header().Add("X-Tag", "a")
+		w.Header().Add("X-Tag", "b")
+		http.SetCookie(w, &http.Cookie{Name: "SID", Value: "s3cr3t"})
+		w.Write([]byte(`{"data": [{"id": 42, "name": "nug", "admin": false}], "ratio": 0.5, "html": "code=7"}`))
+	}))
+	defer server.Close()
+
+	nugget := parse(t, `GET https://test.com/login
+HTTP 200
+[Capture]
+status: status
+token: header "Authorization"
+tags: header "X-Tag"
+session: cookie "SID"
+id: jsonpath "$.data[0].id"
+user: jsonpath "$.data[0]"
+admin: $.data[0].admin
+ratio: $.ratio
+name: jsonpath "$.data[?(@.id == 42 && @.admin == false)].name"
+numbers: $..[?(@ > 0.1)]
+code: regex "code=(\\d+)"
+location: url`)
+
+	res, err := http.Get(server.URL + "/login")
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+	response, err := NewResponse(res)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	vars := map[string]string{"host": "test.com"}
+	if err := Capture(nugget.Entries[0].Res.Capture, response, vars); err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	expected := map[string]string{
+		"host":     "test.com",
+		"status":   "200",
+		"token":    "Bearer abc",
+		"tags":     `["a","b"]`,
+		"session":  "s3cr3t",
+		"id":       "42",
+		"user":     `{"admin":false,"id":42,"name":"nug"}`,
+		"admin":    "false",
+		"ratio":    "0.5",
+		"name":     "nug",
+		"numbers":  "[0.5,42]",
+		"code":     "7",
+		"location": server.URL + "/users/42",
+	}
+	for name, value := range expected {
+		if vars[name] != value {
+			t.Fatalf("error: expected %s to be %q, got: %q", name, value, vars[name])
+		}
+	}
+	if len(vars) != len(expected) {
+		t.Fatalf("error: unexpected variables: %v", vars)
+	}
+}
+
+func TestCaptureErrors(t *testing.T) {
+	tests := [...]struct {
+		capture string
+		body    string
+		err     string
+	}{
+		{capture: "id: $.id", body: `{"name": "nug"}`, err: "line 4, column 1: nothing to capture in `id`"},
+		{capture: "id: $.id", body: `<html>`, err: "line 4, column 1: cannot capture `id`: invalid JSON body: invalid character '<' looking for beginning of value"},
+		{capture: "id: header \"X-Id\"", body: ``, err: "line 4, column 1: nothing to capture in `id`"},
+		{capture: "id: regex \"id=(\\\\d+)\"", body: `id=none`, err: "line 4, column 1: nothing to capture in `id`"},
+	}
+
+	for _, test := range tests {
+		nugget := parse(t, "GET https://test.com\nHTTP 200\n[Capture]\n"+test.capture)
+
+		err := Capture(nugget.Entries[0].Res.Capture, Response{Body: []byte(test.body)}, map[string]string{})
+		if err == nil {
+			t.Fatalf("expected error for %q", test.capture)
+		}
+		var captureErr *CaptureError
+		if !errors.As(err, &captureErr) || captureErr.Name != "id" {
+			t.Fatalf("error: expected a *CaptureError, got: %#v", err)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package resolver
+
+// The resolver replaces the `{{name}}` references of the AST with the values
+// of the variables, either given by the caller or captured by the `[Capture]`
+// section of a previous entry.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/token"
+)
+
+// UndefinedError is a reference to a variable that has no value
+type UndefinedError struct {
+	Name string
+	Pos  token.Position
+}
+
+func (e *UndefinedError) Error() string {
+	return fmt.Sprintf("line %d, column %d: undefined variable `%s`", e.Pos.Line, e.Pos.Column, e.Name)
+}
+
+// Render returns the template with each reference replaced by its value. It
+// fails on the first reference to a variable missing from vars.
+func Render(tmpl *ast.Template, vars map[string]string) (string, error) {
+	var b strings.Builder
+	for _, part := range tmpl.Parts {
+		if part.Type != "Variable" {
+			b.WriteString(part.Value)
+			continue
+		}
+		value, ok := vars[part.Value]
+		if !ok {
+			return "", &UndefinedError{Name: part.Value, Pos: part.Pos}
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+// Request returns a copy of req where the url, the header values and the
+// body are rendered with vars. The returned request holds no template anymore.
+// The body is rendered from its raw text, with the values referenced inside
+// its JSON strings escaped, Body.Value is left untouched.
+func Request(req ast.Request, vars map[string]string) (ast.Request, error) {
+	var err error
+
+	if req.Line.UrlTemplate != nil {
+		if req.Line.Url, err = Render(req.Line.UrlTemplate, vars); err != nil {
+			return ast.Request{}, err
+		}
+		req.Line.UrlTemplate = nil
+	}
+
+	if req.Header != nil {
+		headers := make([]ast.KeyValue, len(req.Header))
+		for i, header := range req.Header {
+			if header.ValueTemplate != nil {
+				if header.Value, err = Render(header.ValueTemplate, vars); err != nil {
+					return ast.Request{}, err
+				}
+				header.ValueTemplate = nil
+			}
+			headers[i] = header
+		}
+		req.Header = headers
+	}
+
+	if req.Body != nil && req.Body.Template != nil {
+		body := *req.Body
+		if body.Raw, err = Render(BodyTemplate(body.Template, vars), vars); err != nil {
+			return ast.Request{}, err
+		}
+		body.Template = nil
+		req.Body = &body
+	}
+
+	return req, nil
+}
+
+// BodyTemplate returns a copy of the template of a JSON body with the
+// references to the variables of vars replaced by their value. A value
+// referenced inside a JSON string is escaped, so a quote or a newline keeps the
+// body valid. The other references are left in the template.
+func BodyTemplate(tmpl *ast.Template, vars map[string]string) *ast.Template {
+	out := &ast.Template{Type: tmpl.Type}
+	inString, escaped := false, false
+	for _, part := range tmpl.Parts {
+		if part.Type != "Variable" {
+			for i := 0; i < len(part.Value); i++ {
+				switch c := part.Value[i]; {
+				case escaped:
+					escaped = false
+				case inString && c == '\\':
+					escaped = true
+				case c == '"':
+					inString = !inString
+				}
+			}
+			out.Parts = append(out.Parts, part)
+			continue
+		}
+
+		value, ok := vars[part.Value]
+		if !ok {
+			out.Parts = append(out.Parts, part)
+			continue
+		}
+		if inString {
+			value = escapeJSON(value)
+		}
+		out.Parts = append(out.Parts, ast.TemplatePart{Type: "Text", Value: value, Pos: part.Pos})
+	}
+	return out
+}
+
+// escapeJSON returns s escaped as the content of a JSON string
+func escapeJSON(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	quoted := strings.TrimSuffix(b.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
+
+// Define gives each variable defined by a `@name = value` line of the .http
+// dialect its value, rendered with the variables defined before it. The
+// variables already in vars, given by the caller, keep their value.
+func Define(variables []ast.KeyValue, vars map[string]string) error {
+	for _, v := range variables {
+		if _, ok := vars[v.Key]; ok {
+			continue
+		}
+		value := v.Value
+		if v.ValueTemplate != nil {
+			var err error
+			if value, err = Render(v.ValueTemplate, vars); err != nil {
+				return err
+			}
+		}
+		vars[v.Key] = value
+	}
+	return nil
+}
+
+// Check returns an error for each reference to a variable that is neither in
+// vars, defined by the nugget, nor captured by an entry before the one using
+// it. It lets a nugget be validated before any request is sent.
+func Check(nugget *ast.Nugget, vars map[string]string) []*UndefinedError {
+	var errs []*UndefinedError
+
+	defined := make(map[string]bool, len(vars))
+	for name := range vars {
+		defined[name] = true
+	}
+
+	check := func(tmpl *ast.Template) {
+		if tmpl == nil {
+			return
+		}
+		for _, part := range tmpl.Parts {
+			if part.Type == "Variable" && !defined[part.Value] {
+				errs = append(errs, &UndefinedError{Name: part.Value, Pos: part.Pos})
+			}
+		}
+	}
+
+	for _, v := range nugget.Variables {
+		check(v.ValueTemplate)
+		defined[v.Key] = true
+	}
+
+	for _, entry := range nugget.Entries {
+		check(entry.Req.Line.UrlTemplate)
+		for _, header := range entry.Req.Header {
+			check(header.ValueTemplate)
+		}
+		if entry.Req.Body != nil {
+			check(entry.Req.Body.Template)
+		}
+
+		for _, capture := range entry.Res.Capture {
+			defined[capture.Name] = true
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,152 @@
+package resolver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"nug/pkg/ast"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+	"nug/pkg/token"
+)
+
+func parse(t *testing.T, input string) *ast.Nugget {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("failed to parse program: %v", err)
+	}
+	return program.RootValue
+}
+
+func TestRequest(t *testing.T) {
+	nugget := parse(t, `POST {{host}}/users/{{ id }}?v=1
+Authorization: {{token}}
+{"name": "{{name}}", "age": {{age}}}
+HTTP 200`)
+
+	vars := map[string]string{
+		"host":  "https://test.com",
+		"id":    "42",
+		"token": "secret",
+		"name":  "nugget",
+		"age":   "3",
+	}
+
+	req, err := Request(nugget.Entries[0].Req, vars)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	if req.Line.Url != "https://test.com/users/42?v=1" {
+		t.Fatalf("error: expected url to be rendered, got: %s", req.Line.Url)
+	}
+	if req.Header[0].Value != "secret" {
+		t.Fatalf("error: expected header to be rendered, got: %s", req.Header[0].Value)
+	}
+	if req.Body.Raw != `{"name": "nugget", "age": 3}` {
+		t.Fatalf("error: expected body to be rendered, got: %s", req.Body.Raw)
+	}
+	if req.Line.UrlTemplate != nil || req.Header[0].ValueTemplate != nil || req.Body.Template != nil {
+		t.Fatalf("error: expected no template left, got: %+v", req)
+	}
+
+	// the original request is not modified
+	original := nugget.Entries[0].Req
+	if original.Line.Url != "{{host}}/users/{{ id }}?v=1" || original.Header[0].Value != "{{token}}" {
+		t.Fatalf("error: expected the original request to be untouched, got: %+v", original)
+	}
+}
+
+func TestRequestJSONString(t *testing.T) {
+	nugget := parse(t, `POST https://test.com
+{"name": "{{name}} \"{{nick}}\"", "tags": [{{tags}}], "path": "a\\{{dir}}"}`)
+
+	vars := map[string]string{
+		"name": `say "hi"`,
+		"nick": "a\\b\nc",
+		"tags": `"a", "b"`,
+		"dir":  "<b>",
+	}
+	req, err := Request(nugget.Entries[0].Req, vars)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+	expected := `{"name": "say \"hi\" \"a\\b\nc\"", "tags": ["a", "b"], "path": "a\\<b>"}`
+	if req.Body.Raw != expected {
+		t.Fatalf("error: expected %s, got: %s", expected, req.Body.Raw)
+	}
+	if !json.Valid([]byte(req.Body.Raw)) {
+		t.Fatalf("error: expected a valid JSON body, got: %s", req.Body.Raw)
+	}
+}
+
+func TestRequestUndefined(t *testing.T) {
+	nugget := parse(t, `GET https://test.com/{{id}}`)
+
+	_, err := Request(nugget.Entries[0].Req, map[string]string{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if err.Error() != "line 1, column 22: undefined variable `id`" {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	nugget := parse(t, `POST https://test.com/login
+{"user": "{{user}}", "password": "{{password}}"}
+HTTP 200
+[Capture]
+token: $.token
+
+GET https://test.com/users/{{id}}
+Authorization: Bearer{{token}}
+HTTP 200`)
+
+	errs := Check(nugget, map[string]string{"user": "nugget"})
+
+	expected := []UndefinedError{
+		{Name: "password", Pos: token.Position{Line: 2, Column: 35, Offset: 62}},
+		{Name: "id", Pos: token.Position{Line: 7, Column: 28, Offset: 139}},
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("error: expected %d errors, got: %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if *err != expected[i] {
+			t.Fatalf("errors[%d] - expected %+v, got: %+v", i, expected[i], *err)
+		}
+	}
+}
+
+func TestDefine(t *testing.T) {
+	root, err := parser.New(lexer.New("@host = test.com\n@url = https://{{host}}/{{version}}\n@user = bob\nGET {{url}}", lexer.HTTPFile())).ParseProgram()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	nugget := root.RootValue
+
+	vars := map[string]string{"version": "v1", "user": "alice"}
+	if err := Define(nugget.Variables, vars); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if vars["url"] != "https://test.com/v1" || vars["user"] != "alice" {
+		t.Fatalf("error: unexpected variables %v", vars)
+	}
+
+	err = Define(nugget.Variables, map[string]string{})
+	if err == nil || err.Error() != "line 2, column 25: undefined variable `version`" {
+		t.Fatalf("error: unexpected error %v", err)
+	}
+
+	errs := Check(nugget, map[string]string{})
+	if len(errs) != 1 || errs[0].Name != "version" {
+		t.Fatalf("error: unexpected errors %v", errs)
+	}
+}
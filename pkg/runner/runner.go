@@ -0,0 +1,203 @@
+package runner
+
+// The runner sends the requests of a nugget to the servers, one entry after
+// the other, and records what they answered.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+
+	"nug/pkg/ast"
+	"nug/pkg/query"
+	"nug/pkg/resolver"
+)
+
+// Result is what the server answered to the request of an entry
+type Result struct {
+	Entry    ast.Entry
+	Request  *http.Request // the request as sent, with its variables rendered
+	Status   int
+	Proto    string // e.g. "HTTP/1.1"
+	Header   http.Header
+	Body     []byte
+	URL      *url.URL      // url of the response, after the redirects
+	Start    time.Time     // when the request was sent
+	Wait     time.Duration // from sending the request to reading the first byte of the response, 0 when the transport does not report it
+	Duration time.Duration // from sending the request to reading the whole body
+}
+
+// Response returns the part of the result read by the queries of the asserts
+// and the captures
+func (r Result) Response() query.Response {
+	return query.Response{
+		Status:   r.Status,
+		Header:   r.Header,
+		Body:     r.Body,
+		URL:      r.URL,
+		Duration: r.Duration,
+	}
+}
+
+// EntryError is an error running the entry at index Index of the nugget, Line
+// is the line of its request
+type EntryError struct {
+	Index int
+	Line  int
+	Err   error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("entry %d, line %d: %v", e.Index+1, e.Line, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+type Runner struct {
+	client *http.Client
+	vars   map[string]string
+}
+
+// Option configures a Runner
+type Option func(*Runner)
+
+// Client makes the Runner send its requests with c instead of
+// http.DefaultClient
+func Client(c *http.Client) Option {
+	return func(r *Runner) {
+		r.client = c
+	}
+}
+
+// Variables gives the values of the `{{name}}` references of the nugget, on
+// top of the ones captured while running it
+func Variables(vars map[string]string) Option {
+	return func(r *Runner) {
+		for name, value := range vars {
+			r.vars[name] = value
+		}
+	}
+}
+
+// New creates a Runner, by default it uses http.DefaultClient and knows no
+// variables
+func New(opts ...Option) *Runner {
+	r := &Runner{client: http.DefaultClient, vars: map[string]string{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run sends the request of each entry of root in order, the values captured
+// by an entry are available to the next ones. It stops at the first entry
+// that fails, and returns the results of the entries run so far along with an
+// *EntryError. The variables defined by root come first, an undefined one in
+// their values is returned as a *resolver.UndefinedError.
+func (r *Runner) Run(ctx context.Context, root ast.RootNode) ([]Result, error) {
+	if root.RootValue == nil {
+		return nil, nil
+	}
+	if err := resolver.Define(root.RootValue.Variables, r.vars); err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for i, entry := range root.RootValue.Entries {
+		result, err := r.RunEntry(ctx, entry)
+		if err != nil {
+			return results, &EntryError{Index: i, Line: entry.Req.StartPos.Line, Err: err}
+		}
+		results = append(results, result)
+
+		if err := query.Capture(entry.Res.Capture, result.Response(), r.vars); err != nil {
+			return results, &EntryError{Index: i, Line: entry.Req.StartPos.Line, Err: err}
+		}
+	}
+	return results, nil
+}
+
+// RunEntry sends the request of a single entry and reads the whole response
+func (r *Runner) RunEntry(ctx context.Context, entry ast.Entry) (Result, error) {
+	req, err := NewRequest(ctx, entry.Req, r.vars)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var firstByte time.Time
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}))
+
+	start := time.Now()
+	res, err := r.client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+
+	response, err := query.NewResponse(res)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// a transport other than http.Transport may not report the first byte
+	var wait time.Duration
+	if !firstByte.IsZero() {
+		wait = firstByte.Sub(start)
+	}
+
+	return Result{
+		Entry:    entry,
+		Request:  req,
+		Status:   response.Status,
+		Proto:    res.Proto,
+		Header:   response.Header,
+		Body:     response.Body,
+		URL:      response.URL,
+		Start:    start,
+		Wait:     wait,
+		Duration: time.Since(start),
+	}, nil
+}
+
+// NewRequest builds the *http.Request of req, its `{{name}}` references are
+// rendered with vars. The body is sent as written in the nugget, with a JSON
+// content type unless the request sets its own.
+func NewRequest(ctx context.Context, req ast.Request, vars map[string]string) (*http.Request, error) {
+	req, err := resolver.Request(req, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if req.Body != nil {
+		body = bytes.NewBufferString(req.Body.Raw)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Line.Method, req.Line.Url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, header := range req.Header {
+		if strings.EqualFold(header.Key, "Host") {
+			httpReq.Host = header.Value
+			continue
+		}
+		httpReq.Header.Add(header.Key, header.Value)
+	}
+
+	if req.Body != nil && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	return httpReq, nil
+}
@@ -0,0 +1,236 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nug/pkg/ast"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+	"nug/pkg/resolver"
+)
+
+func parse(t *testing.T, input string) ast.RootNode {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("failed to parse program: %v", err)
+	}
+	return program
+}
+
+// echo answers with the method, the path, the X-Id header and the body of the
+// request it got
+func echo(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("X-Method", r.Method)
+	w.Header().Set("X-Path", r.URL.Path)
+	w.Header().Set("X-Id", r.Header.Get("X-Id"))
+	w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	w.Write(body)
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echo))
+	defer server.Close()
+
+	program := parse(t, `GET {{host}}/users/1
+X-Id: {{id}}
+HTTP 200
+
+post {{host}}/users
+{"name": "{{name}}"}
+HTTP 201`)
+
+	r := New(
+		Client(server.Client()),
+		Variables(map[string]string{"host": server.URL, "id": "42", "name": "nugget"}),
+	)
+
+	results, err := r.Run(context.Background(), program)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("error: expected 2 results, got: %d", len(results))
+	}
+
+	tests := []struct {
+		status      int
+		method      string
+		path        string
+		id          string
+		contentType string
+		body        string
+	}{
+		{200, "GET", "/users/1", "42", "", ""},
+		{201, "POST", "/users", "", "application/json", `{"name": "nugget"}`},
+	}
+
+	for i, tt := range tests {
+		result := results[i]
+		if result.Status != tt.status || result.Proto != "HTTP/1.1" {
+			t.Fatalf("results[%d] - expected %d HTTP/1.1, got: %d %s", i, tt.status, result.Status, result.Proto)
+		}
+		if result.Header.Get("X-Method") != tt.method || result.Header.Get("X-Path") != tt.path {
+			t.Fatalf("results[%d] - expected %s %s, got: %v", i, tt.method, tt.path, result.Header)
+		}
+		if result.Header.Get("X-Id") != tt.id || result.Header.Get("X-Content-Type") != tt.contentType {
+			t.Fatalf("results[%d] - unexpected headers: %v", i, result.Header)
+		}
+		if string(result.Body) != tt.body {
+			t.Fatalf("results[%d] - expected body %q, got: %q", i, tt.body, result.Body)
+		}
+		if result.Duration <= 0 {
+			t.Fatalf("results[%d] - expected a duration, got: %v", i, result.Duration)
+		}
+		if result.Start.IsZero() || result.Wait <= 0 || result.Wait > result.Duration {
+			t.Fatalf("results[%d] - unexpected timings: %v %v %v", i, result.Start, result.Wait, result.Duration)
+		}
+		if result.Entry.Req.Line.Url != program.RootValue.Entries[i].Req.Line.Url {
+			t.Fatalf("results[%d] - unexpected entry: %+v", i, result.Entry)
+		}
+	}
+}
+
+func TestRunStopsAtFirstError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echo))
+	defer server.Close()
+
+	program := parse(t, `GET {{host}}/a
+HTTP 200
+
+GET {{host}}/b
+Authorization: {{token}}
+HTTP 200
+
+GET {{host}}/c
+HTTP 200`)
+
+	r := New(Client(server.Client()), Variables(map[string]string{"host": server.URL}))
+
+	results, err := r.Run(context.Background(), program)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(results) != 1 {
+		t.Fatalf("error: expected the result of the first entry, got: %d results", len(results))
+	}
+
+	var entryErr *EntryError
+	if !errors.As(err, &entryErr) || entryErr.Index != 1 || entryErr.Line != 4 {
+		t.Fatalf("error: expected an *EntryError for the second entry, got: %#v", err)
+	}
+	var undefined *resolver.UndefinedError
+	if !errors.As(err, &undefined) || undefined.Name != "token" {
+		t.Fatalf("error: expected an undefined variable, got: %v", err)
+	}
+	if err.Error() != "entry 2, line 4: line 5, column 16: undefined variable `token`" {
+		t.Fatalf("error: unexpected message: %q", err.Error())
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRunEntryWithoutFirstByte(t *testing.T) {
+	program := parse(t, `GET http://test.com/a
+HTTP 200`)
+
+	// the transport answers without going through the network, so the first
+	// byte of the response is never reported
+	client := &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Proto: "HTTP/1.1", Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})}
+
+	result, err := New(Client(client)).RunEntry(context.Background(), program.RootValue.Entries[0])
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+	if result.Wait != 0 || result.Duration < 0 {
+		t.Fatalf("error: expected no wait, got: %v %v", result.Wait, result.Duration)
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	program := parse(t, `PUT https://test.com/users/1
+Host: api.test.com
+Content-Type: application/merge-patch+json
+Accept: text/plain
+Accept: application/json
+{"name": "nugget"}`)
+
+	req, err := NewRequest(context.Background(), program.RootValue.Entries[0].Req, nil)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	if req.Method != "PUT" || req.URL.String() != "https://test.com/users/1" || req.Host != "api.test.com" {
+		t.Fatalf("error: unexpected request line: %s %s (host %s)", req.Method, req.URL, req.Host)
+	}
+	if req.Header.Get("Content-Type") != "application/merge-patch+json" {
+		t.Fatalf("error: expected the content type of the nugget, got: %s", req.Header.Get("Content-Type"))
+	}
+	if accept := req.Header.Values("Accept"); len(accept) != 2 || accept[1] != "application/json" {
+		t.Fatalf("error: expected both Accept headers, got: %v", accept)
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"name": "nugget"}` {
+		t.Fatalf("error: expected the body to be sent verbatim, got: %s", body)
+	}
+}
+
+func TestRunCaptures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Write([]byte(`{"token": "abc", "user": {"id": 42}}`))
+		case "/users/42":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	program := parse(t, `POST {{host}}/login
+HTTP 200
+[Capture]
+token: jsonpath "$.token"
+id: $.user.id
+
+GET {{host}}/users/{{id}}
+Authorization: Bearer {{token}}
+HTTP 200`)
+
+	r := New(Client(server.Client()), Variables(map[string]string{"host": server.URL}))
+
+	results, err := r.Run(context.Background(), program)
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+	if len(results) != 2 || results[1].Status != http.StatusOK {
+		t.Fatalf("error: expected the second request to use the captured values, got: %+v", results)
+	}
+	if results[1].URL.Path != "/users/42" {
+		t.Fatalf("error: unexpected url: %s", results[1].URL)
+	}
+}
@@ -1,6 +1,9 @@
 package token
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Type alias for a string
 type Type string
@@ -25,32 +28,113 @@ const (
 	Comment Type = "COMMENT"
 
 	// Methods
-	Post Type = "POST"
-	Get  Type = "GET"
+	Post    Type = "POST"
+	Get     Type = "GET"
+	Put     Type = "PUT"
+	Patch   Type = "PATCH"
+	Delete  Type = "DELETE"
+	Head    Type = "HEAD"
+	Options Type = "OPTIONS"
+	Trace   Type = "TRACE"
+	Connect Type = "CONNECT"
 
 	// Response
 	Http    Type = "HTTP"
 	Capture Type = "CAPTURE"
+	Asserts Type = "ASSERTS"
+
+	// JSON structural tokens, only emitted while lexing a request body
+	LeftBrace    Type = "{"
+	RightBrace   Type = "}"
+	LeftBracket  Type = "["
+	RightBracket Type = "]"
+	Colon        Type = ":"
+	Comma        Type = ","
+
+	// A bare `{{name}}` reference used as a JSON value
+	Variable Type = "VARIABLE"
+
+	// A `@name = value` line of the .http dialect, the whole line is its literal
+	Definition Type = "DEFINITION"
+
+	// JSON keywords
+	True  Type = "TRUE"
+	False Type = "FALSE"
+	Null  Type = "NULL"
 )
 
+// Position is a location in the input. Line and Column are 1-based, Column
+// counts runes, and Offset is the UTF-8 byte offset from the start of the input.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
 type Token struct {
-	Type    Type
-	Literal string
-	Line    int
-	Start   int
-	End     int
+	Type     Type
+	Literal  string
+	Line     int // 0-based line, kept for compatibility: prefer StartPos.Line
+	Start    int // rune index of the first char of the token
+	End      int // rune index after the last char of the token
+	StartPos Position
+	EndPos   Position // position right after the last char of the token
 }
 
 var validKeywords = map[string]Type{
-	"POST":      Post,
-	"GET":       Get,
 	"HTTP":      Http,
+	"HTTP/1.0":  Http,
+	"HTTP/1.1":  Http,
+	"HTTP/2":    Http,
+	"HTTP/3":    Http,
 	"[Capture]": Capture,
+	"[Asserts]": Asserts,
 }
 
+var methods = map[string]Type{
+	"POST":    Post,
+	"GET":     Get,
+	"PUT":     Put,
+	"PATCH":   Patch,
+	"DELETE":  Delete,
+	"HEAD":    Head,
+	"OPTIONS": Options,
+	"TRACE":   Trace,
+	"CONNECT": Connect,
+}
+
+// Methods returns the token types of all the HTTP methods
+func Methods() []Type {
+	return []Type{Get, Post, Put, Patch, Delete, Head, Options, Trace, Connect}
+}
+
+// IsMethod reports whether the token type is one of the HTTP methods
+func IsMethod(t Type) bool {
+	_, ok := methods[string(t)]
+	return ok
+}
+
+var jsonKeywords = map[string]Type{
+	"true":  True,
+	"false": False,
+	"null":  Null,
+}
+
+// LookupJSONKeyword returns the token type of a JSON literal name (true, false
+// or null), and false if the identifier is not one of them.
+func LookupJSONKeyword(identifier string) (Type, bool) {
+	t, ok := jsonKeywords[identifier]
+	return t, ok
+}
+
+// LookupMethod returns the token type of a keyword. HTTP methods are case
+// insensitive, so `get` and `Get` are both a Get token.
 func LookupMethod(identifier string) (Type, error) {
 	if token, ok := validKeywords[identifier]; ok {
 		return token, nil
 	}
+	if token, ok := methods[strings.ToUpper(identifier)]; ok {
+		return token, nil
+	}
 	return "", fmt.Errorf("error: expected a valid method, found: %s", identifier)
 }
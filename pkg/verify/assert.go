@@ -0,0 +1,175 @@
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/query"
+	"nug/pkg/runner"
+)
+
+// Assert evaluates an assert of the `[Asserts]` section against the response
+func Assert(assert ast.Assert, result runner.Result) Result {
+	check := Result{
+		Type:     "Assert",
+		Line:     assert.StartPos.Line,
+		Column:   assert.StartPos.Column,
+		Expected: describe(assert),
+	}
+
+	value, found, err := query.Eval(assert.Query, result.Response())
+	if err != nil {
+		check.Actual = err.Error()
+		return check
+	}
+
+	check.Actual = format(value, found)
+	check.Pass = satisfies(assert.Predicate, value, found)
+	return check
+}
+
+// satisfies reports whether the value selected by a query satisfies the
+// predicate. Only exists and != are satisfied by a missing value.
+func satisfies(predicate ast.Predicate, value interface{}, found bool) bool {
+	var expected interface{}
+	if predicate.Value != nil {
+		expected = predicate.Value.Value
+	}
+
+	switch predicate.Op {
+	case "exists":
+		return found
+	case "!=":
+		return !found || !equal(value, expected)
+	}
+	if !found {
+		return false
+	}
+
+	switch predicate.Op {
+	case "==":
+		return equal(value, expected)
+	case ">", ">=", "<", "<=":
+		c, ok := compare(value, expected)
+		if !ok {
+			return false
+		}
+		switch predicate.Op {
+		case ">":
+			return c > 0
+		case ">=":
+			return c >= 0
+		case "<":
+			return c < 0
+		default:
+			return c <= 0
+		}
+	case "contains":
+		if s, ok := value.(string); ok {
+			sub, ok := expected.(string)
+			return ok && strings.Contains(s, sub)
+		}
+		if values, ok := value.([]interface{}); ok {
+			for _, v := range values {
+				if equal(v, expected) {
+					return true
+				}
+			}
+		}
+		return false
+	case "startsWith":
+		s, ok := value.(string)
+		prefix, _ := expected.(string)
+		return ok && strings.HasPrefix(s, prefix)
+	case "matches":
+		s, ok := value.(string)
+		pattern, _ := expected.(string)
+		re, err := regexp.Compile(pattern)
+		return ok && err == nil && re.MatchString(s)
+	case "isInteger":
+		if n, ok := value.(json.Number); ok {
+			return !strings.ContainsAny(n.String(), ".eE")
+		}
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	}
+	return false
+}
+
+// number returns v as a float64 when it is a number
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equal compares two values, numbers by their value whatever their type
+func equal(a, b interface{}) bool {
+	x, ok := number(a)
+	y, isNumber := number(b)
+	if ok || isNumber {
+		return ok && isNumber && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two numbers or two strings, it returns false when the values
+// can't be ordered
+func compare(a, b interface{}) (int, bool) {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	x, ok := a.(string)
+	y, isString := b.(string)
+	if !ok || !isString {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+// describe writes the assert back the way it is written in the nugget
+func describe(assert ast.Assert) string {
+	s := assert.Query.Kind
+	if assert.Query.Arg != "" {
+		s += " " + strconv.Quote(assert.Query.Arg)
+	}
+	s += " " + assert.Predicate.Op
+	if assert.Predicate.Value != nil {
+		s += " " + format(assert.Predicate.Value.Value, true)
+	}
+	return s
+}
+
+// format writes a value as JSON, strings are quoted
+func format(value interface{}, found bool) string {
+	if !found {
+		return "nothing"
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
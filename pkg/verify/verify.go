@@ -0,0 +1,128 @@
+package verify
+
+// The verify package checks the responses recorded by the runner against what
+// the entries of the nugget expect.
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"nug/pkg/ast"
+	"nug/pkg/runner"
+)
+
+// Result is the outcome of a single check of a response
+type Result struct {
+	Type     string // "Status", "Version" or "Assert"
+	Line     int    // position of the checked line in the nugget
+	Column   int
+	Expected string
+	Actual   string
+	Pass     bool
+}
+
+func (r Result) String() string {
+	if r.Type == "Assert" {
+		if r.Pass {
+			return fmt.Sprintf("line %d, column %d: assert `%s` passed", r.Line, r.Column, r.Expected)
+		}
+		return fmt.Sprintf("line %d, column %d: assert `%s` failed, got: `%s`", r.Line, r.Column, r.Expected, r.Actual)
+	}
+	if r.Pass {
+		return fmt.Sprintf("line %d, column %d: %s `%s` passed", r.Line, r.Column, strings.ToLower(r.Type), r.Actual)
+	}
+	return fmt.Sprintf("line %d, column %d: expected %s `%s`, got: `%s`",
+		r.Line, r.Column, strings.ToLower(r.Type), r.Expected, r.Actual)
+}
+
+// Entry checks the response of an entry against its `HTTP` line, the version
+// first and then the status, and then against each of its asserts. An entry
+// without response has nothing to check.
+func Entry(result runner.Result) []Result {
+	res := result.Entry.Res
+	if res.Version == "" {
+		return nil
+	}
+
+	expectedStatus := strconv.Itoa(res.Status)
+	if res.StatusPattern != "" {
+		expectedStatus = res.StatusPattern
+	}
+
+	checks := []Result{
+		{
+			Type:     "Version",
+			Line:     res.StartPos.Line,
+			Column:   res.StartPos.Column,
+			Expected: res.Version,
+			Actual:   result.Proto,
+			Pass:     MatchVersion(res.Version, result.Proto),
+		},
+		{
+			Type:     "Status",
+			Line:     res.StartPos.Line,
+			Column:   res.StartPos.Column,
+			Expected: expectedStatus,
+			Actual:   strconv.Itoa(result.Status),
+			Pass:     MatchStatus(res, result.Status),
+		},
+	}
+	for _, assert := range res.Asserts {
+		checks = append(checks, Assert(assert, result))
+	}
+	return checks
+}
+
+// Results checks the response of each entry run, in order
+func Results(results []runner.Result) []Result {
+	var checks []Result
+	for _, result := range results {
+		checks = append(checks, Entry(result)...)
+	}
+	return checks
+}
+
+// Failures returns the checks that did not pass
+func Failures(checks []Result) []Result {
+	var failures []Result
+	for _, check := range checks {
+		if !check.Pass {
+			failures = append(failures, check)
+		}
+	}
+	return failures
+}
+
+// MatchStatus reports whether status is the one expected by res, either the
+// exact code or a code matching its wildcard (`2xx`, `*`)
+func MatchStatus(res ast.Response, status int) bool {
+	switch {
+	case res.StatusPattern == "*":
+		return true
+	case res.StatusPattern != "":
+		return strconv.Itoa(status/100) == res.StatusPattern[:1] && status < 1000
+	default:
+		return res.Status == status
+	}
+}
+
+// MatchVersion reports whether the protocol of a response (e.g. "HTTP/2.0"
+// for net/http) is the version expected by the nugget. `HTTP` matches any
+// version and `HTTP/2` matches "HTTP/2.0".
+func MatchVersion(version, proto string) bool {
+	if version == "HTTP" {
+		return true
+	}
+	if !strings.Contains(version, ".") {
+		version += ".0"
+	}
+
+	expectedMajor, expectedMinor, ok := http.ParseHTTPVersion(version)
+	if !ok {
+		return false
+	}
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	return ok && major == expectedMajor && minor == expectedMinor
+}
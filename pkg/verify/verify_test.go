@@ -0,0 +1,170 @@
+package verify
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"nug/pkg/ast"
+	"nug/pkg/lexer"
+	"nug/pkg/parser"
+	"nug/pkg/runner"
+)
+
+func parse(t *testing.T, input string) *ast.Nugget {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("failed to parse program: %v", err)
+	}
+	return program.RootValue
+}
+
+func TestEntry(t *testing.T) {
+	nugget := parse(t, `GET https://test.com/a
+HTTP 200
+
+GET https://test.com/b
+HTTP/2 2xx
+
+GET https://test.com/c
+HTTP/1.1 *
+
+GET https://test.com/d`)
+
+	results := []runner.Result{
+		{Entry: nugget.Entries[0], Status: 404, Proto: "HTTP/1.1"},
+		{Entry: nugget.Entries[1], Status: 204, Proto: "HTTP/1.1"},
+		{Entry: nugget.Entries[2], Status: 500, Proto: "HTTP/1.1"},
+		{Entry: nugget.Entries[3], Status: 200, Proto: "HTTP/1.1"},
+	}
+
+	expected := []Result{
+		{Type: "Version", Line: 2, Column: 1, Expected: "HTTP", Actual: "HTTP/1.1", Pass: true},
+		{Type: "Status", Line: 2, Column: 1, Expected: "200", Actual: "404", Pass: false},
+		{Type: "Version", Line: 5, Column: 1, Expected: "HTTP/2", Actual: "HTTP/1.1", Pass: false},
+		{Type: "Status", Line: 5, Column: 1, Expected: "2xx", Actual: "204", Pass: true},
+		{Type: "Version", Line: 8, Column: 1, Expected: "HTTP/1.1", Actual: "HTTP/1.1", Pass: true},
+		{Type: "Status", Line: 8, Column: 1, Expected: "*", Actual: "500", Pass: true},
+	}
+
+	checks := Results(results)
+	if !reflect.DeepEqual(checks, expected) {
+		t.Fatalf("error: unexpected checks\nexpected: %+v\ngot: %+v", expected, checks)
+	}
+
+	failures := Failures(checks)
+	messages := []string{
+		"line 2, column 1: expected status `200`, got: `404`",
+		"line 5, column 1: expected version `HTTP/2`, got: `HTTP/1.1`",
+	}
+	if len(failures) != len(messages) {
+		t.Fatalf("error: expected %d failures, got: %v", len(messages), failures)
+	}
+	for i, failure := range failures {
+		if failure.String() != messages[i] {
+			t.Fatalf("failures[%d] - expected %q, got: %q", i, messages[i], failure.String())
+		}
+	}
+}
+
+func TestMatchStatus(t *testing.T) {
+	tests := [...]struct {
+		res    ast.Response
+		status int
+		match  bool
+	}{
+		{ast.Response{Status: 200}, 200, true},
+		{ast.Response{Status: 200}, 201, false},
+		{ast.Response{StatusPattern: "2xx"}, 200, true},
+		{ast.Response{StatusPattern: "2xx"}, 299, true},
+		{ast.Response{StatusPattern: "2xx"}, 300, false},
+		{ast.Response{StatusPattern: "4xx"}, 404, true},
+		{ast.Response{StatusPattern: "*"}, 503, true},
+	}
+
+	for _, test := range tests {
+		if MatchStatus(test.res, test.status) != test.match {
+			t.Fatalf("error: expected MatchStatus(%+v, %d) to be %v", test.res, test.status, test.match)
+		}
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	tests := [...]struct {
+		version string
+		proto   string
+		match   bool
+	}{
+		{"HTTP", "HTTP/1.1", true},
+		{"HTTP", "HTTP/2.0", true},
+		{"HTTP/1.1", "HTTP/1.1", true},
+		{"HTTP/1.1", "HTTP/1.0", false},
+		{"HTTP/2", "HTTP/2.0", true},
+		{"HTTP/2", "HTTP/1.1", false},
+		{"HTTP/3", "HTTP/3.0", true},
+	}
+
+	for _, test := range tests {
+		if MatchVersion(test.version, test.proto) != test.match {
+			t.Fatalf("error: expected MatchVersion(%q, %q) to be %v", test.version, test.proto, test.match)
+		}
+	}
+}
+
+func TestAsserts(t *testing.T) {
+	nugget := parse(t, `GET https://test.com/users
+HTTP 200
+[Asserts]
+status < 300
+header "Content-Type" startsWith "application/json"
+header "X-Missing" exists
+jsonpath "$.data[0].id" == 1
+jsonpath "$.data[*].name" contains "b"
+jsonpath "$.data[0].name" matches "^[a-z]+$"
+jsonpath "$.total" isInteger
+jsonpath "$.ratio" isInteger
+jsonpath "$.missing" != 1
+jsonpath "$.ratio" == 5e-1
+jsonpath "$.total" < 1.5e3
+body contains "\"total\""
+duration >= 0`)
+
+	result := runner.Result{
+		Entry:  nugget.Entries[0],
+		Status: 200,
+		Proto:  "HTTP/1.1",
+		Header: http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:   []byte(`{"data": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}], "total": 2, "ratio": 0.5}`),
+	}
+
+	failures := Failures(Entry(result))
+	messages := []string{
+		"line 6, column 1: assert `header \"X-Missing\" exists` failed, got: `nothing`",
+		"line 11, column 1: assert `jsonpath \"$.ratio\" isInteger` failed, got: `0.5`",
+	}
+	if len(failures) != len(messages) {
+		t.Fatalf("error: expected %d failures, got: %v", len(messages), failures)
+	}
+	for i, failure := range failures {
+		if failure.String() != messages[i] {
+			t.Fatalf("failures[%d] - expected %q, got: %q", i, messages[i], failure.String())
+		}
+	}
+}
+
+func TestAssertInvalidBody(t *testing.T) {
+	nugget := parse(t, `GET https://test.com
+HTTP 200
+[Asserts]
+jsonpath "$.id" exists`)
+
+	check := Assert(nugget.Entries[0].Res.Asserts[0], runner.Result{Body: []byte("<html>")})
+	if check.Pass || check.Actual != "invalid JSON body: invalid character '<' looking for beginning of value" {
+		t.Fatalf("error: expected the assert to fail on the body, got: %+v", check)
+	}
+}
@@ -0,0 +1,196 @@
+package xpath
+
+// The xpath package selects the elements and attributes of an XML or HTML
+// document with a subset of XPath: `/` and `//` steps, element names or `*`,
+// 1-based `[n]` positions, and a last `@name` or `text()` step.
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Path is a parsed XPath expression
+type Path struct {
+	Source string
+	steps  []step
+	attr   string // name of the attribute selected by the last step, if any
+	text   bool   // the last step is text()
+}
+
+// step selects the children, or the descendants, of the current nodes with
+// the given name, `*` for any name. A position selects the nth of them among
+// the children of each parent, 0 selects all of them.
+type step struct {
+	descendant bool
+	name       string
+	position   int
+}
+
+type node struct {
+	name     string
+	attrs    map[string]string
+	children []*node
+	text     strings.Builder // text of the node and its descendants
+}
+
+// Parse parses an absolute XPath expression such as `//ul/li[2]/@class`
+func Parse(expr string) (*Path, error) {
+	p := &Path{Source: expr}
+	s := expr
+
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("invalid XPath, expected `/`: `%s`", expr)
+	}
+
+	for s != "" {
+		if p.attr != "" || p.text {
+			return nil, fmt.Errorf("invalid XPath, `@` and `text()` must be the last step: `%s`", expr)
+		}
+
+		st := step{}
+		if strings.HasPrefix(s, "//") {
+			st.descendant = true
+			s = s[2:]
+		} else if strings.HasPrefix(s, "/") {
+			s = s[1:]
+		}
+
+		end := strings.Index(s, "/")
+		if end < 0 {
+			end = len(s)
+		}
+		segment := s[:end]
+		s = s[end:]
+
+		switch {
+		case segment == "text()":
+			p.text = true
+			continue
+		case strings.HasPrefix(segment, "@") && len(segment) > 1:
+			p.attr = segment[1:]
+			if st.descendant {
+				p.steps = append(p.steps, step{descendant: true, name: "*"})
+			}
+			continue
+		}
+
+		if open := strings.Index(segment, "["); open >= 0 {
+			if !strings.HasSuffix(segment, "]") {
+				return nil, fmt.Errorf("invalid XPath, expected `]`: `%s`", expr)
+			}
+			position, err := strconv.Atoi(segment[open+1 : len(segment)-1])
+			if err != nil || position < 1 {
+				return nil, fmt.Errorf("invalid XPath, invalid position `%s`: `%s`", segment[open:], expr)
+			}
+			st.position = position
+			segment = segment[:open]
+		}
+
+		if segment == "" {
+			return nil, fmt.Errorf("invalid XPath, expected a name: `%s`", expr)
+		}
+		st.name = segment
+		p.steps = append(p.steps, st)
+	}
+
+	return p, nil
+}
+
+// Eval returns the text of the elements, or the values of the attributes,
+// selected by the path in doc. HTML documents are read leniently.
+func (p *Path) Eval(doc []byte) ([]string, error) {
+	root, err := parseDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*node{root}
+	for _, st := range p.steps {
+		var next []*node
+		for _, n := range nodes {
+			next = append(next, st.eval(n)...)
+		}
+		nodes = next
+	}
+
+	var values []string
+	for _, n := range nodes {
+		switch {
+		case p.attr != "":
+			if value, ok := n.attrs[p.attr]; ok {
+				values = append(values, value)
+			}
+		default:
+			values = append(values, strings.TrimSpace(n.text.String()))
+		}
+	}
+	return values, nil
+}
+
+// eval returns the nodes the step selects from n, in document order. A
+// position counts among the children of each parent, so `//li[1]` is the
+// first item of every list.
+func (st step) eval(n *node) []*node {
+	var matches []*node
+	var walk func(n *node)
+	walk = func(n *node) {
+		position := 0
+		for _, child := range n.children {
+			if st.name == "*" || child.name == st.name {
+				position++
+				if st.position == 0 || st.position == position {
+					matches = append(matches, child)
+				}
+			}
+			if st.descendant {
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	return matches
+}
+
+// parseDocument reads the XML or HTML document into a tree under a root node
+func parseDocument(doc []byte) (*node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(doc))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	root := &node{}
+	stack := []*node{root}
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML body: %v", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: tok.Name.Local, attrs: map[string]string{}}
+			for _, attr := range tok.Attr {
+				n.attrs[attr.Name.Local] = attr.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			for _, n := range stack {
+				n.text.Write(tok)
+			}
+		}
+	}
+	return root, nil
+}
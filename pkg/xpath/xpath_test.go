@@ -0,0 +1,107 @@
+package xpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	doc := []byte(`<!DOCTYPE html>
+<html>
+<head><title>Todos</title></head>
+<body>
+	<ul id="todos">
+		<li class="done">write the parser</li>
+		<li>write the <b>runner</b></li>
+	</ul>
+	<img src="logo.png">
+	<a href="/about">About &amp; more</a>
+</body>
+</html>`)
+
+	tests := [...]struct {
+		path     string
+		expected []string
+	}{
+		{"/html/head/title", []string{"Todos"}},
+		{"//title/text()", []string{"Todos"}},
+		{"//li", []string{"write the parser", "write the runner"}},
+		{"//ul/li[2]", []string{"write the runner"}},
+		{"//li/@class", []string{"done"}},
+		{"//ul/@id", []string{"todos"}},
+		{"//@href", []string{"/about"}},
+		{"//img/@src", []string{"logo.png"}},
+		{"//a", []string{"About & more"}},
+		{"/html/*/title", []string{"Todos"}},
+		{"//li[3]", nil},
+		{"//table", nil},
+	}
+
+	for _, test := range tests {
+		path, err := Parse(test.path)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", test.path, err)
+		}
+		values, err := path.Eval(doc)
+		if err != nil {
+			t.Fatalf("error evaluating %q: %v", test.path, err)
+		}
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Fatalf("error: %s - expected %q, got: %q", test.path, test.expected, values)
+		}
+	}
+}
+
+func TestEvalPositions(t *testing.T) {
+	doc := []byte(`<body>
+	<ul><li>a1</li><li>a2</li></ul>
+	<ol><li>b1</li><li>b2<ul><li>c1</li></ul></li></ol>
+</body>`)
+
+	tests := [...]struct {
+		path     string
+		expected []string
+	}{
+		{"//li[1]", []string{"a1", "b1", "c1"}},
+		{"//li[2]", []string{"a2", "b2c1"}},
+		{"/body/*/li[2]", []string{"a2", "b2c1"}},
+		{"//ul/li[1]", []string{"a1", "c1"}},
+	}
+
+	for _, test := range tests {
+		path, err := Parse(test.path)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", test.path, err)
+		}
+		values, err := path.Eval(doc)
+		if err != nil {
+			t.Fatalf("error evaluating %q: %v", test.path, err)
+		}
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Fatalf("error: %s - expected %q, got: %q", test.path, test.expected, values)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := [...]struct {
+		path string
+		err  string
+	}{
+		{"title", "invalid XPath, expected `/`: `title`"},
+		{"//li[0]", "invalid XPath, invalid position `[0]`: `//li[0]`"},
+		{"//li[1", "invalid XPath, expected `]`: `//li[1`"},
+		{"//a/@href/b", "invalid XPath, `@` and `text()` must be the last step: `//a/@href/b`"},
+		{"/html//", "invalid XPath, expected a name: `/html//`"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.path)
+		if err == nil {
+			t.Fatalf("expected error for %q", test.path)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("error: expected %q, got: %q", test.err, err.Error())
+		}
+	}
+}